@@ -0,0 +1,332 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// apiKeepAlive is how often an idle event stream sends a comment, so
+// proxies and clients don't time it out.
+var apiKeepAlive = 30 * time.Second
+
+// openAPISpec describes the HTTP API; served at /openapi.json.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// startAPI serves the HTTP API on 127.0.0.1 when enabled in config,
+// generating a token on first use.
+func startAPI() {
+	c := cfgSnapshot()
+	if !c.APIEnabled {
+		return
+	}
+	if c.APIToken == "" {
+		c.APIToken = newAPIToken()
+		updateConfig(func(cf *config) { cf.APIToken = c.APIToken })
+		log.Printf("api: generated token, see api_token in %s", configPath())
+	}
+	addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(c.APIPort))
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           apiHandler(c.APIToken),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	log.Printf("api: listening on http://%s", addr)
+	go func() {
+		if err := srv.ListenAndServe(); err != nil {
+			log.Printf("api: %v", err)
+		}
+	}()
+}
+
+func newAPIToken() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// apiHandler routes the HTTP API. Every route but /openapi.json needs the
+// bearer token. Changes go through handleRequest, like the command line.
+func apiHandler(token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /openapi.json", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(openAPISpec)
+	})
+
+	api := http.NewServeMux()
+	api.HandleFunc("GET /monitors", apiGetMonitors)
+	api.HandleFunc("PUT /monitors", apiPutBrightness)
+	api.HandleFunc("GET /monitors/{id}/brightness", apiGetBrightness)
+	api.HandleFunc("PUT /monitors/{id}/brightness", apiPutBrightness)
+	api.HandleFunc("GET /color-temp", apiGetColorTemp)
+	api.HandleFunc("PUT /color-temp", apiPutColorTemp)
+	api.HandleFunc("GET /auto-color", apiGetAutoColor)
+	api.HandleFunc("PUT /auto-color", apiPutAutoColor)
+	api.HandleFunc("GET /profiles", apiGetProfiles)
+	api.HandleFunc("GET /profiles/active", apiGetActiveProfile)
+	api.HandleFunc("PUT /profiles/active", apiPutActiveProfile)
+	api.HandleFunc("GET /events", apiEvents)
+	mux.Handle("/", requireToken(token, api))
+
+	return localOnly(mux)
+}
+
+// localOnly rejects requests whose Host isn't a loopback name, so web pages
+// can't reach the API through DNS rebinding.
+func localOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+		if host != "127.0.0.1" && host != "localhost" && host != "[::1]" && host != "::1" {
+			apiError(w, http.StatusForbidden, errors.New("host not allowed"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func requireToken(token string, next http.Handler) http.Handler {
+	want := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := []byte(r.Header.Get("Authorization"))
+		if subtle.ConstantTimeCompare(got, want) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="monibright"`)
+			apiError(w, http.StatusUnauthorized, errors.New("missing or wrong bearer token"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func apiJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func apiError(w http.ResponseWriter, status int, err error) {
+	apiJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// apiDecode reads a JSON body into v, rejecting unknown fields.
+func apiDecode(w http.ResponseWriter, r *http.Request, v any) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		apiError(w, http.StatusBadRequest, fmt.Errorf("bad body: %w", err))
+		return false
+	}
+	return true
+}
+
+// apiDo runs req and writes the error, if any. It returns the resulting
+// status on success.
+func apiDo(w http.ResponseWriter, req cliRequest) (*cliStatus, bool) {
+	reply := handleRequest(req, false)
+	if reply.Error != "" {
+		apiError(w, http.StatusInternalServerError, errors.New(reply.Error))
+		return nil, false
+	}
+	return reply.Status, true
+}
+
+// apiTarget resolves the {id} path value, answering 404 if it matches no
+// monitor.
+func apiTarget(w http.ResponseWriter, r *http.Request) (string, bool) {
+	id := r.PathValue("id")
+	if _, err := resolveTarget(id); err != nil {
+		apiError(w, http.StatusNotFound, err)
+		return "", false
+	}
+	return id, true
+}
+
+func apiGetMonitors(w http.ResponseWriter, _ *http.Request) {
+	if st, ok := apiDo(w, cliRequest{Command: "get"}); ok {
+		apiJSON(w, http.StatusOK, st.Monitors)
+	}
+}
+
+type apiBrightness struct {
+	Brightness *int `json:"brightness"`
+}
+
+func apiGetBrightness(w http.ResponseWriter, r *http.Request) {
+	id, ok := apiTarget(w, r)
+	if !ok {
+		return
+	}
+	st, ok := apiDo(w, cliRequest{Command: "get", Monitor: id})
+	if !ok {
+		return
+	}
+	if len(st.Monitors) != 1 {
+		apiError(w, http.StatusBadRequest, fmt.Errorf("%q matches %d monitors", id, len(st.Monitors)))
+		return
+	}
+	m := st.Monitors[0]
+	if m.Error != "" {
+		apiError(w, http.StatusBadGateway, errors.New(m.Error))
+		return
+	}
+	apiJSON(w, http.StatusOK, apiBrightness{Brightness: &m.Brightness})
+}
+
+// apiPutBrightness sets the brightness of one monitor or group, or of all
+// monitors on /monitors, and returns the monitors it set.
+func apiPutBrightness(w http.ResponseWriter, r *http.Request) {
+	id := ""
+	if r.PathValue("id") != "" {
+		var ok bool
+		if id, ok = apiTarget(w, r); !ok {
+			return
+		}
+	}
+	var body apiBrightness
+	if !apiDecode(w, r, &body) {
+		return
+	}
+	if body.Brightness == nil || *body.Brightness < 0 || *body.Brightness > 100 {
+		apiError(w, http.StatusBadRequest, errors.New("brightness must be 0-100"))
+		return
+	}
+	if st, ok := apiDo(w, cliRequest{Command: "set", Monitor: id, Level: *body.Brightness}); ok {
+		apiJSON(w, http.StatusOK, st.Monitors)
+	}
+}
+
+type apiColorTemp struct {
+	Temp int  `json:"temp"`
+	Auto bool `json:"auto"`
+}
+
+func apiGetColorTemp(w http.ResponseWriter, _ *http.Request) {
+	apiJSON(w, http.StatusOK, apiColorTemp{Temp: colorTemp(), Auto: autoColorOn()})
+}
+
+// apiPutColorTemp sets a manual color temperature, turning auto color off.
+func apiPutColorTemp(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Temp int `json:"temp"`
+	}
+	if !apiDecode(w, r, &body) {
+		return
+	}
+	if body.Temp < tempMin || body.Temp > tempMax {
+		apiError(w, http.StatusBadRequest, fmt.Errorf("temp must be %d-%d", tempMin, tempMax))
+		return
+	}
+	if st, ok := apiDo(w, cliRequest{Command: "temp", Temp: body.Temp}); ok {
+		apiJSON(w, http.StatusOK, apiColorTemp{Temp: st.Temp, Auto: st.AutoColor})
+	}
+}
+
+type apiAutoColor struct {
+	Enabled *bool `json:"enabled"`
+}
+
+func apiGetAutoColor(w http.ResponseWriter, _ *http.Request) {
+	on := autoColorOn()
+	apiJSON(w, http.StatusOK, apiAutoColor{Enabled: &on})
+}
+
+func apiPutAutoColor(w http.ResponseWriter, r *http.Request) {
+	var body apiAutoColor
+	if !apiDecode(w, r, &body) {
+		return
+	}
+	if body.Enabled == nil {
+		apiError(w, http.StatusBadRequest, errors.New("enabled is required"))
+		return
+	}
+	if st, ok := apiDo(w, cliRequest{Command: "auto", Auto: "color", On: *body.Enabled}); ok {
+		apiJSON(w, http.StatusOK, apiAutoColor{Enabled: &st.AutoColor})
+	}
+}
+
+type apiProfile struct {
+	Name   string `json:"name"`
+	Hotkey string `json:"hotkey,omitempty"`
+}
+
+func apiGetProfiles(w http.ResponseWriter, _ *http.Request) {
+	profiles := []apiProfile{}
+	for _, p := range cfgSnapshot().Profiles {
+		profiles = append(profiles, apiProfile{Name: p.Name, Hotkey: p.Hotkey})
+	}
+	apiJSON(w, http.StatusOK, profiles)
+}
+
+func apiGetActiveProfile(w http.ResponseWriter, _ *http.Request) {
+	profileMu.Lock()
+	name := lastProfile
+	profileMu.Unlock()
+	apiJSON(w, http.StatusOK, apiProfile{Name: name})
+}
+
+// apiPutActiveProfile applies a profile by name.
+func apiPutActiveProfile(w http.ResponseWriter, r *http.Request) {
+	var body apiProfile
+	if !apiDecode(w, r, &body) {
+		return
+	}
+	p, ok := findProfile(strings.TrimSpace(body.Name))
+	if !ok {
+		apiError(w, http.StatusNotFound, fmt.Errorf("no profile %q", body.Name))
+		return
+	}
+	if _, ok := apiDo(w, cliRequest{Command: "profile", Profile: p.Name}); ok {
+		apiJSON(w, http.StatusOK, apiProfile{Name: p.Name, Hotkey: p.Hotkey})
+	}
+}
+
+// apiEvents streams state changes as server-sent events until the client
+// goes away. Each event's data is JSON; see publish.
+func apiEvents(w http.ResponseWriter, r *http.Request) {
+	rc := http.NewResponseController(w)
+	events, unsubscribe := subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	_, _ = fmt.Fprint(w, ": monibright events\n\n")
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	keepAlive := time.NewTicker(apiKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		var err error
+		select {
+		case <-r.Context().Done():
+			return
+		case ev := <-events:
+			_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.ID, ev.Type, ev.Data)
+		case <-keepAlive.C:
+			_, err = fmt.Fprint(w, ": keep-alive\n\n")
+		}
+		if err == nil {
+			err = rc.Flush()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
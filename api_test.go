@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/alex-vit/monibright/monitor"
+)
+
+// apiCall sends one request to the API handler and decodes the JSON reply
+// into out, if given.
+func apiCall(t *testing.T, method, path, body string, out any) int {
+	t.Helper()
+	r := httptest.NewRequest(method, "http://127.0.0.1:8737"+path, strings.NewReader(body))
+	r.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	apiHandler("secret").ServeHTTP(w, r)
+	if out != nil && w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+			t.Fatalf("%s %s: %v\n%s", method, path, err, w.Body)
+		}
+	}
+	return w.Code
+}
+
+func TestAPIAuth(t *testing.T) {
+	useFakeBackend(t, monitor.NewFakeMonitor(0, 50))
+	h := apiHandler("secret")
+	tests := []struct {
+		host, auth, path string
+		want             int
+	}{
+		{"127.0.0.1:8737", "Bearer secret", "/monitors", http.StatusOK},
+		{"localhost:8737", "Bearer secret", "/monitors", http.StatusOK},
+		{"127.0.0.1:8737", "", "/monitors", http.StatusUnauthorized},
+		{"127.0.0.1:8737", "Bearer wrong", "/monitors", http.StatusUnauthorized},
+		{"127.0.0.1:8737", "secret", "/monitors", http.StatusUnauthorized},
+		{"evil.example:8737", "Bearer secret", "/monitors", http.StatusForbidden},
+		{"127.0.0.1:8737", "", "/openapi.json", http.StatusOK},
+		{"evil.example:8737", "", "/openapi.json", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", tt.path, nil)
+		r.Host = tt.host
+		if tt.auth != "" {
+			r.Header.Set("Authorization", tt.auth)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != tt.want {
+			t.Errorf("GET %s (host %s, auth %q) = %d, want %d", tt.path, tt.host, tt.auth, w.Code, tt.want)
+		}
+		if w.Code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+			t.Error("401 without WWW-Authenticate")
+		}
+	}
+}
+
+func TestAPIBrightness(t *testing.T) {
+	a, b := monitor.NewFakeMonitor(0, 50), monitor.NewFakeMonitor(1, 40)
+	useFakeBackend(t, a, b)
+
+	var monitors []monitorStatus
+	if code := apiCall(t, "GET", "/monitors", "", &monitors); code != http.StatusOK || len(monitors) != 2 {
+		t.Fatalf("GET /monitors = %d, %+v", code, monitors)
+	}
+
+	if code := apiCall(t, "PUT", "/monitors/1/brightness", `{"brightness": 70}`, nil); code != http.StatusOK {
+		t.Fatalf("PUT brightness = %d", code)
+	}
+	if a.VCP[monitor.VCPBrightness] != 50 || b.VCP[monitor.VCPBrightness] != 70 {
+		t.Errorf("brightness = %d, %d; want 50, 70", a.VCP[monitor.VCPBrightness], b.VCP[monitor.VCPBrightness])
+	}
+	var got apiBrightness
+	if code := apiCall(t, "GET", "/monitors/1/brightness", "", &got); code != http.StatusOK || got.Brightness == nil || *got.Brightness != 70 {
+		t.Errorf("GET brightness = %d, %+v", code, got)
+	}
+
+	if code := apiCall(t, "PUT", "/monitors", `{"brightness": 20}`, nil); code != http.StatusOK {
+		t.Fatalf("PUT /monitors = %d", code)
+	}
+	if a.VCP[monitor.VCPBrightness] != 20 || b.VCP[monitor.VCPBrightness] != 20 {
+		t.Errorf("brightness = %d, %d; want 20, 20", a.VCP[monitor.VCPBrightness], b.VCP[monitor.VCPBrightness])
+	}
+
+	errs := []struct {
+		method, path, body string
+		want               int
+	}{
+		{"GET", "/monitors/nonexistent/brightness", "", http.StatusNotFound},
+		{"PUT", "/monitors/nonexistent/brightness", `{"brightness": 10}`, http.StatusNotFound},
+		{"PUT", "/monitors/0/brightness", `{"brightness": 101}`, http.StatusBadRequest},
+		{"PUT", "/monitors/0/brightness", `{}`, http.StatusBadRequest},
+		{"PUT", "/monitors/0/brightness", `{"level": 10}`, http.StatusBadRequest},
+		{"PUT", "/monitors/0/brightness", `not json`, http.StatusBadRequest},
+		{"DELETE", "/monitors/0/brightness", "", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range errs {
+		if code := apiCall(t, tt.method, tt.path, tt.body, nil); code != tt.want {
+			t.Errorf("%s %s %s = %d, want %d", tt.method, tt.path, tt.body, code, tt.want)
+		}
+	}
+}
+
+func TestAPIColorTempAndProfiles(t *testing.T) {
+	useFakeBackend(t, monitor.NewFakeMonitor(0, 80))
+	useProfiles(t, profile{Name: "Reading", Hotkey: "ctrl+alt+r", profileLevels: profileLevels{Brightness: intp(30)}})
+
+	var temp apiColorTemp
+	if code := apiCall(t, "PUT", "/color-temp", `{"temp": 4200}`, &temp); code != http.StatusOK || temp.Temp != 4200 || temp.Auto {
+		t.Errorf("PUT /color-temp = %d, %+v", code, temp)
+	}
+	if colorTemp() != 4200 || cfg.ManualTemp != 4200 {
+		t.Errorf("color temp = %dK, manual = %dK, want 4200K", colorTemp(), cfg.ManualTemp)
+	}
+	if code := apiCall(t, "PUT", "/color-temp", `{"temp": 1000}`, nil); code != http.StatusBadRequest {
+		t.Errorf("PUT /color-temp out of range = %d", code)
+	}
+	var auto apiAutoColor
+	if code := apiCall(t, "GET", "/auto-color", "", &auto); code != http.StatusOK || auto.Enabled == nil || *auto.Enabled {
+		t.Errorf("GET /auto-color = %d, %+v", code, auto)
+	}
+	if code := apiCall(t, "PUT", "/auto-color", `{}`, nil); code != http.StatusBadRequest {
+		t.Errorf("PUT /auto-color without enabled = %d", code)
+	}
+
+	var profiles []apiProfile
+	if code := apiCall(t, "GET", "/profiles", "", &profiles); code != http.StatusOK || len(profiles) != 1 || profiles[0].Hotkey != "ctrl+alt+r" {
+		t.Errorf("GET /profiles = %d, %+v", code, profiles)
+	}
+	if code := apiCall(t, "PUT", "/profiles/active", `{"name": "reading"}`, nil); code != http.StatusOK {
+		t.Errorf("PUT /profiles/active = %d", code)
+	}
+	var active apiProfile
+	if code := apiCall(t, "GET", "/profiles/active", "", &active); code != http.StatusOK || active.Name != "Reading" {
+		t.Errorf("GET /profiles/active = %d, %+v", code, active)
+	}
+	if code := apiCall(t, "PUT", "/profiles/active", `{"name": "Gaming"}`, nil); code != http.StatusNotFound {
+		t.Errorf("PUT unknown profile = %d, want 404", code)
+	}
+}
+
+func TestAPIConcurrentWithSlider(t *testing.T) {
+	useFakeBackend(t, monitor.NewFakeMonitor(0, 80))
+	prevTemp := colorTemp()
+	t.Cleanup(func() { setColorTemp(prevTemp) })
+
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Go(func() {
+			apiCall(t, "PUT", "/color-temp", fmt.Sprintf(`{"temp": %d}`, 3500+100*i), nil)
+			apiCall(t, "GET", "/color-temp", "", nil)
+			apiCall(t, "GET", "/auto-color", "", nil)
+		})
+	}
+	wg.Go(func() {
+		for k := 6500; k >= 3500; k -= 100 {
+			requestColorTemp(k) // dragging the slider
+		}
+	})
+	wg.Wait()
+}
+
+func TestAPIEvents(t *testing.T) {
+	useEvents(t)
+	srv := httptest.NewServer(apiHandler("secret"))
+	defer srv.Close()
+
+	req, _ := http.NewRequest("GET", srv.URL+"/events", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); resp.StatusCode != http.StatusOK || ct != "text/event-stream" {
+		t.Fatalf("GET /events = %d, %s", resp.StatusCode, ct)
+	}
+
+	// The stream opens with a comment; once it arrives the handler is
+	// subscribed.
+	lines := bufio.NewScanner(resp.Body)
+	if !lines.Scan() || !strings.HasPrefix(lines.Text(), ":") {
+		t.Fatalf("first line = %q", lines.Text())
+	}
+	publishAuto("color", true)
+	var got []string
+	for lines.Scan() {
+		if lines.Text() != "" {
+			got = append(got, lines.Text())
+		} else if len(got) > 0 {
+			break
+		}
+	}
+	want := []string{"event: auto", `data: {"mode":"color","on":true}`}
+	if len(got) != 3 || !strings.HasPrefix(got[0], "id: ") || got[1] != want[0] || got[2] != want[1] {
+		t.Errorf("event = %q, want id, %q", got, want)
+	}
+}
+
+// TestOpenAPISpec checks that the description is valid JSON and lists every
+// route the handler serves.
+func TestOpenAPISpec(t *testing.T) {
+	var spec struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(openAPISpec, &spec); err != nil {
+		t.Fatal(err)
+	}
+	routes := []string{
+		"GET /monitors", "PUT /monitors",
+		"GET /monitors/{id}/brightness", "PUT /monitors/{id}/brightness",
+		"GET /color-temp", "PUT /color-temp",
+		"GET /auto-color", "PUT /auto-color",
+		"GET /profiles", "GET /profiles/active", "PUT /profiles/active",
+		"GET /events", "GET /openapi.json",
+	}
+	for _, route := range routes {
+		method, path, _ := strings.Cut(route, " ")
+		if _, ok := spec.Paths[path][strings.ToLower(method)]; !ok {
+			t.Errorf("openapi.json is missing %s", route)
+		}
+	}
+}
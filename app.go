@@ -0,0 +1,25 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+var version = ""
+
+var logPath string
+var dataDir string
+
+type isoLogWriter struct{ w io.Writer }
+
+func (lw isoLogWriter) Write(p []byte) (int, error) {
+	return fmt.Fprintf(lw.w, "%s %s", time.Now().Format("2006-01-02 15:04:05"), p)
+}
+
+func displayVersion() string {
+	if version != "" {
+		return version
+	}
+	return "dev"
+}
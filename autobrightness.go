@@ -0,0 +1,169 @@
+package main
+
+import (
+	"log"
+	"math"
+	"sync"
+	"time"
+)
+
+var (
+	autoBrightnessActive bool
+	autoBrightnessStop   chan struct{}
+	autoBrightnessDone   chan struct{}
+	autoBrightnessWake   chan struct{}
+	autoBrightnessMu     sync.Mutex
+)
+
+// interpolateBrightness computes the brightness for the given time based on
+// the sun schedule, blending between dayLevel and nightLevel during twilight
+// like interpolateTemp.
+func interpolateBrightness(now time.Time, sched sunSchedule, dayLevel, nightLevel int) int {
+	level, _ := blend(now, sched, float64(dayLevel), float64(nightLevel))
+	return int(math.Round(level))
+}
+
+// startAutoBrightness launches the auto brightness goroutine. Never blocks on HTTP.
+func startAutoBrightness() {
+	autoBrightnessMu.Lock()
+	defer autoBrightnessMu.Unlock()
+
+	if autoBrightnessActive {
+		return
+	}
+
+	autoBrightnessStop = make(chan struct{})
+	autoBrightnessDone = make(chan struct{})
+	autoBrightnessWake = make(chan struct{}, 1)
+	autoBrightnessActive = true
+	publishAuto("brightness", true)
+	go func(stop, wake, done chan struct{}) {
+		defer close(done)
+		runAutoBrightness(stop, wake)
+	}(autoBrightnessStop, autoBrightnessWake, autoBrightnessDone)
+}
+
+// stopAutoBrightness stops the auto brightness goroutine without waiting for
+// it, as it may be busy fetching the sun schedule. No scheduled write lands
+// after a manual one: the goroutine checks stop under brightnessMu before
+// writing. Leaves the current brightness as-is.
+func stopAutoBrightness() {
+	autoBrightnessMu.Lock()
+	defer autoBrightnessMu.Unlock()
+
+	if !autoBrightnessActive {
+		return
+	}
+	close(autoBrightnessStop)
+	autoBrightnessActive = false
+	publishAuto("brightness", false)
+}
+
+// setAutoBrightness turns auto brightness on or off and saves the choice.
+func setAutoBrightness(on bool) {
+	if on {
+		startAutoBrightness()
+	} else {
+		stopAutoBrightness()
+	}
+	updateConfig(func(c *config) { c.AutoBrightnessEnabled = on })
+	syncAutoBrightnessMenu()
+}
+
+// autoBrightnessOn reports whether auto brightness is running.
+func autoBrightnessOn() bool {
+	autoBrightnessMu.Lock()
+	defer autoBrightnessMu.Unlock()
+	return autoBrightnessActive
+}
+
+// wakeAutoBrightness makes a running auto brightness goroutine recalculate now.
+func wakeAutoBrightness() {
+	autoBrightnessMu.Lock()
+	defer autoBrightnessMu.Unlock()
+
+	if !autoBrightnessActive {
+		return
+	}
+	select {
+	case autoBrightnessWake <- struct{}{}:
+	default:
+	}
+}
+
+// manualBrightness sets brightness on the user's behalf. Like dragging the
+// color temp slider, a manual change disengages auto brightness so the two
+// don't fight.
+func manualBrightness(level int) {
+	disengageAutoBrightness()
+	setBrightness(level)
+}
+
+// disengageAutoBrightness turns auto brightness off after a manual override.
+func disengageAutoBrightness() {
+	if !autoBrightnessOn() {
+		return
+	}
+	stopAutoBrightness()
+	updateConfig(func(c *config) { c.AutoBrightnessEnabled = false })
+	syncAutoBrightnessMenu()
+	log.Printf("auto brightness disabled (manual override)")
+}
+
+func runAutoBrightness(stop, wake chan struct{}) {
+	// Apply immediately from the computed or default schedule — no HTTP wait.
+	sched := sunScheduleAt(time.Now())
+	lastLevel := -1
+	apply := func(reason string) {
+		lv, rules := scheduledLevels(time.Now(), sched)
+		level := lv.Brightness
+		if level == lastLevel {
+			return
+		}
+		brightnessMu.Lock()
+		defer brightnessMu.Unlock()
+		select {
+		case <-stop:
+			return // turned off, maybe by a manual change waiting on the lock
+		default:
+		}
+		log.Printf("autobrightness: %d%% (%s, sched date=%s, rules=%v)", level, reason, sched.key(), rules)
+		applyBrightnessLocked(monitorList(), level)
+		lastLevel = level
+		noteAppliedBrightness(level)
+	}
+	apply("start")
+
+	var err error
+	if sched, err = refreshSunSchedule(); err != nil {
+		log.Printf("autobrightness: %v", err)
+	}
+	apply("corrected after refresh")
+
+	tick := func(reason string) {
+		// Refresh on a new solar day or a time zone change.
+		if next := sunScheduleAt(time.Now()); next.key() != sched.key() {
+			if sched, err = refreshSunSchedule(); err != nil {
+				log.Printf("autobrightness: %v", err)
+			}
+		}
+		apply(reason)
+	}
+
+	ticker := time.NewTicker(60 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			log.Printf("autobrightness: stopped")
+			return
+		case <-ticker.C:
+			tick("tick")
+		case <-wake:
+			// The monitor may have been reset while asleep.
+			lastLevel = -1
+			tick("wake")
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alex-vit/monibright/monitor"
+)
+
+func TestInterpolateBrightness(t *testing.T) {
+	today := time.Now()
+	d := func(h, m int) time.Time {
+		return time.Date(today.Year(), today.Month(), today.Day(), h, m, 0, 0, time.Local)
+	}
+	sched := sunSchedule{
+		CivilTwBegin: d(5, 30),
+		Sunrise:      d(6, 0),
+		Sunset:       d(18, 0),
+		CivilTwEnd:   d(18, 30),
+	}
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want int
+	}{
+		{"deep night", d(3, 0), 30},
+		{"before morning twilight", d(5, 0), 30},
+		{"sunrise midpoint", d(6, 0), 65},
+		{"morning ramp end", d(6, 30), 100},
+		{"noon", d(12, 0), 100},
+		{"sunset midpoint", d(18, 0), 65},
+		{"evening quarter", d(17, 45), 83},
+		{"after evening twilight", d(19, 0), 30},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := interpolateBrightness(tt.now, sched, 100, 30); got != tt.want {
+				t.Errorf("interpolateBrightness(%s) = %d, want %d", tt.now.Format("15:04"), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestManualBrightnessDisengagesAuto(t *testing.T) {
+	m := monitor.NewFakeMonitor(0, 50)
+	useFakeBackend(t, m)
+
+	// A configured location, so the goroutine doesn't go to the network.
+	t.Cleanup(func() {
+		stopAutoBrightness()
+		<-autoBrightnessDone
+		cfg.AutoBrightnessEnabled = false
+		cfg.DayBrightness, cfg.NightBrightness = 0, 0
+		cfg.Latitude, cfg.Longitude = 0, 0
+	})
+	cfg.Latitude, cfg.Longitude = 52.52, 13.41
+	cfg.AutoBrightnessEnabled = true
+	cfg.DayBrightness, cfg.NightBrightness = 100, 30
+	startAutoBrightness()
+	if !autoBrightnessActive {
+		t.Fatal("auto brightness not started")
+	}
+
+	manualBrightness(70)
+	if autoBrightnessActive || cfg.AutoBrightnessEnabled {
+		t.Errorf("auto brightness still on after manual change (active=%v enabled=%v)",
+			autoBrightnessActive, cfg.AutoBrightnessEnabled)
+	}
+	if got := m.VCP[monitor.VCPBrightness]; got != 70 {
+		t.Errorf("brightness = %d, want 70", got)
+	}
+}
+
+func TestStopAutoBrightnessDoesNotWait(t *testing.T) {
+	m := monitor.NewFakeMonitor(0, 50)
+	useFakeBackend(t, m)
+	t.Cleanup(func() {
+		cfg.AutoBrightnessEnabled = false
+		cfg.DayBrightness, cfg.NightBrightness = 0, 0
+		cfg.Latitude, cfg.Longitude = 0, 0
+	})
+	cfg.Latitude, cfg.Longitude = 52.52, 13.41
+	cfg.AutoBrightnessEnabled = true
+	cfg.DayBrightness, cfg.NightBrightness = 100, 30
+
+	// Hold up the goroutine's schedule refresh, as a slow network would.
+	schedMu.Lock()
+	startAutoBrightness()
+	done := autoBrightnessDone
+
+	changed := make(chan struct{})
+	go func() {
+		manualBrightness(70)
+		close(changed)
+	}()
+	select {
+	case <-changed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("manual change blocked on the auto brightness goroutine")
+	}
+	schedMu.Unlock()
+	<-done
+
+	if got := m.VCP[monitor.VCPBrightness]; got != 70 {
+		t.Errorf("brightness = %d, want the manual 70", got)
+	}
+}
@@ -16,8 +16,15 @@ var (
 	autoColorWake   chan struct{}
 	autoColorMu     sync.Mutex
 
-	cachedSched    sunSchedule
-	cachedSchedDay int // YearDay when last fetched, 0 = never
+	// currentColorTemp is the temperature on screen, read with colorTemp
+	// from any goroutine.
+	currentColorTemp = 6500
+	colorTempMu      sync.Mutex
+
+	// schedMu serializes refreshSunSchedule between auto color and auto
+	// brightness, so they share one location lookup and API check.
+	schedMu    sync.Mutex
+	checkedDay string // key of the last schedule checked against the API
 )
 
 // detectLocation determines latitude/longitude from IP geolocation,
@@ -62,8 +69,10 @@ func locationFromIP() (lat, lon float64, err error) {
 	return result.Lat, result.Lon, nil
 }
 
+// locationFromTimezone guesses the location from the schedule's time zone.
+// time.Local is no use here: on Windows it is named "Local".
 func locationFromTimezone() (lat, lon float64, err error) {
-	tz := time.Now().Location().String()
+	tz := scheduleZone().String()
 	coords, ok := tzCoords[tz]
 	if !ok {
 		return 0, 0, fmt.Errorf("unknown timezone %q", tz)
@@ -72,15 +81,35 @@ func locationFromTimezone() (lat, lon float64, err error) {
 	return coords[0], coords[1], nil
 }
 
-// sunSchedule holds the parsed sun times for one day.
+// sunCycle says whether the sun rises and sets on a given day.
+type sunCycle int
+
+const (
+	sunRisesAndSets sunCycle = iota
+	sunNeverSets             // polar day (midnight sun)
+	sunNeverRises            // polar night
+)
+
+// sunSchedule holds the sun times for one day. Times that don't occur that
+// day are zero: astronomical twilight in a northern summer, civil twilight on
+// white nights, and sunrise and sunset when Cycle isn't sunRisesAndSets.
 type sunSchedule struct {
-	Sunrise      time.Time
-	Sunset       time.Time
-	CivilTwBegin time.Time // civil twilight begin (morning)
-	CivilTwEnd   time.Time // civil twilight end (evening)
+	Cycle           sunCycle
+	Fixed           bool      // built from cfg.FixedTimes rather than the sun
+	Noon            time.Time // solar noon (wake time if Fixed); anchors the schedule's day
+	Sunrise         time.Time
+	Sunset          time.Time
+	CivilTwBegin    time.Time // civil twilight begin (morning), sun at -6°
+	CivilTwEnd      time.Time // civil twilight end (evening)
+	NauticalTwBegin time.Time // sun at -12°
+	NauticalTwEnd   time.Time
+	AstroTwBegin    time.Time // sun at -18°
+	AstroTwEnd      time.Time
 }
 
 // fetchSunSchedule queries the sunrise-sunset.io API for the given coordinates.
+// The schedule is computed offline (see computeSunSchedule); this is only
+// used to cross-check it.
 func fetchSunSchedule(lat, lon float64) (sunSchedule, error) {
 	url := fmt.Sprintf("https://api.sunrisesunset.io/json?lat=%f&lng=%f&date=today", lat, lon)
 	req, err := http.NewRequest(http.MethodGet, url, nil) //nolint:noctx
@@ -115,7 +144,11 @@ func fetchSunSchedule(lat, lon float64) (sunSchedule, error) {
 	}
 
 	today := time.Now()
+	// Times are empty when they don't happen, e.g. no sunset in polar day.
 	parse := func(s string) (time.Time, error) {
+		if s == "" {
+			return time.Time{}, nil
+		}
 		t, err := time.Parse("3:04:05 PM", s)
 		if err != nil {
 			return time.Time{}, err
@@ -141,11 +174,152 @@ func fetchSunSchedule(lat, lon float64) (sunSchedule, error) {
 	return sched, nil
 }
 
-// defaultSunSchedule returns a hardcoded fallback (sunrise 06:00, sunset 18:00).
-func defaultSunSchedule() sunSchedule {
-	today := time.Now()
+func (s sunSchedule) String() string {
+	if s.Fixed {
+		return fmt.Sprintf("fixed times, wake=%s bed=%s (sched date=%s)",
+			formatSunTime(s.CivilTwBegin), formatSunTime(s.CivilTwEnd), s.key())
+	}
+	switch s.Cycle {
+	case sunNeverSets:
+		return "midnight sun, sun never sets"
+	case sunNeverRises:
+		return "polar night, sun never rises"
+	}
+	return fmt.Sprintf("sunrise=%s sunset=%s dawn=%s dusk=%s (sched date=%s)",
+		formatSunTime(s.Sunrise), formatSunTime(s.Sunset),
+		formatSunTime(s.CivilTwBegin), formatSunTime(s.CivilTwEnd),
+		s.key())
+}
+
+// anchor returns the middle of the schedule's day: solar noon (wake time for
+// fixed schedules), or noon on the sunrise date for schedules that don't
+// record it.
+func (s sunSchedule) anchor() time.Time {
+	if !s.Noon.IsZero() {
+		return s.Noon
+	}
+	return time.Date(s.Sunrise.Year(), s.Sunrise.Month(), s.Sunrise.Day(), 12, 0, 0, 0, s.Sunrise.Location())
+}
+
+// key identifies the schedule's day and time zone, e.g.
+// "2026-03-29 Europe/Berlin". It changes at each new solar day, and when the
+// time zone changes.
+func (s sunSchedule) key() string {
+	a := s.anchor()
+	return a.Format("2006-01-02") + " " + a.Location().String()
+}
+
+// formatSunTime formats a schedule time, or "none" if it doesn't occur.
+func formatSunTime(t time.Time) string {
+	if t.IsZero() {
+		return "none"
+	}
+	return t.Format("15:04")
+}
+
+// sunScheduleAt returns the schedule for the solar day containing now, from
+// one solar midnight to the next, in the schedule time zone. Events after
+// civil midnight, like a 00:04 sunset in a Reykjavik summer, stay with the
+// day they belong to. Without a location it returns the default schedule;
+// in fixed mode, the fixed-times schedule. Never touches the network.
+func sunScheduleAt(now time.Time) sunSchedule {
+	now = now.In(scheduleZone())
+	c := cfgSnapshot()
+	if c.ScheduleMode == modeFixed {
+		return fixedScheduleAt(now)
+	}
+	if c.Latitude == 0 && c.Longitude == 0 {
+		return defaultSunSchedule(now)
+	}
+	sched := computeSunSchedule(now, c.Latitude, c.Longitude)
+	switch {
+	case now.Before(sched.Noon.Add(-12 * time.Hour)):
+		sched = computeSunSchedule(now.AddDate(0, 0, -1), c.Latitude, c.Longitude)
+	case !now.Before(sched.Noon.Add(12 * time.Hour)):
+		sched = computeSunSchedule(now.AddDate(0, 0, 1), c.Latitude, c.Longitude)
+	}
+	return sched
+}
+
+// refreshSunSchedule returns the schedule for now after making sure the
+// location is known. The location is detected when unset, and re-detected
+// when it was detected in another time zone, so a travelling laptop follows
+// its new sunset. With sun_api_check set, each day's schedule is also
+// compared against the sunrisesunset.io API. May block on the network.
+func refreshSunSchedule() (sunSchedule, error) {
+	schedMu.Lock()
+	defer schedMu.Unlock()
+
+	c := cfgSnapshot()
+	if c.ScheduleMode == modeFixed {
+		return sunScheduleAt(time.Now()), nil
+	}
+
+	zone := scheduleZone().String()
+	if c.LocationDetected && c.LocationZone != zone {
+		log.Printf("autocolor: time zone changed from %s to %s, re-detecting location", c.LocationZone, zone)
+		cfgMu.Lock()
+		cfg.Latitude, cfg.Longitude = 0, 0
+		cfgMu.Unlock()
+		c.Latitude, c.Longitude = 0, 0
+	}
+	if c.Latitude == 0 && c.Longitude == 0 {
+		lat, lon, err := detectLocation()
+		if err != nil {
+			return sunScheduleAt(time.Now()), fmt.Errorf("location detection failed: %w", err)
+		}
+		updateConfig(func(c *config) {
+			c.Latitude = lat
+			c.Longitude = lon
+			c.LocationDetected = true
+			c.LocationZone = zone
+		})
+		log.Printf("autocolor: detected location lat=%.2f lon=%.2f", lat, lon)
+	}
+	sched := sunScheduleAt(time.Now())
+	if c.SunAPICheck && checkedDay != sched.key() {
+		crossCheckSunSchedule(sched)
+		checkedDay = sched.key()
+	}
+	return sched, nil
+}
+
+// crossCheckSunSchedule logs where the sunrisesunset.io API disagrees with
+// the computed schedule by more than a minute.
+func crossCheckSunSchedule(sched sunSchedule) {
+	c := cfgSnapshot()
+	api, err := fetchSunSchedule(c.Latitude, c.Longitude)
+	if err != nil {
+		log.Printf("sun schedule check: %v", err)
+		return
+	}
+	for _, ev := range []struct {
+		name          string
+		computed, api time.Time
+	}{
+		{"sunrise", sched.Sunrise, api.Sunrise},
+		{"sunset", sched.Sunset, api.Sunset},
+		{"dawn", sched.CivilTwBegin, api.CivilTwBegin},
+		{"dusk", sched.CivilTwEnd, api.CivilTwEnd},
+	} {
+		if ev.computed.IsZero() != ev.api.IsZero() {
+			log.Printf("sun schedule check: %s computed %s, API %s", ev.name,
+				formatSunTime(ev.computed), formatSunTime(ev.api))
+			continue
+		}
+		if d := ev.computed.Sub(ev.api).Abs(); d > time.Minute {
+			log.Printf("sun schedule check: %s computed %s, API %s (off by %s)",
+				ev.name, formatSunTime(ev.computed), formatSunTime(ev.api), d.Round(time.Second))
+		}
+	}
+}
+
+// defaultSunSchedule returns a hardcoded fallback (sunrise 06:00, sunset 18:00)
+// for the day of today, in today's location.
+func defaultSunSchedule(today time.Time) sunSchedule {
 	loc := today.Location()
 	return sunSchedule{
+		Noon:         time.Date(today.Year(), today.Month(), today.Day(), 12, 0, 0, 0, loc),
 		Sunrise:      time.Date(today.Year(), today.Month(), today.Day(), 6, 0, 0, 0, loc),
 		Sunset:       time.Date(today.Year(), today.Month(), today.Day(), 18, 0, 0, 0, loc),
 		CivilTwBegin: time.Date(today.Year(), today.Month(), today.Day(), 5, 30, 0, 0, loc),
@@ -153,19 +327,44 @@ func defaultSunSchedule() sunSchedule {
 	}
 }
 
-// normalizeSched moves schedule times to the same date as now,
-// preserving the hour/minute/second. Prevents stale-date comparisons
-// when the schedule was fetched on a previous day.
+// normalizeSched moves a schedule from an earlier (or later) day to the solar
+// day containing now, by whole calendar days so the wall-clock times survive
+// DST changes. Prevents stale-date comparisons when the schedule was made
+// on a previous day.
 func normalizeSched(now time.Time, s sunSchedule) sunSchedule {
+	if s.Fixed {
+		return s // built for the day in effect by fixedScheduleAt; days vary in length
+	}
+	a := s.anchor()
+	an := now.In(a.Location())
+	days := int(time.Date(an.Year(), an.Month(), an.Day(), 0, 0, 0, 0, time.UTC).Sub(
+		time.Date(a.Year(), a.Month(), a.Day(), 0, 0, 0, 0, time.UTC)) / (24 * time.Hour))
+	switch shifted := a.AddDate(0, 0, days); {
+	case now.Before(shifted.Add(-12 * time.Hour)):
+		days--
+	case !now.Before(shifted.Add(12 * time.Hour)):
+		days++
+	}
+	if days == 0 {
+		return s
+	}
 	redate := func(t time.Time) time.Time {
-		return time.Date(now.Year(), now.Month(), now.Day(),
-			t.Hour(), t.Minute(), t.Second(), 0, now.Location())
+		if t.IsZero() {
+			return t
+		}
+		return t.AddDate(0, 0, days)
 	}
 	return sunSchedule{
-		Sunrise:      redate(s.Sunrise),
-		Sunset:       redate(s.Sunset),
-		CivilTwBegin: redate(s.CivilTwBegin),
-		CivilTwEnd:   redate(s.CivilTwEnd),
+		Cycle:           s.Cycle,
+		Noon:            redate(s.Noon),
+		Sunrise:         redate(s.Sunrise),
+		Sunset:          redate(s.Sunset),
+		CivilTwBegin:    redate(s.CivilTwBegin),
+		CivilTwEnd:      redate(s.CivilTwEnd),
+		NauticalTwBegin: redate(s.NauticalTwBegin),
+		NauticalTwEnd:   redate(s.NauticalTwEnd),
+		AstroTwBegin:    redate(s.AstroTwBegin),
+		AstroTwEnd:      redate(s.AstroTwEnd),
 	}
 }
 
@@ -173,37 +372,103 @@ func normalizeSched(now time.Time, s sunSchedule) sunSchedule {
 // the sun schedule, linearly blending between dayTemp and nightTemp during
 // twilight transitions.
 func interpolateTemp(now time.Time, sched sunSchedule, dayTemp, nightTemp int) int {
+	temp, ramp := blend(now, sched, float64(dayTemp), float64(nightTemp))
+	if !ramp {
+		return int(temp)
+	}
+	return roundTo100(int(temp))
+}
+
+// Transition models, selected by cfg.TransitionModel.
+const (
+	// modelTwilight ramps over clock time, symmetric around sunrise and
+	// sunset and ending at civil dawn/dusk.
+	modelTwilight = "twilight"
+	// modelElevation ramps over solar elevation between cfg.ElevationLow and
+	// cfg.ElevationHigh, like Redshift.
+	modelElevation = "elevation"
+)
+
+// blend returns the day/night blend at now under the configured transition
+// model. The elevation model needs the sun and a location; for fixed-times
+// schedules, or without a location, it falls back to the twilight model.
+func blend(now time.Time, sched sunSchedule, day, night float64) (value float64, ramp bool) {
+	c := cfgSnapshot()
+	if c.TransitionModel == modelElevation && !sched.Fixed && (c.Latitude != 0 || c.Longitude != 0) {
+		elev := solarElevation(now, c.Latitude, c.Longitude)
+		return elevationBlend(elev, c.ElevationHigh, c.ElevationLow, day, night)
+	}
+	return sunBlend(now, sched, day, night)
+}
+
+// elevationBlend returns day above the high elevation, night below the low
+// one, and a linear blend in between (Redshift's model).
+func elevationBlend(elev, high, low, day, night float64) (value float64, ramp bool) {
+	switch {
+	case elev >= high:
+		return day, false
+	case elev <= low:
+		return night, false
+	default:
+		frac := (elev - low) / (high - low)
+		return night + frac*(day-night), true
+	}
+}
+
+// sunBlend returns night before dawn and after dusk, day in between, and a
+// linear blend of the two during the twilight ramps. ramp reports whether now
+// falls in a ramp.
+func sunBlend(now time.Time, sched sunSchedule, day, night float64) (value float64, ramp bool) {
+	switch sched.Cycle {
+	case sunNeverSets:
+		return day, false
+	case sunNeverRises:
+		return night, false
+	}
+
 	// Normalize schedule times to now's date to prevent stale-date bugs
 	// (e.g. schedule from yesterday causing permanent night after midnight).
 	sched = normalizeSched(now, sched)
 
+	// On white nights the sun sets but never reaches -6°, so there is no
+	// civil twilight to end the ramps. Use 30 minutes, or half the night if
+	// that's shorter, so the evening and morning ramps don't overlap.
+	twBegin, twEnd := sched.CivilTwBegin, sched.CivilTwEnd
+	if twBegin.IsZero() || twEnd.IsZero() {
+		half := min(30*time.Minute, (24*time.Hour-sched.Sunset.Sub(sched.Sunrise))/2)
+		if twBegin.IsZero() {
+			twBegin = sched.Sunrise.Add(-half)
+		}
+		if twEnd.IsZero() {
+			twEnd = sched.Sunset.Add(half)
+		}
+	}
+
 	// Evening ramp: sunset is the midpoint (~50% warm at sunset).
 	// eveningStart = 2*sunset - civilTwEnd  (≈30 min before sunset)
 	// eveningEnd   = civilTwEnd             (≈30 min after sunset)
-	eveningStart := sched.Sunset.Add(sched.Sunset.Sub(sched.CivilTwEnd)) // 2*sunset - twEnd
-	eveningEnd := sched.CivilTwEnd
+	eveningStart := sched.Sunset.Add(sched.Sunset.Sub(twEnd)) // 2*sunset - twEnd
+	eveningEnd := twEnd
 
 	// Morning ramp: sunrise is the midpoint (symmetric).
 	// morningStart = civilTwBegin              (≈30 min before sunrise)
 	// morningEnd   = 2*sunrise - civilTwBegin  (≈30 min after sunrise)
-	morningStart := sched.CivilTwBegin
-	morningEnd := sched.Sunrise.Add(sched.Sunrise.Sub(sched.CivilTwBegin)) // 2*sunrise - twBegin
+	morningStart := twBegin
+	morningEnd := sched.Sunrise.Add(sched.Sunrise.Sub(twBegin)) // 2*sunrise - twBegin
 
 	switch {
 	case now.Before(morningStart) || now.After(eveningEnd):
-		return nightTemp
+		return night, false
 	case now.After(morningEnd) && now.Before(eveningStart):
-		return dayTemp
+		return day, false
 	case !now.Before(morningStart) && !now.After(morningEnd):
 		// Morning transition: night → day
 		frac := float64(now.Sub(morningStart)) / float64(morningEnd.Sub(morningStart))
-		temp := float64(nightTemp) + frac*float64(dayTemp-nightTemp)
-		return roundTo100(int(temp))
+		return night + frac*(day-night), true
 	default:
 		// Evening transition: day → night
 		frac := float64(now.Sub(eveningStart)) / float64(eveningEnd.Sub(eveningStart))
-		temp := float64(dayTemp) + frac*float64(nightTemp-dayTemp)
-		return roundTo100(int(temp))
+		return day + frac*(night-day), true
 	}
 }
 
@@ -211,6 +476,83 @@ func roundTo100(k int) int {
 	return ((k + 50) / 100) * 100
 }
 
+// setAutoColor turns auto color temperature on or off and saves the choice.
+// Turning it off animates back to the manual temperature, like the slider's
+// Auto toggle.
+func setAutoColor(on bool) {
+	if on == autoColorOn() {
+		return
+	}
+	from := colorTemp()
+	updateConfig(func(c *config) { c.AutoColorEnabled = on })
+	if on {
+		startAutoColor(from)
+	} else {
+		stopAutoColor()
+		animateColorTempSync(from, cfgSnapshot().ManualTemp, make(chan struct{}))
+	}
+	syncAutoToggle()
+}
+
+// manualColorTemp sets a fixed color temperature on the user's behalf,
+// turning auto color off like dragging the temperature slider does.
+func manualColorTemp(kelvin int, animate bool) {
+	kelvin = clamp(kelvin, tempMin, tempMax)
+	from := colorTemp()
+	wasAuto := autoColorOn()
+	if wasAuto {
+		stopAutoColor()
+		log.Printf("auto color temp disabled (manual override)")
+	}
+	updateConfig(func(c *config) {
+		if wasAuto {
+			c.AutoColorEnabled = false
+		}
+		c.ManualTemp = kelvin
+	})
+	syncManualTemp(kelvin)
+	if animate {
+		animateColorTempSync(from, kelvin, make(chan struct{}))
+		return
+	}
+	requestColorTemp(kelvin)
+	syncColorTempSlider(kelvin)
+}
+
+// autoColorOn reports whether auto color temperature is running.
+func autoColorOn() bool {
+	autoColorMu.Lock()
+	defer autoColorMu.Unlock()
+	return autoColorActive
+}
+
+// wakeAutoColor makes a running auto color goroutine recalculate now.
+func wakeAutoColor() {
+	autoColorMu.Lock()
+	defer autoColorMu.Unlock()
+
+	if !autoColorActive {
+		return
+	}
+	select {
+	case autoColorWake <- struct{}{}:
+	default:
+	}
+}
+
+// colorTemp returns the color temperature on screen.
+func colorTemp() int {
+	colorTempMu.Lock()
+	defer colorTempMu.Unlock()
+	return currentColorTemp
+}
+
+func setColorTemp(kelvin int) {
+	colorTempMu.Lock()
+	currentColorTemp = kelvin
+	colorTempMu.Unlock()
+}
+
 // startAutoColor launches the auto color goroutine. Never blocks on HTTP.
 // If animateFrom > 0, the first color temp change is animated from that value.
 func startAutoColor(animateFrom int) {
@@ -224,7 +566,8 @@ func startAutoColor(animateFrom int) {
 	autoColorStop = make(chan struct{})
 	autoColorWake = make(chan struct{}, 1)
 	autoColorActive = true
-	go runAutoColor(autoColorStop, animateFrom)
+	publishAuto("color", true)
+	go runAutoColor(autoColorStop, autoColorWake, animateFrom)
 }
 
 // stopAutoColor stops the auto color goroutine. Leaves the current color temp as-is.
@@ -237,18 +580,16 @@ func stopAutoColor() {
 	}
 	close(autoColorStop)
 	autoColorActive = false
+	publishAuto("color", false)
 }
 
-func runAutoColor(stop chan struct{}, animateFrom int) {
-	// Use cached or default schedule for immediate response.
-	sched := defaultSunSchedule()
-	if cachedSchedDay == time.Now().YearDay() {
-		sched = cachedSched
-	}
+func runAutoColor(stop, wake chan struct{}, animateFrom int) {
+	// Computed (or default) schedule for immediate response.
+	sched := sunScheduleAt(time.Now())
 
 	// Animate/apply immediately — no HTTP wait.
 	lastTemp := 0
-	temp := interpolateTemp(time.Now(), sched, cfg.DayTemp, cfg.NightTemp)
+	temp := scheduledTemp(time.Now(), sched)
 	if animateFrom > 0 && animateFrom != temp {
 		log.Printf("autocolor: %dK (animating from %dK)", temp, animateFrom)
 		animateColorTempSync(animateFrom, temp, stop)
@@ -258,72 +599,44 @@ func runAutoColor(stop chan struct{}, animateFrom int) {
 		syncColorTempSlider(temp)
 	}
 	lastTemp = temp
+	noteAppliedTemp(temp)
 
 	// Background: detect location if needed, then refresh schedule.
-	if cfg.Latitude == 0 && cfg.Longitude == 0 {
-		lat, lon, err := detectLocation()
-		if err != nil {
-			log.Printf("autocolor: location detection failed: %v", err)
-		} else {
-			cfg.Latitude = lat
-			cfg.Longitude = lon
-			saveConfig()
-			log.Printf("autocolor: detected location lat=%.2f lon=%.2f", lat, lon)
-		}
-	}
-	if cfg.Latitude != 0 || cfg.Longitude != 0 {
-		if freshSched, err := fetchSunSchedule(cfg.Latitude, cfg.Longitude); err != nil {
-			log.Printf("autocolor: sun schedule fetch failed: %v", err)
-		} else {
-			sched = freshSched
-			cachedSched = freshSched
-			cachedSchedDay = time.Now().YearDay()
-			log.Printf("autocolor: sunrise=%s sunset=%s dawn=%s dusk=%s (sched date=%s)",
-				sched.Sunrise.Format("15:04"), sched.Sunset.Format("15:04"),
-				sched.CivilTwBegin.Format("15:04"), sched.CivilTwEnd.Format("15:04"),
-				sched.Sunrise.Format("2006-01-02"))
-		}
+	sched, err := refreshSunSchedule()
+	if err != nil {
+		log.Printf("autocolor: %v", err)
 	}
+	log.Printf("autocolor: %s", sched)
 
 	// Apply corrected temp if the fresh schedule changed it.
-	temp = interpolateTemp(time.Now(), sched, cfg.DayTemp, cfg.NightTemp)
+	temp = scheduledTemp(time.Now(), sched)
 	if temp != lastTemp {
 		log.Printf("autocolor: %dK (corrected after refresh)", temp)
 		requestColorTemp(temp)
 		syncColorTempSlider(temp)
 		lastTemp = temp
+		noteAppliedTemp(temp)
 	}
 
-	lastDate := time.Now().YearDay()
-
 	tick := func() {
 		now := time.Now()
 
-		// Re-fetch schedule on date change.
-		if now.YearDay() != lastDate {
-			newSched, err := fetchSunSchedule(cfg.Latitude, cfg.Longitude)
-			if err != nil {
-				log.Printf("autocolor: schedule re-fetch failed: %v, using defaults", err)
-				sched = defaultSunSchedule()
-			} else {
-				sched = newSched
-				cachedSched = newSched
-				cachedSchedDay = now.YearDay()
+		// Refresh on a new solar day or a time zone change.
+		if next := sunScheduleAt(now); next.key() != sched.key() {
+			if sched, err = refreshSunSchedule(); err != nil {
+				log.Printf("autocolor: %v", err)
 			}
-			log.Printf("autocolor: new day, sunrise=%s sunset=%s dawn=%s dusk=%s (sched date=%s)",
-				sched.Sunrise.Format("15:04"), sched.Sunset.Format("15:04"),
-				sched.CivilTwBegin.Format("15:04"), sched.CivilTwEnd.Format("15:04"),
-				sched.Sunrise.Format("2006-01-02"))
-			lastDate = now.YearDay()
+			log.Printf("autocolor: new day, %s", sched)
 		}
 
-		temp := interpolateTemp(now, sched, cfg.DayTemp, cfg.NightTemp)
+		lv, rules := scheduledLevels(now, sched)
+		temp := lv.Temp
 		if temp != lastTemp {
-			log.Printf("autocolor: %dK → %dK (sched date=%s)",
-				lastTemp, temp, sched.Sunrise.Format("2006-01-02"))
+			log.Printf("autocolor: %dK → %dK (sched date=%s, rules=%v)", lastTemp, temp, sched.key(), rules)
 			requestColorTemp(temp)
 			syncColorTempSlider(temp)
 			lastTemp = temp
+			noteAppliedTemp(temp)
 		}
 	}
 
@@ -337,25 +650,13 @@ func runAutoColor(stop chan struct{}, animateFrom int) {
 			return
 		case <-ticker.C:
 			tick()
-		case <-autoColorWake:
+		case <-wake:
 			log.Printf("autocolor: wake, recalculating")
 			tick()
 		}
 	}
 }
 
-// syncColorTempSlider posts a message to update the slider UI from any goroutine.
-func syncColorTempSlider(kelvin int) {
-	select {
-	case <-sliderReady:
-	default:
-		return
-	}
-	if sliderHWND != 0 {
-		procPostMessageW.Call(sliderHWND, wmSyncColorTemp, uintptr(kelvin), 0) //nolint:errcheck
-	}
-}
-
 // tzCoords maps IANA timezone names to approximate city coordinates.
 var tzCoords = map[string][2]float64{
 	"America/New_York":               {40.71, -74.01},
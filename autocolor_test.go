@@ -1,6 +1,7 @@
 package main
 
 import (
+	"math"
 	"testing"
 	"time"
 )
@@ -111,7 +112,7 @@ func TestInterpolateTempStaleSchedule(t *testing.T) {
 }
 
 func TestDefaultSunScheduleUsesToday(t *testing.T) {
-	sched := defaultSunSchedule()
+	sched := defaultSunSchedule(time.Now())
 	today := time.Now()
 
 	if sched.Sunrise.Day() != today.Day() || sched.Sunrise.Month() != today.Month() {
@@ -219,3 +220,94 @@ func TestRoundTo100(t *testing.T) {
 		}
 	}
 }
+
+func TestElevationBlend(t *testing.T) {
+	tests := []struct {
+		elev     float64
+		want     float64
+		wantRamp bool
+	}{
+		{30, 6500, false},
+		{3, 6500, false},
+		{0, 5500, true}, // a third of the way to night at the horizon
+		{-1.5, 5000, true},
+		{-6, 3500, false},
+		{-20, 3500, false},
+	}
+	for _, tt := range tests {
+		got, ramp := elevationBlend(tt.elev, 3, -6, 6500, 3500)
+		if math.Abs(got-tt.want) > 1 || ramp != tt.wantRamp {
+			t.Errorf("elevationBlend(%.1f°) = %.0f, %v; want %.0f, %v", tt.elev, got, ramp, tt.want, tt.wantRamp)
+		}
+	}
+}
+
+func TestInterpolateTempElevationModel(t *testing.T) {
+	prev := cfg
+	t.Cleanup(func() { cfg = prev })
+	cfg.Latitude, cfg.Longitude = 51.5074, -0.1278 // London
+	cfg.ElevationHigh, cfg.ElevationLow = 3, -6
+
+	date := time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC)
+	sched := computeSunSchedule(date, cfg.Latitude, cfg.Longitude)
+	at := func(h, m int) time.Time { return time.Date(2024, 3, 20, h, m, 0, 0, time.UTC) }
+
+	cfg.TransitionModel = modelElevation
+	if got := interpolateTemp(at(12, 0), sched, 6500, 3500); got != 6500 {
+		t.Errorf("noon = %d, want 6500", got)
+	}
+	if got := interpolateTemp(sched.CivilTwEnd.Add(time.Minute), sched, 6500, 3500); got != 3500 {
+		t.Errorf("after civil dusk = %d, want 3500", got)
+	}
+	// Unlike the twilight model, the evening ramp is a third done at sunset.
+	elev := interpolateTemp(sched.Sunset, sched, 6500, 3500)
+	if elev < 4900 || elev > 5300 {
+		t.Errorf("elevation model at sunset = %d, want ≈5100", elev)
+	}
+
+	cfg.TransitionModel = modelTwilight
+	if got := interpolateTemp(sched.Sunset, sched, 6500, 3500); got != 5000 {
+		t.Errorf("twilight model at sunset = %d, want 5000", got)
+	}
+}
+
+func TestInterpolateTempPolar(t *testing.T) {
+	oslo, err := time.LoadLocation("Europe/Oslo")
+	if err != nil {
+		t.Skipf("no tzdata: %v", err)
+	}
+	const tromsøLat, tromsøLon = 69.6492, 18.9553
+	const svalbardLat, svalbardLon = 78.2232, 15.6267
+	at := func(date string, h, m int) time.Time {
+		d, _ := time.ParseInLocation("2006-01-02", date, oslo)
+		return d.Add(time.Duration(h)*time.Hour + time.Duration(m)*time.Minute)
+	}
+	const day, night = 6500, 3500
+
+	tests := []struct {
+		name     string
+		lat, lon float64
+		now      time.Time
+		want     int
+	}{
+		{"Tromsø midnight sun, midnight", tromsøLat, tromsøLon, at("2024-06-21", 0, 30), day},
+		{"Svalbard midnight sun, 3am", svalbardLat, svalbardLon, at("2024-06-21", 3, 0), day},
+		{"Tromsø polar night, noon", tromsøLat, tromsøLon, at("2024-12-21", 12, 0), night},
+		{"Svalbard polar night, noon", svalbardLat, svalbardLon, at("2024-12-21", 12, 0), night},
+		// White night: sunset 23:25, sunrise 02:08, no civil twilight.
+		{"Tromsø white night, noon", tromsøLat, tromsøLon, at("2024-07-30", 12, 0), day},
+		{"Tromsø white night, sunset", tromsøLat, tromsøLon, at("2024-07-30", 23, 25), 5000},
+		{"Tromsø white night, after ramp", tromsøLat, tromsøLon, at("2024-07-30", 23, 58), night},
+		{"Tromsø white night, 1am", tromsøLat, tromsøLon, at("2024-07-30", 1, 0), night},
+		{"Tromsø white night, sunrise", tromsøLat, tromsøLon, at("2024-07-30", 2, 8), 5000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sched := computeSunSchedule(tt.now, tt.lat, tt.lon)
+			got := interpolateTemp(tt.now, sched, day, night)
+			if got < tt.want-100 || got > tt.want+100 {
+				t.Errorf("interpolateTemp(%s) = %d, want ≈%d (%s)", tt.now.Format("15:04"), got, tt.want, sched)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+)
+
+// Boot health check after an update. applyUpdate leaves a marker next to
+// the exe; every start of the new version counts an attempt and a start that
+// gets the tray up (bootOK) removes it. Once maxFailedBoots starts have
+// failed, the next one puts the previous binary back and skips the version.
+var maxFailedBoots = 2
+
+// bootState is the marker's content.
+type bootState struct {
+	Version  string `json:"version"`  // the version installed by the update
+	Previous string `json:"previous"` // the version in .old
+	Attempts int    `json:"attempts"` // starts without reaching bootOK
+}
+
+func bootMarker(exe string) string { return exe + ".boot" }
+
+// writeBootMarker arms the check for a freshly installed version.
+func writeBootMarker(exe, newVersion string) error {
+	data, _ := json.Marshal(bootState{Version: newVersion, Previous: version})
+	return os.WriteFile(bootMarker(exe), data, 0o644)
+}
+
+// checkBoot runs at start, after the config is loaded. It reports whether it
+// rolled back to the previous binary, which the caller should then launch
+// in place of this process.
+func checkBoot() (rolledBack bool) {
+	exe, err := executable()
+	if err != nil {
+		return false
+	}
+	data, err := os.ReadFile(bootMarker(exe))
+	if errors.Is(err, os.ErrNotExist) {
+		return false
+	}
+	var st bootState
+	if err == nil {
+		err = json.Unmarshal(data, &st)
+	}
+	if err != nil {
+		log.Printf("boot check: %v, discarding marker", err)
+		_ = os.Remove(bootMarker(exe))
+		return false
+	}
+
+	// A marker from another version means the exe was replaced by hand.
+	if v, ok := parseSemver(st.Version); !ok || !sameVersion(v, version) {
+		_ = os.Remove(bootMarker(exe))
+		return false
+	}
+
+	if st.Attempts >= maxFailedBoots {
+		if err := rollback(exe, st); err != nil {
+			log.Printf("boot check: v%s failed to start %d times, rollback failed: %v", st.Version, st.Attempts, err)
+			return false
+		}
+		log.Printf("boot check: v%s failed to start %d times, rolled back to %s", st.Version, st.Attempts, st.Previous)
+		return true
+	}
+
+	st.Attempts++
+	data, _ = json.Marshal(st)
+	if err := os.WriteFile(bootMarker(exe), data, 0o644); err != nil {
+		log.Printf("boot check: %v", err)
+	}
+	return false
+}
+
+func sameVersion(v semver, s string) bool {
+	w, ok := parseSemver(s)
+	return ok && v.compare(w) == 0
+}
+
+// rollback swaps the previous binary back in, keeping the failed one as
+// .failed, and adds the failed version to UpdateSkip so it isn't downloaded
+// again.
+func rollback(exe string, st bootState) error {
+	old, failed := exe+".old", exe+".failed"
+	if _, err := os.Stat(old); err != nil {
+		return err
+	}
+	_ = os.Remove(failed)
+	// Windows allows renaming a running exe but not overwriting it.
+	if err := os.Rename(exe, failed); err != nil {
+		return fmt.Errorf("rename exe to .failed: %w", err)
+	}
+	if err := os.Rename(old, exe); err != nil {
+		_ = os.Rename(failed, exe)
+		return fmt.Errorf("rename .old to exe: %w", err)
+	}
+	_ = os.Remove(bootMarker(exe))
+
+	v, _ := parseSemver(st.Version) // checked by checkBoot
+	updateConfig(func(c *config) {
+		if !slices.ContainsFunc(c.UpdateSkip, func(s string) bool { return sameVersion(v, s) }) {
+			c.UpdateSkip = append(c.UpdateSkip, st.Version)
+		}
+	})
+	return nil
+}
+
+// bootOK marks this start as healthy, once the tray is up. Only then is
+// the previous binary deleted. A marker for another version is left alone:
+// it belongs to an update applied since this process started.
+func bootOK() {
+	exe, err := executable()
+	if err != nil {
+		return
+	}
+	var st bootState
+	if data, err := os.ReadFile(bootMarker(exe)); err == nil && json.Unmarshal(data, &st) == nil {
+		if v, ok := parseSemver(st.Version); ok && sameVersion(v, version) {
+			if err := os.Remove(bootMarker(exe)); err == nil {
+				log.Printf("boot check: v%s started fine", version)
+			}
+		}
+	}
+	cleanOldBinary()
+}
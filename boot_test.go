@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// useUpdatedExe sets up an install just updated from 1.4.0 to 1.5.0 and
+// running the new version.
+func useUpdatedExe(t *testing.T) (exe string) {
+	t.Helper()
+	dir := t.TempDir()
+	exe = filepath.Join(dir, "monibright.exe")
+	_ = os.WriteFile(exe, []byte("new"), 0o755)
+	_ = os.WriteFile(exe+".old", []byte("old"), 0o755)
+
+	prevExe, prevVersion, prevCfg, prevDataDir := executable, version, cfg, dataDir
+	t.Cleanup(func() {
+		executable, version, cfg, dataDir = prevExe, prevVersion, prevCfg, prevDataDir
+	})
+	executable = func() (string, error) { return exe, nil }
+	dataDir = t.TempDir()
+	cfg = config{}
+
+	version = "v1.4.0"
+	if err := writeBootMarker(exe, "1.5.0"); err != nil {
+		t.Fatal(err)
+	}
+	version = "v1.5.0"
+	return exe
+}
+
+func readBootState(t *testing.T, exe string) bootState {
+	t.Helper()
+	var st bootState
+	data, err := os.ReadFile(bootMarker(exe))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &st); err != nil {
+		t.Fatal(err)
+	}
+	return st
+}
+
+func TestBootRollback(t *testing.T) {
+	exe := useUpdatedExe(t)
+
+	for i := 1; i <= maxFailedBoots; i++ {
+		if checkBoot() {
+			t.Fatalf("rolled back on start %d", i)
+		}
+		if st := readBootState(t, exe); st.Attempts != i || st.Previous != "v1.4.0" {
+			t.Errorf("after start %d: %+v", i, st)
+		}
+		cleanOldBinary() // must keep .old while the check is pending
+	}
+	if !checkBoot() {
+		t.Fatal("no rollback after repeated failed starts")
+	}
+
+	if got, _ := os.ReadFile(exe); string(got) != "old" {
+		t.Errorf("exe content = %q, want the previous binary", got)
+	}
+	if got, _ := os.ReadFile(exe + ".failed"); string(got) != "new" {
+		t.Errorf(".failed content = %q, want the failed binary", got)
+	}
+	if _, err := os.Stat(bootMarker(exe)); !os.IsNotExist(err) {
+		t.Error("boot marker left behind")
+	}
+	if !slices.Equal(cfg.UpdateSkip, []string{"1.5.0"}) {
+		t.Errorf("update_skip = %q, want [1.5.0]", cfg.UpdateSkip)
+	}
+	var saved config
+	data, _ := os.ReadFile(configPath())
+	if err := json.Unmarshal(data, &saved); err != nil || !slices.Equal(saved.UpdateSkip, []string{"1.5.0"}) {
+		t.Errorf("saved update_skip = %q (%v)", saved.UpdateSkip, err)
+	}
+
+	// The restored version won't fetch the failed one again.
+	if r := selectRelease([]ghRelease{{TagName: "v1.5.0"}}, "v1.4.0"); r != nil {
+		t.Errorf("selectRelease offered %s after rollback", r.TagName)
+	}
+}
+
+func TestBootOK(t *testing.T) {
+	exe := useUpdatedExe(t)
+
+	if checkBoot() {
+		t.Fatal("rolled back on first start")
+	}
+	bootOK()
+
+	if _, err := os.Stat(bootMarker(exe)); !os.IsNotExist(err) {
+		t.Error("boot marker left behind")
+	}
+	if _, err := os.Stat(exe + ".old"); !os.IsNotExist(err) {
+		t.Error(".old kept after a healthy start")
+	}
+	if checkBoot() {
+		t.Error("rolled back after a healthy start")
+	}
+}
+
+func TestBootOKKeepsNewerMarker(t *testing.T) {
+	exe := useUpdatedExe(t)
+	version = "v1.4.0" // the old process, which just applied the update
+
+	bootOK()
+
+	if st := readBootState(t, exe); st.Version != "1.5.0" {
+		t.Errorf("boot marker = %+v, want the one for 1.5.0", st)
+	}
+	if _, err := os.Stat(exe + ".old"); err != nil {
+		t.Errorf(".old removed while the update's boot check is pending: %v", err)
+	}
+}
+
+func TestBootMarkerFromOtherVersion(t *testing.T) {
+	exe := useUpdatedExe(t)
+	version = "v1.6.0" // replaced by hand since the update
+
+	for range maxFailedBoots + 1 {
+		if checkBoot() {
+			t.Fatal("rolled back for another version's marker")
+		}
+	}
+	if _, err := os.Stat(bootMarker(exe)); !os.IsNotExist(err) {
+		t.Error("stale boot marker kept")
+	}
+	if got, _ := os.ReadFile(exe); string(got) != "new" {
+		t.Errorf("exe content = %q, want it untouched", got)
+	}
+}
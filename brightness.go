@@ -0,0 +1,494 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alex-vit/monibright/icon"
+	"github.com/alex-vit/monibright/monitor"
+	"github.com/energye/systray"
+)
+
+var (
+	backend monitor.Backend
+
+	// monitorsMu guards the current enumeration: allMonitors and their keys.
+	// Use monitorList to read it; a refresh replaces the slice, never edits
+	// it.
+	monitorsMu     sync.RWMutex
+	allMonitors    []monitor.Monitor
+	enumeratedKeys map[monitor.Monitor]string
+
+	// brightnessMu serializes brightness writes, and the re-enumerations
+	// they can trigger, between the slider, hotkeys, auto brightness,
+	// profiles and commands.
+	brightnessMu sync.Mutex
+)
+
+var errNoMonitors = errors.New("no usable monitors")
+
+// monitorState is the brightness of one monitor as last read or written.
+// Levels are percentages; Max is the monitor's raw VCP 0x10 maximum, which
+// isn't always 100.
+type monitorState struct {
+	Current int
+	Target  int
+	Min     int
+	Max     int
+}
+
+var (
+	monitorStatesMu sync.Mutex
+	monitorStates   = map[string]*monitorState{}
+
+	configSaveTimer *time.Timer // guarded by cfgMu
+)
+
+// monitorKey identifies a monitor across re-enumerations and restarts. It
+// keys per-monitor state and config. The EDID-based ID survives reordering,
+// port changes and driver updates; without an EDID, fall back to the
+// backend's name and enumeration index. Identical monitors without serial
+// numbers share an EDID ID, so the second and later ones in enumeration
+// order get a "#2", "#3"... suffix.
+func monitorKey(m monitor.Monitor) string {
+	monitorsMu.RLock()
+	key, ok := enumeratedKeys[m]
+	monitorsMu.RUnlock()
+	if ok {
+		return key
+	}
+	return baseMonitorKey(m)
+}
+
+func baseMonitorKey(m monitor.Monitor) string {
+	if e := monitor.Identify(m); e != nil {
+		return e.ID()
+	}
+	return m.Name() + "#" + m.ID()
+}
+
+// keysOf returns the key of each of monitors, numbering duplicates.
+func keysOf(monitors []monitor.Monitor) map[monitor.Monitor]string {
+	keys := make(map[monitor.Monitor]string, len(monitors))
+	seen := map[string]int{}
+	for _, m := range monitors {
+		key := baseMonitorKey(m)
+		seen[key]++
+		if n := seen[key]; n > 1 {
+			key += "#" + strconv.Itoa(n)
+		}
+		keys[m] = key
+	}
+	return keys
+}
+
+// setMonitors makes monitors the current enumeration.
+func setMonitors(monitors []monitor.Monitor) {
+	keys := keysOf(monitors)
+	monitorsMu.Lock()
+	allMonitors, enumeratedKeys = monitors, keys
+	monitorsMu.Unlock()
+}
+
+// monitorList returns the current enumeration.
+func monitorList() []monitor.Monitor {
+	monitorsMu.RLock()
+	defer monitorsMu.RUnlock()
+	return allMonitors
+}
+
+// stateFor returns the state of m, seeded from config on first use.
+// Callers must hold monitorStatesMu.
+func stateFor(m monitor.Monitor) *monitorState {
+	key := monitorKey(m)
+	st, ok := monitorStates[key]
+	if !ok {
+		st = &monitorState{Max: 100}
+		cfgMu.Lock()
+		mc, ok := cfg.Monitors[key]
+		cfgMu.Unlock()
+		if ok {
+			st.Current = mc.Brightness
+			st.Target = mc.Brightness
+		}
+		monitorStates[key] = st
+	}
+	return st
+}
+
+// monitorBrightness returns the last known brightness of m in percent.
+func monitorBrightness(m monitor.Monitor) int {
+	monitorStatesMu.Lock()
+	defer monitorStatesMu.Unlock()
+	return stateFor(m).Current
+}
+
+// readBrightness is getBrightness for callers not holding brightnessMu, so
+// the read can't use a handle a rescan is closing.
+func readBrightness(m monitor.Monitor) (int, error) {
+	brightnessMu.Lock()
+	defer brightnessMu.Unlock()
+	return getBrightness(m)
+}
+
+// getBrightness reads the brightness of m in percent and records it.
+// Callers hold brightnessMu.
+func getBrightness(m monitor.Monitor) (int, error) {
+	cur, maxValue, err := m.GetVCP(monitor.VCPBrightness)
+	if err != nil {
+		return 0, err
+	}
+	monitorStatesMu.Lock()
+	defer monitorStatesMu.Unlock()
+	st := stateFor(m)
+	if maxValue > 0 {
+		st.Max = maxValue
+	}
+	st.Current = rawToPercent(cur, st.Max)
+	return st.Current, nil
+}
+
+// writeBrightness sets m to level percent, scaled to its raw range.
+func writeBrightness(m monitor.Monitor, level int) error {
+	monitorStatesMu.Lock()
+	st := stateFor(m)
+	st.Target = level
+	raw := percentToRaw(level, st.Max)
+	monitorStatesMu.Unlock()
+
+	if err := m.SetVCP(monitor.VCPBrightness, raw); err != nil {
+		return err
+	}
+
+	monitorStatesMu.Lock()
+	st.Current = level
+	monitorStatesMu.Unlock()
+	rememberBrightness(m, level)
+	publishBrightness(m, level)
+	return nil
+}
+
+// brightnessRaw converts level percent to m's raw brightness range.
+func brightnessRaw(m monitor.Monitor, level int) int {
+	monitorStatesMu.Lock()
+	defer monitorStatesMu.Unlock()
+	return percentToRaw(level, stateFor(m).Max)
+}
+
+// recordBrightness notes that m was set to level percent by other means than
+// writeBrightness.
+func recordBrightness(m monitor.Monitor, level int) {
+	monitorStatesMu.Lock()
+	st := stateFor(m)
+	st.Target = level
+	st.Current = level
+	monitorStatesMu.Unlock()
+	rememberBrightness(m, level)
+	publishBrightness(m, level)
+}
+
+func rawToPercent(raw, maxValue int) int {
+	if maxValue <= 0 || maxValue == 100 {
+		return raw
+	}
+	return (raw*100 + maxValue/2) / maxValue
+}
+
+func percentToRaw(level, maxValue int) int {
+	if maxValue <= 0 || maxValue == 100 {
+		return level
+	}
+	return (level*maxValue + 50) / 100
+}
+
+// rememberBrightness stores a monitor's level in config. Saving is debounced
+// so dragging the slider doesn't rewrite the file on every step.
+func rememberBrightness(m monitor.Monitor, level int) {
+	key := monitorKey(m)
+	cfgMu.Lock()
+	defer cfgMu.Unlock()
+	if cfg.Monitors == nil {
+		cfg.Monitors = map[string]monitorConfig{}
+	}
+	mc := cfg.Monitors[key]
+	if mc.Brightness == level {
+		return
+	}
+	mc.Brightness = level
+	cfg.Monitors[key] = mc
+	if configSaveTimer != nil {
+		configSaveTimer.Stop()
+	}
+	configSaveTimer = time.AfterFunc(2*time.Second, saveConfig)
+}
+
+// flushConfig runs a pending debounced save now, for a process that exits
+// before the timer would fire.
+func flushConfig() {
+	cfgMu.Lock()
+	pending := configSaveTimer != nil && configSaveTimer.Stop()
+	configSaveTimer = nil
+	cfgMu.Unlock()
+	if pending {
+		saveConfig()
+	}
+}
+
+// trayMonitor returns the monitor the tray icon and slider reflect: the first
+// match for the tray_monitor setting, or the first monitor.
+func trayMonitor() monitor.Monitor {
+	monitors := monitorList()
+	if len(monitors) == 0 {
+		return nil
+	}
+	if sel := cfgSnapshot().TrayMonitor; sel != "" {
+		if ms := selectMonitors(sel); len(ms) > 0 {
+			return ms[0]
+		}
+	}
+	return monitors[0]
+}
+
+// updateIcon shows the tray monitor's brightness in the tray icon. With more
+// than one monitor the tooltip names the monitor and lists the others.
+func updateIcon() {
+	m := trayMonitor()
+	if m == nil {
+		return
+	}
+	level := monitorBrightness(m)
+	systray.SetIcon(icon.Generate(level))
+	monitors := monitorList()
+	if len(monitors) == 1 {
+		systray.SetTooltip(fmt.Sprintf("MoniBright — %d%%", level))
+		return
+	}
+	tip := fmt.Sprintf("MoniBright — %s %d%%", monitorLabel(m), level)
+	for _, other := range monitors {
+		if other != m {
+			tip += fmt.Sprintf("\n%s %d%%", monitorLabel(other), monitorBrightness(other))
+		}
+	}
+	systray.SetTooltip(tip)
+}
+
+// currentBrightness reads the brightness of the tray monitor.
+// DDC/CI handles go stale after monitor sleep/wake and return 0, so a zero
+// reading triggers one re-enumeration and retry.
+func currentBrightness() (int, error) {
+	brightnessMu.Lock()
+	defer brightnessMu.Unlock()
+	m := trayMonitor()
+	if m == nil {
+		return 0, errNoMonitors
+	}
+	current, err := getBrightness(m)
+	if err != nil {
+		return 0, err
+	}
+	log.Printf("GetBrightness: current=%d", current)
+
+	if current == 0 {
+		log.Printf("brightness=0 is suspicious, re-enumerating monitors")
+		if refreshMonitors() {
+			current, err = getBrightness(trayMonitor())
+			if err != nil {
+				return 0, fmt.Errorf("retry: %w", err)
+			}
+			log.Printf("GetBrightness retry: current=%d", current)
+		}
+	}
+	return current, nil
+}
+
+func refreshCheck() {
+	if _, err := currentBrightness(); err != nil {
+		log.Printf("GetBrightness failed: %v", err)
+		return
+	}
+	updateIcon()
+}
+
+// refreshMonitors re-enumerates the monitors, closing handles that went
+// away. Callers hold brightnessMu, so no write is using an old handle.
+func refreshMonitors() bool {
+	monitors, err := backend.Enumerate()
+	if err != nil {
+		log.Printf("re-enumerate failed: %v", err)
+		return false
+	}
+	if len(monitors) == 0 {
+		log.Printf("re-enumerate: no usable monitors")
+		return false
+	}
+	old := monitorList()
+	changed := !slices.Equal(monitorKeys(old), monitorKeys(monitors))
+	for _, m := range old {
+		if !slices.Contains(monitors, m) {
+			_ = m.Close()
+		}
+	}
+	setMonitors(monitors)
+	log.Printf("re-enumerated %d physical monitors", len(monitors))
+	publishMonitors(changed)
+	return true
+}
+
+// rescanMonitors re-enumerates after a display change and reports whether
+// monitors were plugged in or out.
+func rescanMonitors() (changed bool) {
+	brightnessMu.Lock()
+	defer brightnessMu.Unlock()
+	before := monitorKeys(monitorList())
+	if !refreshMonitors() {
+		return false
+	}
+	if changed = !slices.Equal(before, monitorKeys(monitorList())); changed {
+		log.Printf("monitors changed: %v -> %v", before, monitorKeys(monitorList()))
+		updateIcon()
+	}
+	return changed
+}
+
+// monitorKeys returns the keys of monitors, sorted.
+func monitorKeys(monitors []monitor.Monitor) []string {
+	keys := slices.Collect(maps.Values(keysOf(monitors)))
+	slices.Sort(keys)
+	return keys
+}
+
+// selectMonitors returns the monitors matching sel: all monitors when sel is
+// empty, otherwise those whose ID or key equals sel or whose name or model
+// contains it (case-insensitive).
+func selectMonitors(sel string) []monitor.Monitor {
+	if sel == "" {
+		return monitorList()
+	}
+	needle := strings.ToLower(sel)
+	var out []monitor.Monitor
+	for _, m := range monitorList() {
+		model := ""
+		if caps := capsFor(m); caps != nil {
+			model = caps.Model
+		}
+		if e := monitor.Identify(m); e != nil {
+			model += "\n" + e.DisplayName()
+		}
+		if m.ID() == sel || monitorKey(m) == sel ||
+			strings.Contains(strings.ToLower(m.Name()), needle) ||
+			strings.Contains(strings.ToLower(model), needle) {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// resolveTarget returns the monitors a brightness target refers to: "" or
+// "all" for every monitor, the name of a monitor group from config, or a
+// single-monitor selector (see selectMonitors).
+func resolveTarget(target string) ([]monitor.Monitor, error) {
+	if target == "" || strings.EqualFold(target, "all") {
+		return monitorList(), nil
+	}
+	for name, members := range cfgSnapshot().MonitorGroups {
+		if !strings.EqualFold(name, target) {
+			continue
+		}
+		var out []monitor.Monitor
+		for _, sel := range members {
+			for _, m := range selectMonitors(sel) {
+				if !slices.Contains(out, m) {
+					out = append(out, m)
+				}
+			}
+		}
+		if len(out) == 0 {
+			return nil, fmt.Errorf("monitor group %q matches no monitors", target)
+		}
+		return out, nil
+	}
+	if ms := selectMonitors(target); len(ms) > 0 {
+		return ms, nil
+	}
+	return nil, fmt.Errorf("no monitor matches %q", target)
+}
+
+// setBrightness sets every monitor to level.
+func setBrightness(level int) {
+	log.Printf("setting brightness to %d%%", level)
+	applyBrightness(monitorList(), level)
+}
+
+// setBrightnessFor sets the monitors selected by target (see resolveTarget)
+// to level.
+func setBrightnessFor(target string, level int) error {
+	targets, err := resolveTarget(target)
+	if err != nil {
+		return err
+	}
+	log.Printf("setting brightness of %q to %d%%", target, level)
+	applyBrightness(targets, level)
+	return nil
+}
+
+func applyBrightness(targets []monitor.Monitor, level int) {
+	brightnessMu.Lock()
+	defer brightnessMu.Unlock()
+	applyBrightnessLocked(targets, level)
+}
+
+// applyBrightnessLocked is applyBrightness for callers holding brightnessMu.
+func applyBrightnessLocked(targets []monitor.Monitor, level int) {
+	keys := make([]string, 0, len(targets))
+	for _, m := range targets {
+		keys = append(keys, monitorKey(m))
+	}
+
+	// Targets are tracked by key because a refresh replaces the handles.
+	setAll := func() {
+		for _, m := range monitorList() {
+			if !slices.Contains(keys, monitorKey(m)) || !supportsVCP(m, monitor.VCPBrightness) {
+				continue
+			}
+			if err := writeBrightness(m, level); err != nil {
+				log.Printf("monitor %s: SetBrightness(%d) error: %v", monitorLabel(m), level, err)
+			} else {
+				log.Printf("monitor %s: SetBrightness(%d) ok", monitorLabel(m), level)
+			}
+		}
+	}
+
+	setAll()
+
+	// Verify the write took effect. Stale DDC/CI handles after sleep/wake
+	// silently fail: SetBrightness returns nil but the monitor doesn't change.
+	// Re-enumerate for fresh handles and retry.
+	if i := slices.IndexFunc(targets, func(m monitor.Monitor) bool {
+		return supportsVCP(m, monitor.VCPBrightness)
+	}); i >= 0 {
+		cur, err := getBrightness(targets[i])
+		log.Printf("post-set verify: current=%d expected=%d err=%v", cur, level, err)
+		diff := cur - level
+		if diff < 0 {
+			diff = -diff
+		}
+		if err != nil || diff > 5 {
+			log.Printf("stale handle detected, refreshing monitors and retrying")
+			if refreshMonitors() {
+				setAll()
+			}
+		}
+	}
+
+	updateIcon()
+	if m := trayMonitor(); m != nil && slices.Contains(keys, monitorKey(m)) {
+		syncSlider(level)
+	}
+}
@@ -0,0 +1,389 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/alex-vit/monibright/monitor"
+)
+
+// useFakeBackend installs a fake backend with the given displays and
+// enumerates it, restoring the previous globals when the test ends.
+func useFakeBackend(t *testing.T, displays ...*monitor.FakeMonitor) *monitor.Fake {
+	t.Helper()
+	prevBackend, prevMonitors, prevDataDir := backend, allMonitors, dataDir
+	dataDir = t.TempDir()
+	t.Cleanup(func() {
+		backend, dataDir = prevBackend, prevDataDir
+		setMonitors(prevMonitors)
+		if configSaveTimer != nil {
+			configSaveTimer.Stop()
+		}
+		monitorStates = map[string]*monitorState{}
+		cfg.Monitors = nil
+		cfg.MonitorGroups = nil
+		cfg.TrayMonitor = ""
+	})
+
+	fake := &monitor.Fake{Displays: displays}
+	backend = fake
+	monitors, err := fake.Enumerate()
+	if err != nil {
+		t.Fatalf("Enumerate: %v", err)
+	}
+	setMonitors(monitors)
+	return fake
+}
+
+func TestSetBrightness(t *testing.T) {
+	a, b := monitor.NewFakeMonitor(0, 50), monitor.NewFakeMonitor(1, 50)
+	fake := useFakeBackend(t, a, b)
+
+	setBrightness(70)
+
+	for _, m := range []*monitor.FakeMonitor{a, b} {
+		if got := m.VCP[monitor.VCPBrightness]; got != 70 {
+			t.Errorf("monitor %d brightness = %d, want 70", m.Index, got)
+		}
+		if len(m.Sets) != 1 {
+			t.Errorf("monitor %d: %d writes, want 1", m.Index, len(m.Sets))
+		}
+	}
+	if fake.Enumerations != 1 {
+		t.Errorf("enumerations = %d, want 1 (no refresh)", fake.Enumerations)
+	}
+}
+
+func TestSetBrightnessStaleHandleRetry(t *testing.T) {
+	a, b := monitor.NewFakeMonitor(0, 50), monitor.NewFakeMonitor(1, 50)
+	fake := useFakeBackend(t, a, b)
+	a.Stale = true
+	b.Stale = true
+
+	setBrightness(70)
+
+	if fake.Enumerations != 2 {
+		t.Errorf("enumerations = %d, want 2 (one refresh)", fake.Enumerations)
+	}
+	for _, m := range []*monitor.FakeMonitor{a, b} {
+		if got := m.VCP[monitor.VCPBrightness]; got != 70 {
+			t.Errorf("monitor %d brightness = %d after retry, want 70", m.Index, got)
+		}
+	}
+}
+
+func TestSetBrightnessVerifyError(t *testing.T) {
+	a := monitor.NewFakeMonitor(0, 50)
+	fake := useFakeBackend(t, a)
+	a.GetErr = errors.New("i2c timeout")
+
+	setBrightness(40)
+
+	if fake.Enumerations != 2 {
+		t.Errorf("enumerations = %d, want 2 (refresh on verify error)", fake.Enumerations)
+	}
+	if len(a.Sets) != 2 {
+		t.Errorf("writes = %d, want 2 (initial + retry)", len(a.Sets))
+	}
+}
+
+func TestSetBrightnessRefreshFails(t *testing.T) {
+	a := monitor.NewFakeMonitor(0, 50)
+	fake := useFakeBackend(t, a)
+	a.Stale = true
+	fake.Err = errors.New("enumeration failed")
+
+	setBrightness(70)
+
+	if len(allMonitors) != 1 || allMonitors[0] != monitor.Monitor(a) {
+		t.Errorf("allMonitors replaced after failed refresh")
+	}
+	if a.Closed {
+		t.Errorf("monitor closed after failed refresh")
+	}
+}
+
+func TestCurrentBrightnessStaleRetry(t *testing.T) {
+	a := monitor.NewFakeMonitor(0, 80)
+	fake := useFakeBackend(t, a)
+	a.Stale = true
+
+	got, err := currentBrightness()
+	if err != nil {
+		t.Fatalf("currentBrightness: %v", err)
+	}
+	if got != 80 {
+		t.Errorf("currentBrightness = %d, want 80", got)
+	}
+	if fake.Enumerations != 2 {
+		t.Errorf("enumerations = %d, want 2", fake.Enumerations)
+	}
+}
+
+func TestCurrentBrightnessNoMonitors(t *testing.T) {
+	useFakeBackend(t)
+	if _, err := currentBrightness(); !errors.Is(err, errNoMonitors) {
+		t.Errorf("currentBrightness err = %v, want errNoMonitors", err)
+	}
+}
+
+func TestRescanMonitors(t *testing.T) {
+	a, b := monitor.NewFakeMonitor(0, 50), monitor.NewFakeMonitor(1, 50)
+	fake := useFakeBackend(t, a)
+	events := useEvents(t)
+
+	if rescanMonitors() {
+		t.Error("rescan with the same monitor reported a change")
+	}
+	fake.Displays = []*monitor.FakeMonitor{a, b}
+	if !rescanMonitors() {
+		t.Error("plugged-in monitor not reported")
+	}
+	if got := len(monitorList()); got != 2 {
+		t.Errorf("%d monitors after plugging one in, want 2", got)
+	}
+
+	var changed []bool
+	for _, ev := range drain(events) {
+		var mons struct {
+			Changed bool `json:"changed"`
+		}
+		if ev.Type == "monitors" && json.Unmarshal(ev.Data, &mons) == nil {
+			changed = append(changed, mons.Changed)
+		}
+	}
+	if !slices.Equal(changed, []bool{false, true}) {
+		t.Errorf("monitors events changed = %v, want [false true]", changed)
+	}
+}
+
+func TestRefreshMonitorsClosesDroppedHandles(t *testing.T) {
+	a, b := monitor.NewFakeMonitor(0, 50), monitor.NewFakeMonitor(1, 50)
+	fake := useFakeBackend(t, a, b)
+	fake.Displays = []*monitor.FakeMonitor{a}
+
+	if !refreshMonitors() {
+		t.Fatal("refreshMonitors failed")
+	}
+	if len(allMonitors) != 1 {
+		t.Errorf("len(allMonitors) = %d, want 1", len(allMonitors))
+	}
+	if a.Closed {
+		t.Error("still-present monitor was closed")
+	}
+	if !b.Closed {
+		t.Error("unplugged monitor was not closed")
+	}
+}
+
+func TestSetBrightnessSkipsUnsupported(t *testing.T) {
+	a, b := monitor.NewFakeMonitor(0, 50), monitor.NewFakeMonitor(1, 50)
+	b.Caps = "(prot(monitor)type(LCD)model(TV)vcp(02 60(11 12)))"
+	a.Caps = "(prot(monitor)type(LCD)model(U2415)vcp(02 10 12))"
+	useFakeBackend(t, a, b)
+	t.Cleanup(func() { monitorCaps = map[string]*monitor.Capabilities{} })
+	loadCapabilities(allMonitors)
+
+	setBrightness(70)
+
+	if len(a.Sets) != 1 {
+		t.Errorf("monitor 0: %d writes, want 1", len(a.Sets))
+	}
+	if len(b.Sets) != 0 {
+		t.Errorf("monitor 1 lacks VCP 0x10 but got %d writes", len(b.Sets))
+	}
+}
+
+func TestSetBrightnessForTargets(t *testing.T) {
+	left, right, tv := monitor.NewFakeMonitor(0, 50), monitor.NewFakeMonitor(1, 50), monitor.NewFakeMonitor(2, 50)
+	left.Caps = "(model(U2722D)vcp(10))"
+	right.Caps = "(model(P2419H)vcp(10))"
+	tv.Caps = "(model(LG TV)vcp(10))"
+	useFakeBackend(t, left, right, tv)
+	withCapabilities(t)
+	cfg.MonitorGroups = map[string][]string{"Desk": {"U2722D", "p2419"}}
+
+	if err := setBrightnessFor("lg tv", 30); err != nil {
+		t.Fatal(err)
+	}
+	if err := setBrightnessFor("desk", 80); err != nil {
+		t.Fatal(err)
+	}
+	want := map[*monitor.FakeMonitor]int{left: 80, right: 80, tv: 30}
+	for m, level := range want {
+		if got := m.VCP[monitor.VCPBrightness]; got != level {
+			t.Errorf("monitor %d brightness = %d, want %d", m.Index, got, level)
+		}
+		if got := cfg.Monitors[monitorKey(m)].Brightness; got != level {
+			t.Errorf("monitor %d saved brightness = %d, want %d", m.Index, got, level)
+		}
+	}
+
+	if err := setBrightnessFor("all", 60); err != nil {
+		t.Fatal(err)
+	}
+	for m := range want {
+		if got := m.VCP[monitor.VCPBrightness]; got != 60 {
+			t.Errorf("monitor %d brightness = %d after all, want 60", m.Index, got)
+		}
+	}
+	if err := setBrightnessFor("nonexistent", 10); err == nil {
+		t.Error("setBrightnessFor(nonexistent) succeeded")
+	}
+}
+
+func TestSetBrightnessScalesToMonitorRange(t *testing.T) {
+	m := monitor.NewFakeMonitor(0, 0)
+	m.Max = map[byte]int{monitor.VCPBrightness: 255}
+	useFakeBackend(t, m)
+	if _, err := currentBrightness(); err != nil {
+		t.Fatal(err)
+	}
+
+	setBrightness(50)
+
+	if got := m.VCP[monitor.VCPBrightness]; got != 128 {
+		t.Errorf("raw brightness = %d, want 128 (50%% of 255)", got)
+	}
+	if got := monitorBrightness(m); got != 50 {
+		t.Errorf("state brightness = %d%%, want 50%%", got)
+	}
+}
+
+func TestTrayMonitor(t *testing.T) {
+	a, b := monitor.NewFakeMonitor(0, 20), monitor.NewFakeMonitor(1, 90)
+	b.Description = "DELL U2722D"
+	useFakeBackend(t, a, b)
+
+	if got := trayMonitor(); got != monitor.Monitor(a) {
+		t.Errorf("default tray monitor = %s, want first", got.ID())
+	}
+	cfg.TrayMonitor = "u2722d"
+	if got := trayMonitor(); got != monitor.Monitor(b) {
+		t.Errorf("tray monitor = %s, want 1", got.ID())
+	}
+	cur, err := currentBrightness()
+	if err != nil || cur != 90 {
+		t.Errorf("currentBrightness = %d, %v; want 90 from the tray monitor", cur, err)
+	}
+}
+
+func TestMonitorStateSeededFromConfig(t *testing.T) {
+	a := monitor.NewFakeMonitor(0, 50)
+	useFakeBackend(t, a)
+	cfg.Monitors = map[string]monitorConfig{monitorKey(a): {Brightness: 65}}
+
+	if got := monitorBrightness(a); got != 65 {
+		t.Errorf("monitorBrightness = %d, want 65 from config", got)
+	}
+}
+
+func TestMonitorKeyFromEDID(t *testing.T) {
+	dell, err := os.ReadFile(filepath.Join("monitor", "testdata", "edid", "dell-u2722d.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Enumerated second this time, e.g. after swapping ports.
+	a, b := monitor.NewFakeMonitor(0, 20), monitor.NewFakeMonitor(1, 50)
+	b.RawEDID = dell
+	useFakeBackend(t, a, b)
+	cfg.Monitors = map[string]monitorConfig{"DEL-4277-7MT0182C2XYL": {Brightness: 65}}
+
+	if got := monitorKey(b); got != "DEL-4277-7MT0182C2XYL" {
+		t.Errorf("monitorKey = %q", got)
+	}
+	if got := monitorKey(a); got != "Fake Monitor 0#0" {
+		t.Errorf("monitorKey without EDID = %q", got)
+	}
+	if got := monitorBrightness(b); got != 65 {
+		t.Errorf("monitorBrightness = %d, want 65 from config", got)
+	}
+	if got := monitorLabel(b); got != "DELL U2722D #7MT0182C2XYL" {
+		t.Errorf("monitorLabel = %q", got)
+	}
+	if got := selectMonitors("u2722d"); len(got) != 1 || got[0] != monitor.Monitor(b) {
+		t.Errorf("selectMonitors(u2722d) = %v, want the Dell", got)
+	}
+}
+
+func TestIdenticalMonitorsGetOwnKeys(t *testing.T) {
+	panel, err := os.ReadFile(filepath.Join("monitor", "testdata", "edid", "auo-laptop-panel.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Two of the same model, neither with a serial number.
+	a, b := monitor.NewFakeMonitor(0, 20), monitor.NewFakeMonitor(1, 50)
+	a.RawEDID, b.RawEDID = panel, panel
+	fake := useFakeBackend(t, a, b)
+
+	if ka, kb := monitorKey(a), monitorKey(b); ka != "AUO-243D" || kb != "AUO-243D#2" {
+		t.Fatalf("keys = %q, %q, want AUO-243D and AUO-243D#2", ka, kb)
+	}
+	if err := setBrightnessFor("AUO-243D#2", 70); err != nil {
+		t.Fatal(err)
+	}
+	if a.VCP[monitor.VCPBrightness] != 20 || b.VCP[monitor.VCPBrightness] != 70 {
+		t.Errorf("brightness = %d, %d, want 20, 70", a.VCP[monitor.VCPBrightness], b.VCP[monitor.VCPBrightness])
+	}
+	if got := cfg.Monitors["AUO-243D#2"].Brightness; got != 70 {
+		t.Errorf("saved brightness of the second panel = %d, want 70", got)
+	}
+	if _, ok := cfg.Monitors["AUO-243D"]; ok {
+		t.Error("the first panel's config was written to")
+	}
+
+	// Fresh handles for the same panels keep their keys.
+	fake.Displays[0].Stale = true
+	if !refreshMonitors() {
+		t.Fatal("refreshMonitors failed")
+	}
+	if got := monitorKeys(allMonitors); !slices.Equal(got, []string{"AUO-243D", "AUO-243D#2"}) {
+		t.Errorf("keys after re-enumeration = %q", got)
+	}
+}
+
+func TestSaveConfigWhileRemembering(t *testing.T) {
+	a, b := monitor.NewFakeMonitor(0, 50), monitor.NewFakeMonitor(1, 50)
+	useFakeBackend(t, a, b)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for level := range 100 {
+			rememberBrightness(allMonitors[level%2], level)
+		}
+	}()
+	for range 20 {
+		saveConfig()
+	}
+	<-done
+}
+
+func TestReadConfigWhileUpdating(t *testing.T) {
+	useFakeBackend(t, monitor.NewFakeMonitor(0, 50))
+	prev := cfg
+	t.Cleanup(func() { cfg = prev })
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := range 50 {
+			updateConfig(func(c *config) {
+				c.TrayMonitor = ""
+				c.DayBrightness = 50 + i
+				c.Latitude = float64(i)
+			})
+		}
+	}()
+	for range 50 {
+		trayMonitor()
+		_, _ = resolveTarget("all")
+		scheduledLevels(time.Now(), sunScheduleAt(time.Now()))
+	}
+	<-done
+}
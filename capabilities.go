@@ -0,0 +1,55 @@
+package main
+
+import (
+	"log"
+	"sync"
+
+	"github.com/alex-vit/monibright/monitor"
+)
+
+var (
+	monitorCapsMu sync.Mutex
+	monitorCaps   = map[string]*monitor.Capabilities{}
+)
+
+// loadCapabilities reads and caches the capabilities of every monitor not yet
+// cached. A DDC/CI capabilities read takes around a second per monitor, so
+// call it from a background goroutine.
+func loadCapabilities(monitors []monitor.Monitor) {
+	for _, m := range monitors {
+		key := monitorKey(m)
+		monitorCapsMu.Lock()
+		_, cached := monitorCaps[key]
+		monitorCapsMu.Unlock()
+		if cached {
+			continue
+		}
+
+		caps, err := monitor.ReadCapabilities(m)
+		if err != nil {
+			log.Printf("monitor %s: capabilities: %v", monitorLabel(m), err)
+			continue
+		}
+		log.Printf("monitor %s: model=%q mccs=%s vcp codes=%d",
+			monitorLabel(m), caps.Model, caps.MCCSVersion, len(caps.VCP))
+
+		monitorCapsMu.Lock()
+		monitorCaps[key] = caps
+		monitorCapsMu.Unlock()
+	}
+}
+
+// capsFor returns the cached capabilities of m, or nil if unknown.
+func capsFor(m monitor.Monitor) *monitor.Capabilities {
+	monitorCapsMu.Lock()
+	defer monitorCapsMu.Unlock()
+	return monitorCaps[monitorKey(m)]
+}
+
+// supportsVCP reports whether m supports a VCP code. Monitors with unknown
+// capabilities (not read yet, or unreadable) are assumed to support
+// everything, since many monitors handle VCP codes they don't advertise.
+func supportsVCP(m monitor.Monitor, code byte) bool {
+	caps := capsFor(m)
+	return caps == nil || caps.Supports(code)
+}
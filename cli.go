@@ -0,0 +1,348 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/alex-vit/monibright/monitor"
+)
+
+const cliUsage = `usage: monibright <command> [--json] [--monitor MONITOR]
+
+commands:
+  get                      show brightness and color temperature
+  set N | +N | -N          set brightness to N%, or change it by N
+  temp K                   set the color temperature (3500-6500)
+  profile NAME             apply a profile from config.json
+  auto on|off              turn auto color temperature on or off
+  auto brightness on|off   turn auto brightness on or off
+  list-monitors            list monitors with their IDs and inputs
+  show                     open the running instance's brightness slider
+
+MONITOR is a monitor ID, name, model or group from config.json.
+Commands go to the running MoniBright when there is one.
+`
+
+// cliRequest is one command-line command, run locally or by the running
+// instance.
+type cliRequest struct {
+	Command  string `json:"command"`           // get, set, temp, profile, auto, list-monitors, show
+	Monitor  string `json:"monitor,omitempty"` // target of get and set, see resolveTarget
+	Level    int    `json:"level,omitempty"`   // set: percent, or the change if Relative
+	Relative bool   `json:"relative,omitempty"`
+	Temp     int    `json:"temp,omitempty"`    // temp: kelvin
+	Profile  string `json:"profile,omitempty"` // profile: name
+	Auto     string `json:"auto,omitempty"`    // auto: "color" or "brightness"
+	On       bool   `json:"on,omitempty"`      // auto: turn on
+}
+
+// cliReply is the result of a cliRequest: an error, or the state after it
+// ran.
+type cliReply struct {
+	Error  string     `json:"error,omitempty"`
+	Status *cliStatus `json:"status,omitempty"`
+}
+
+type cliStatus struct {
+	Monitors       []monitorStatus `json:"monitors"`
+	Temp           int             `json:"temp,omitempty"` // only known to the running instance
+	AutoColor      bool            `json:"auto_color"`
+	AutoBrightness bool            `json:"auto_brightness"`
+}
+
+type monitorStatus struct {
+	ID         string   `json:"id"`
+	Key        string   `json:"key"`
+	Name       string   `json:"name"`
+	Brightness int      `json:"brightness"`
+	Inputs     []string `json:"inputs,omitempty"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// errNeedsInstance is returned for commands that only the running instance
+// can carry out.
+var errNeedsInstance = errors.New("MoniBright is not running")
+
+// parseCLI parses command-line arguments into a request.
+func parseCLI(args []string) (req cliRequest, asJSON bool, err error) {
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		switch a := args[i]; {
+		case a == "--json":
+			asJSON = true
+		case a == "--monitor" || a == "-m":
+			if i+1 == len(args) {
+				return req, false, fmt.Errorf("%s needs a value", a)
+			}
+			i++
+			req.Monitor = args[i]
+		case strings.HasPrefix(a, "--monitor="):
+			req.Monitor = strings.TrimPrefix(a, "--monitor=")
+		default:
+			rest = append(rest, a)
+		}
+	}
+	if len(rest) == 0 {
+		return req, false, errors.New("no command")
+	}
+	req.Command, rest = rest[0], rest[1:]
+	nargs := func(n int) error {
+		if len(rest) != n {
+			return fmt.Errorf("%s takes %d argument(s)", req.Command, n)
+		}
+		return nil
+	}
+
+	switch req.Command {
+	case "get", "list-monitors", "show":
+		err = nargs(0)
+	case "set":
+		if err = nargs(1); err != nil {
+			break
+		}
+		arg := rest[0]
+		req.Relative = strings.HasPrefix(arg, "+") || strings.HasPrefix(arg, "-")
+		req.Level, err = strconv.Atoi(arg)
+		if err != nil || (!req.Relative && (req.Level < 0 || req.Level > 100)) {
+			err = fmt.Errorf("brightness must be 0-100 or a change like +10, got %q", arg)
+		}
+	case "temp":
+		if err = nargs(1); err != nil {
+			break
+		}
+		req.Temp, err = strconv.Atoi(strings.TrimSuffix(strings.ToUpper(rest[0]), "K"))
+		if err != nil || req.Temp < tempMin || req.Temp > tempMax {
+			err = fmt.Errorf("color temperature must be %d-%d, got %q", tempMin, tempMax, rest[0])
+		}
+	case "profile":
+		if len(rest) == 0 {
+			err = errors.New("profile needs a name")
+			break
+		}
+		req.Profile = strings.Join(rest, " ")
+	case "auto":
+		req.Auto = "color"
+		if len(rest) == 2 && rest[0] == "brightness" {
+			req.Auto, rest = "brightness", rest[1:]
+		}
+		if err = nargs(1); err != nil {
+			break
+		}
+		switch rest[0] {
+		case "on":
+			req.On = true
+		case "off":
+		default:
+			err = fmt.Errorf("auto takes on or off, got %q", rest[0])
+		}
+	default:
+		err = fmt.Errorf("unknown command %q", req.Command)
+	}
+	return req, asJSON, err
+}
+
+// runCLI runs a command line and prints the result, returning the exit code.
+// The command goes to the running instance if there is one; otherwise setup
+// prepares this process (config, monitors) to run it locally.
+func runCLI(args []string, stdout, stderr io.Writer, setup func() error) int {
+	req, asJSON, err := parseCLI(args)
+	if err != nil {
+		fmt.Fprintf(stderr, "monibright: %v\n\n%s", err, cliUsage)
+		return 2
+	}
+
+	reply, err := forwardRequest(req)
+	if errors.Is(err, errNeedsInstance) {
+		if err := setup(); err != nil {
+			reply = cliReply{Error: err.Error()}
+		} else {
+			reply = handleRequest(req, true)
+			flushConfig()
+		}
+	} else if err != nil {
+		reply = cliReply{Error: err.Error()}
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(reply)
+	} else if reply.Error != "" {
+		fmt.Fprintf(stderr, "monibright: %s\n", reply.Error)
+	} else {
+		printStatus(stdout, req.Command, reply.Status)
+	}
+	if reply.Error != "" {
+		return 1
+	}
+	return 0
+}
+
+// printStatus prints the human-readable result of a command. Commands that
+// change something print nothing.
+func printStatus(w io.Writer, command string, st *cliStatus) {
+	if st == nil {
+		return
+	}
+	switch command {
+	case "get":
+		for _, m := range st.Monitors {
+			switch {
+			case m.Error != "":
+				fmt.Fprintf(w, "%s: %s\n", m.Name, m.Error)
+			case len(st.Monitors) == 1:
+				fmt.Fprintf(w, "%d%%\n", m.Brightness)
+			default:
+				fmt.Fprintf(w, "%s: %d%%\n", m.Name, m.Brightness)
+			}
+		}
+		if st.Temp > 0 {
+			auto := ""
+			if st.AutoColor {
+				auto = " (auto)"
+			}
+			fmt.Fprintf(w, "color temp: %dK%s\n", st.Temp, auto)
+		}
+		if st.AutoBrightness {
+			fmt.Fprintln(w, "auto brightness: on")
+		}
+	case "list-monitors":
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tNAME\tBRIGHTNESS\tINPUTS\tKEY")
+		for _, m := range st.Monitors {
+			level := strconv.Itoa(m.Brightness) + "%"
+			if m.Error != "" {
+				level = "?"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", m.ID, m.Name, level, strings.Join(m.Inputs, ","), m.Key)
+		}
+		_ = tw.Flush()
+	}
+}
+
+// commandMu runs one command at a time, whether it came from the command
+// line, the pipe, the HTTP API or MQTT, so a check like "is auto color on"
+// still holds when the command acts on it.
+var commandMu sync.Mutex
+
+// handleRequest carries out req in this process. local is set when there is
+// no running instance, so nothing holds the color temperature.
+func handleRequest(req cliRequest, local bool) cliReply {
+	commandMu.Lock()
+	defer commandMu.Unlock()
+	log.Printf("cli: %s", req.Command)
+	var err error
+	switch req.Command {
+	case "get", "list-monitors":
+	case "set":
+		err = cliSetBrightness(req)
+	case "temp":
+		if local {
+			err = errNeedsInstance
+			break
+		}
+		manualColorTemp(req.Temp, false)
+	case "profile":
+		err = applyProfile(req.Profile)
+	case "show":
+		if local {
+			err = errNeedsInstance
+			break
+		}
+		showSlider()
+	case "auto":
+		if local {
+			err = errNeedsInstance
+			break
+		}
+		if req.Auto == "brightness" {
+			setAutoBrightness(req.On)
+		} else {
+			setAutoColor(req.On)
+		}
+	default:
+		err = fmt.Errorf("unknown command %q", req.Command)
+	}
+	if err != nil {
+		return cliReply{Error: err.Error()}
+	}
+
+	sel := ""
+	if req.Command == "get" || req.Command == "set" {
+		sel = req.Monitor
+	}
+	st, err := currentStatus(sel, local)
+	if err != nil {
+		return cliReply{Error: err.Error()}
+	}
+	return cliReply{Status: st}
+}
+
+// cliSetBrightness sets or changes the brightness of the requested monitors.
+// Like a hotkey, it turns auto brightness off.
+func cliSetBrightness(req cliRequest) error {
+	targets, err := resolveTarget(req.Monitor)
+	if err != nil {
+		return err
+	}
+	if len(targets) == 0 {
+		return errNoMonitors
+	}
+	disengageAutoBrightness()
+	if !req.Relative {
+		log.Printf("cli: setting brightness of %q to %d%%", req.Monitor, req.Level)
+		applyBrightness(targets, req.Level)
+		return nil
+	}
+	brightnessMu.Lock()
+	defer brightnessMu.Unlock()
+	for _, m := range targets {
+		cur, err := getBrightness(m)
+		if err != nil {
+			return fmt.Errorf("monitor %s: %w", monitorLabel(m), err)
+		}
+		level := clamp(cur+req.Level, 0, 100)
+		log.Printf("cli: changing brightness of %s by %+d to %d%%", monitorLabel(m), req.Level, level)
+		applyBrightnessLocked([]monitor.Monitor{m}, level)
+	}
+	return nil
+}
+
+// currentStatus reads the brightness of the monitors selected by sel.
+func currentStatus(sel string, local bool) (*cliStatus, error) {
+	targets, err := resolveTarget(sel)
+	if err != nil {
+		return nil, err
+	}
+	c := cfgSnapshot()
+	st := &cliStatus{
+		Monitors:       []monitorStatus{},
+		AutoColor:      c.AutoColorEnabled,
+		AutoBrightness: c.AutoBrightnessEnabled,
+	}
+	if !local {
+		st.Temp = colorTemp()
+		st.AutoColor = autoColorOn()
+		st.AutoBrightness = autoBrightnessOn()
+	}
+	for _, m := range targets {
+		ms := monitorStatus{ID: m.ID(), Key: monitorKey(m), Name: monitorLabel(m)}
+		if level, err := readBrightness(m); err != nil {
+			ms.Error = err.Error()
+		} else {
+			ms.Brightness = level
+		}
+		for _, v := range monitorInputs(m) {
+			ms.Inputs = append(ms.Inputs, monitor.InputName(v))
+		}
+		st.Monitors = append(st.Monitors, ms)
+	}
+	return st, nil
+}
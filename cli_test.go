@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/alex-vit/monibright/monitor"
+)
+
+func TestParseCLI(t *testing.T) {
+	tests := []struct {
+		args   string
+		want   cliRequest
+		asJSON bool
+		err    bool
+	}{
+		{"get", cliRequest{Command: "get"}, false, false},
+		{"get --json -m U2722D", cliRequest{Command: "get", Monitor: "U2722D"}, true, false},
+		{"set 60", cliRequest{Command: "set", Level: 60}, false, false},
+		{"set +10 --monitor=left", cliRequest{Command: "set", Level: 10, Relative: true, Monitor: "left"}, false, false},
+		{"set -5", cliRequest{Command: "set", Level: -5, Relative: true}, false, false},
+		{"temp 4000", cliRequest{Command: "temp", Temp: 4000}, false, false},
+		{"temp 4000K", cliRequest{Command: "temp", Temp: 4000}, false, false},
+		{"profile late night", cliRequest{Command: "profile", Profile: "late night"}, false, false},
+		{"auto on", cliRequest{Command: "auto", Auto: "color", On: true}, false, false},
+		{"auto brightness off", cliRequest{Command: "auto", Auto: "brightness"}, false, false},
+		{"list-monitors --json", cliRequest{Command: "list-monitors"}, true, false},
+		{"", cliRequest{}, false, true},
+		{"set", cliRequest{}, false, true},
+		{"set 101", cliRequest{}, false, true},
+		{"set bright", cliRequest{}, false, true},
+		{"temp 2000", cliRequest{}, false, true},
+		{"auto maybe", cliRequest{}, false, true},
+		{"get extra", cliRequest{}, false, true},
+		{"get --monitor", cliRequest{}, false, true},
+		{"dance", cliRequest{}, false, true},
+	}
+	for _, tt := range tests {
+		req, asJSON, err := parseCLI(strings.Fields(tt.args))
+		if (err != nil) != tt.err {
+			t.Errorf("parseCLI(%q): err = %v", tt.args, err)
+			continue
+		}
+		if !tt.err && (req != tt.want || asJSON != tt.asJSON) {
+			t.Errorf("parseCLI(%q) = %+v, %v; want %+v, %v", tt.args, req, asJSON, tt.want, tt.asJSON)
+		}
+	}
+}
+
+// runTestCLI runs a command line with no running instance, against the fake
+// monitors already installed.
+func runTestCLI(t *testing.T, args string) (stdout, stderr string, code int) {
+	t.Helper()
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	var out, errOut bytes.Buffer
+	code = runCLI(strings.Fields(args), &out, &errOut, func() error { return nil })
+	return out.String(), errOut.String(), code
+}
+
+func TestCLILocal(t *testing.T) {
+	a, b := monitor.NewFakeMonitor(0, 50), monitor.NewFakeMonitor(1, 40)
+	useFakeBackend(t, a, b)
+
+	if _, errOut, code := runTestCLI(t, "set 70 -m 1"); code != 0 {
+		t.Fatalf("set: exit %d: %s", code, errOut)
+	}
+	if a.VCP[monitor.VCPBrightness] != 50 || b.VCP[monitor.VCPBrightness] != 70 {
+		t.Errorf("brightness = %d, %d; want 50, 70", a.VCP[monitor.VCPBrightness], b.VCP[monitor.VCPBrightness])
+	}
+
+	if _, errOut, code := runTestCLI(t, "set -20"); code != 0 {
+		t.Fatalf("set -20: exit %d: %s", code, errOut)
+	}
+	if a.VCP[monitor.VCPBrightness] != 30 || b.VCP[monitor.VCPBrightness] != 50 {
+		t.Errorf("brightness = %d, %d; want 30, 50", a.VCP[monitor.VCPBrightness], b.VCP[monitor.VCPBrightness])
+	}
+
+	out, _, _ := runTestCLI(t, "get")
+	if want := "Fake Monitor 0: 30%\nFake Monitor 1: 50%\n"; out != want {
+		t.Errorf("get = %q, want %q", out, want)
+	}
+
+	out, _, _ = runTestCLI(t, "get --json -m 0")
+	var reply cliReply
+	if err := json.Unmarshal([]byte(out), &reply); err != nil {
+		t.Fatalf("get --json: %v\n%s", err, out)
+	}
+	if reply.Status == nil || len(reply.Status.Monitors) != 1 || reply.Status.Monitors[0].Brightness != 30 {
+		t.Errorf("get --json = %s", out)
+	}
+
+	out, _, _ = runTestCLI(t, "list-monitors")
+	if lines := strings.Split(strings.TrimSpace(out), "\n"); len(lines) != 3 || !strings.HasPrefix(lines[0], "ID") {
+		t.Errorf("list-monitors = %q", out)
+	}
+
+	// Color temperature and auto modes belong to the running instance.
+	if _, errOut, code := runTestCLI(t, "temp 4000"); code != 1 || !strings.Contains(errOut, "not running") {
+		t.Errorf("temp: exit %d, stderr %q", code, errOut)
+	}
+	if _, _, code := runTestCLI(t, "set 50 -m nonexistent"); code != 1 {
+		t.Errorf("set on unknown monitor: exit %d, want 1", code)
+	}
+}
+
+func TestCLILocalSavesConfig(t *testing.T) {
+	useFakeBackend(t, monitor.NewFakeMonitor(0, 50))
+
+	if _, errOut, code := runTestCLI(t, "set 40"); code != 0 {
+		t.Fatalf("set: exit %d: %s", code, errOut)
+	}
+	data, err := os.ReadFile(configPath())
+	if err != nil {
+		t.Fatalf("config not saved before exit: %v", err)
+	}
+	var saved config
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if got := saved.Monitors[monitorKey(allMonitors[0])].Brightness; got != 40 {
+		t.Errorf("saved brightness = %d, want 40", got)
+	}
+}
+
+func TestCLIForwarded(t *testing.T) {
+	a := monitor.NewFakeMonitor(0, 50)
+	useFakeBackend(t, a)
+	prevCfg, prevTemp := cfg, currentColorTemp
+	t.Cleanup(func() { cfg, currentColorTemp = prevCfg, prevTemp })
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+
+	l, err := listenIPC()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+	go serveIPC(l)
+
+	setupRan := false
+	run := func(args string) (string, int) {
+		var out, errOut bytes.Buffer
+		code := runCLI(strings.Fields(args), &out, &errOut, func() error { setupRan = true; return nil })
+		return out.String() + errOut.String(), code
+	}
+	if out, code := run("temp 4200"); code != 0 {
+		t.Fatalf("temp: exit %d: %s", code, out)
+	}
+	if currentColorTemp != 4200 {
+		t.Errorf("color temp = %dK, want 4200K", currentColorTemp)
+	}
+	if out, _ := run("get"); out != "50%\ncolor temp: 4200K\n" {
+		t.Errorf("get = %q", out)
+	}
+	if setupRan {
+		t.Error("ran locally instead of forwarding")
+	}
+}
@@ -3,8 +3,10 @@ package main
 import (
 	"encoding/json"
 	"log"
+	"maps"
 	"os"
 	"path/filepath"
+	"sync"
 )
 
 type config struct {
@@ -14,6 +16,78 @@ type config struct {
 	ManualTemp       int     `json:"manual_temp"`
 	Latitude         float64 `json:"latitude"`
 	Longitude        float64 `json:"longitude"`
+	SunAPICheck      bool    `json:"sun_api_check,omitempty"` // compare the computed sun schedule with sunrisesunset.io
+
+	// Timezone is the IANA zone schedules are computed in, e.g.
+	// "Europe/Berlin". Empty follows the system zone, including changes
+	// while running.
+	Timezone string `json:"timezone,omitempty"`
+	// LocationDetected is set when the coordinates came from detectLocation
+	// rather than the user; they are re-detected when the time zone differs
+	// from LocationZone, the zone they were detected in.
+	LocationDetected bool   `json:"location_detected,omitempty"`
+	LocationZone     string `json:"location_zone,omitempty"`
+
+	// Schedule source: "sun" (default) or "fixed" for FixedTimes.
+	ScheduleMode string     `json:"schedule_mode,omitempty"`
+	FixedTimes   fixedTimes `json:"fixed_times,omitzero"`
+
+	// Day/night transition: "twilight" (default) ramps around sunrise and
+	// sunset; "elevation" ramps between two solar elevations in degrees.
+	TransitionModel string  `json:"transition_model"`
+	ElevationHigh   float64 `json:"elevation_high"`
+	ElevationLow    float64 `json:"elevation_low"`
+
+	AutoBrightnessEnabled bool `json:"auto_brightness_enabled"`
+	DayBrightness         int  `json:"day_brightness"`   // percent
+	NightBrightness       int  `json:"night_brightness"` // percent
+
+	// Rules override the day/night levels at set times; see rule.
+	Rules []rule `json:"rules,omitempty"`
+
+	// Local HTTP API on 127.0.0.1:APIPort, off by default. Requests need
+	// "Authorization: Bearer <APIToken>"; a token is generated when empty.
+	APIEnabled bool   `json:"api_enabled,omitempty"`
+	APIPort    int    `json:"api_port,omitempty"`
+	APIToken   string `json:"api_token,omitempty"`
+
+	// MQTT publishes the monitors to Home Assistant; see mqttConfig.
+	MQTT *mqttConfig `json:"mqtt,omitempty"`
+
+	// Hue mirrors the color temperature to smart lights; see hueConfig.
+	Hue *hueConfig `json:"hue,omitempty"`
+
+	// Self-update channel: "stable" (default) or "beta" to include
+	// pre-releases. UpdatePin installs only that version, UpdateSkip lists
+	// versions never to install.
+	UpdateChannel string   `json:"update_channel,omitempty"`
+	UpdatePin     string   `json:"update_pin,omitempty"`
+	UpdateSkip    []string `json:"update_skip,omitempty"`
+
+	// Hooks run on state changes; see hook.
+	Hooks []hook `json:"hooks,omitempty"`
+
+	InputHotkeys []inputHotkey `json:"input_hotkeys,omitempty"`
+	Profiles     []profile     `json:"profiles,omitempty"`
+
+	// Per-monitor settings keyed by monitorKey, and named groups of monitor
+	// selectors, e.g. {"left pair": ["U2722D", "1"]}.
+	Monitors      map[string]monitorConfig `json:"monitors,omitempty"`
+	MonitorGroups map[string][]string      `json:"monitor_groups,omitempty"`
+	TrayMonitor   string                   `json:"tray_monitor,omitempty"`
+}
+
+type monitorConfig struct {
+	Brightness int `json:"brightness"`
+}
+
+// inputHotkey switches monitors to an input, e.g.
+// {"key": "Win+Alt+1", "input": "DP1", "monitor": "U2722D"}.
+// An empty monitor selects all monitors.
+type inputHotkey struct {
+	Key     string `json:"key"`
+	Input   string `json:"input"`
+	Monitor string `json:"monitor,omitempty"`
 }
 
 var cfg config
@@ -33,8 +107,9 @@ func loadConfig() {
 		log.Printf("config: parse error: %v, using defaults", err)
 	}
 	applyConfigDefaults()
-	log.Printf("config: loaded (auto_color=%v day=%dK night=%dK lat=%.2f lon=%.2f)",
-		cfg.AutoColorEnabled, cfg.DayTemp, cfg.NightTemp, cfg.Latitude, cfg.Longitude)
+	log.Printf("config: loaded (auto_color=%v day=%dK night=%dK lat=%.2f lon=%.2f auto_brightness=%v day=%d%% night=%d%%)",
+		cfg.AutoColorEnabled, cfg.DayTemp, cfg.NightTemp, cfg.Latitude, cfg.Longitude,
+		cfg.AutoBrightnessEnabled, cfg.DayBrightness, cfg.NightBrightness)
 }
 
 func applyConfigDefaults() {
@@ -47,9 +122,72 @@ func applyConfigDefaults() {
 	if cfg.ManualTemp == 0 {
 		cfg.ManualTemp = 6500
 	}
+	// The schedule settings stay as written, so they are only saved when
+	// set: anything but "fixed" follows the sun, and fixedScheduleFor fills
+	// in missing times.
+	switch cfg.ScheduleMode {
+	case "", modeSun, modeFixed:
+	default:
+		log.Printf("config: unknown schedule_mode %q, using %q", cfg.ScheduleMode, modeSun)
+	}
+	switch cfg.TransitionModel {
+	case modelTwilight, modelElevation:
+	default:
+		if cfg.TransitionModel != "" {
+			log.Printf("config: unknown transition_model %q, using %q", cfg.TransitionModel, modelTwilight)
+		}
+		cfg.TransitionModel = modelTwilight
+	}
+	if cfg.ElevationHigh <= cfg.ElevationLow {
+		if cfg.ElevationHigh != 0 || cfg.ElevationLow != 0 {
+			log.Printf("config: elevation_high (%.1f) must be above elevation_low (%.1f), using defaults",
+				cfg.ElevationHigh, cfg.ElevationLow)
+		}
+		cfg.ElevationHigh = 3
+		cfg.ElevationLow = -6
+	}
+	if cfg.DayBrightness == 0 {
+		cfg.DayBrightness = 100
+	}
+	if cfg.NightBrightness == 0 {
+		cfg.NightBrightness = 30
+	}
+	if cfg.APIPort == 0 {
+		cfg.APIPort = 8737
+	}
+	compileRules()
+}
+
+// cfgMu guards changes to cfg once the app is running, so saveConfig never
+// marshals a half-written config or a map being written to.
+var cfgMu sync.Mutex
+
+// cfgSnapshot returns a copy of cfg taken under cfgMu, for goroutines that
+// read settings while others change them. The map and struct that changes
+// write into are copied too.
+func cfgSnapshot() config {
+	cfgMu.Lock()
+	defer cfgMu.Unlock()
+	c := cfg
+	c.Monitors = maps.Clone(cfg.Monitors)
+	if cfg.Hue != nil {
+		hue := *cfg.Hue
+		c.Hue = &hue
+	}
+	return c
+}
+
+// updateConfig changes cfg under cfgMu and saves it.
+func updateConfig(change func(c *config)) {
+	cfgMu.Lock()
+	change(&cfg)
+	cfgMu.Unlock()
+	saveConfig()
 }
 
 func saveConfig() {
+	cfgMu.Lock()
+	defer cfgMu.Unlock()
 	data, err := json.MarshalIndent(cfg, "", "  ")
 	if err != nil {
 		log.Printf("config: marshal error: %v", err)
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/alex-vit/monibright/monitor"
+)
+
+// eventBuffer is how many events a subscriber may fall behind before it
+// starts missing them. Publishing never waits for subscribers.
+const eventBuffer = 64
+
+// colorTempSettle is how long color temperature changes are collected
+// before a color_temp event reports the latest one, so an animation or a
+// slider drag doesn't flood subscribers with a frame every few milliseconds.
+var colorTempSettle = 250 * time.Millisecond
+
+// event is one state change. Data is the JSON payload, encoded when the
+// event is published.
+type event struct {
+	ID   uint64
+	Type string // brightness, color_temp, auto, monitors, profile, update
+	Time time.Time
+	Data []byte
+}
+
+var (
+	eventsMu        sync.Mutex
+	eventSeq        uint64
+	eventSubs       = map[chan event]struct{}{}
+	eventBrightness = map[string]int{} // last published level per monitor key
+	eventTemp       int                // last published color temperature
+	eventTempNext   int                // color temperature waiting to be published
+	eventTempTimer  *time.Timer        // pending flushColorTemp, if any
+)
+
+// subscribe returns a channel of events published from now on, and a
+// function that ends the subscription.
+func subscribe() (<-chan event, func()) {
+	ch := make(chan event, eventBuffer)
+	eventsMu.Lock()
+	eventSubs[ch] = struct{}{}
+	eventsMu.Unlock()
+	return ch, func() {
+		eventsMu.Lock()
+		delete(eventSubs, ch)
+		eventsMu.Unlock()
+	}
+}
+
+// publish sends an event to every subscriber, dropping it for subscribers
+// whose buffer is full.
+func publish(typ string, data any) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("events: %s: %v", typ, err)
+		return
+	}
+	eventsMu.Lock()
+	defer eventsMu.Unlock()
+	publishLocked(typ, b)
+}
+
+func publishLocked(typ string, data []byte) {
+	if len(eventSubs) == 0 {
+		return
+	}
+	eventSeq++
+	ev := event{ID: eventSeq, Type: typ, Time: time.Now(), Data: data}
+	for ch := range eventSubs {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// publishBrightness reports that m is now at level percent. Repeats of the
+// last level, as when a write is retried on fresh handles, are skipped.
+func publishBrightness(m monitor.Monitor, level int) {
+	key := monitorKey(m)
+	data, _ := json.Marshal(monitorStatus{ID: m.ID(), Key: key, Name: monitorLabel(m), Brightness: level})
+	eventsMu.Lock()
+	defer eventsMu.Unlock()
+	if last, ok := eventBrightness[key]; ok && last == level {
+		return
+	}
+	eventBrightness[key] = level
+	publishLocked("brightness", data)
+}
+
+// publishColorTemp reports a new color temperature once changes have
+// settled for colorTempSettle. Only the latest value is published.
+func publishColorTemp(kelvin int) {
+	eventsMu.Lock()
+	defer eventsMu.Unlock()
+	eventTempNext = kelvin
+	if eventTempTimer == nil {
+		eventTempTimer = time.AfterFunc(colorTempSettle, flushColorTemp)
+	}
+}
+
+func flushColorTemp() {
+	auto := autoColorOn()
+	eventsMu.Lock()
+	defer eventsMu.Unlock()
+	eventTempTimer = nil
+	kelvin := eventTempNext
+	if kelvin == eventTemp {
+		return
+	}
+	eventTemp = kelvin
+	data, _ := json.Marshal(apiColorTemp{Temp: kelvin, Auto: auto})
+	publishLocked("color_temp", data)
+}
+
+// publishAuto reports auto color ("color") or auto brightness ("brightness")
+// turning on or off.
+func publishAuto(mode string, on bool) {
+	publish("auto", struct {
+		Mode string `json:"mode"`
+		On   bool   `json:"on"`
+	}{mode, on})
+}
+
+// publishMonitors reports a re-enumeration. changed is false when the same
+// monitors came back with fresh handles, as after sleep/wake.
+func publishMonitors(changed bool) {
+	monitors := []monitorStatus{}
+	for _, m := range monitorList() {
+		monitors = append(monitors, monitorStatus{ID: m.ID(), Key: monitorKey(m), Name: monitorLabel(m), Brightness: monitorBrightness(m)})
+	}
+	publish("monitors", struct {
+		Changed  bool            `json:"changed"`
+		Monitors []monitorStatus `json:"monitors"`
+	}{changed, monitors})
+}
+
+// publishProfile reports that a profile was applied.
+func publishProfile(name string) {
+	publish("profile", apiProfile{Name: name})
+}
+
+// publishUpdate reports a newer release: "available" once found, "ready"
+// once it will run on the next launch.
+func publishUpdate(version, state string) {
+	publish("update", struct {
+		Version string `json:"version"`
+		State   string `json:"state"`
+	}{version, state})
+}
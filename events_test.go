@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/alex-vit/monibright/monitor"
+)
+
+// useEvents subscribes for the length of the test, starting with no
+// remembered levels so earlier tests don't suppress events.
+func useEvents(t *testing.T) <-chan event {
+	t.Helper()
+	useColorTempSettle(t, 10*time.Millisecond)
+	eventsMu.Lock()
+	eventBrightness = map[string]int{}
+	eventTemp, eventTempNext = 0, 0
+	if eventTempTimer != nil {
+		eventTempTimer.Stop()
+		eventTempTimer = nil
+	}
+	eventsMu.Unlock()
+	events, unsubscribe := subscribe()
+	t.Cleanup(unsubscribe)
+	return events
+}
+
+// useColorTempSettle shortens colorTempSettle for the length of the test.
+func useColorTempSettle(t *testing.T, d time.Duration) {
+	t.Helper()
+	eventsMu.Lock()
+	prev := colorTempSettle
+	colorTempSettle = d
+	eventsMu.Unlock()
+	t.Cleanup(func() {
+		eventsMu.Lock()
+		colorTempSettle = prev
+		eventsMu.Unlock()
+	})
+}
+
+// waitEvent returns the next event of type typ, skipping others.
+func waitEvent(t *testing.T, events <-chan event, typ string) event {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case ev := <-events:
+			if ev.Type == typ {
+				return ev
+			}
+		case <-timeout:
+			t.Fatalf("no %s event", typ)
+		}
+	}
+}
+
+// drain returns the events published so far.
+func drain(events <-chan event) []event {
+	var out []event
+	for {
+		select {
+		case ev := <-events:
+			out = append(out, ev)
+		default:
+			return out
+		}
+	}
+}
+
+func TestEventsFromStateChanges(t *testing.T) {
+	a, b := monitor.NewFakeMonitor(0, 50), monitor.NewFakeMonitor(1, 40)
+	useFakeBackend(t, a, b)
+	prevTemp := currentColorTemp
+	t.Cleanup(func() { currentColorTemp = prevTemp })
+	events := useEvents(t)
+
+	applyBrightness(allMonitors[1:], 70)
+	applyBrightness(allMonitors[1:], 70) // no change, no event
+	requestColorTemp(4000)
+	requestColorTemp(4000)
+	time.Sleep(5 * colorTempSettle)
+	refreshMonitors()
+
+	got := drain(events)
+	var types []string
+	for _, ev := range got {
+		types = append(types, ev.Type)
+	}
+	if want := []string{"brightness", "color_temp", "monitors"}; !slices.Equal(types, want) {
+		t.Fatalf("events = %v, want %v", types, want)
+	}
+
+	var ms monitorStatus
+	if err := json.Unmarshal(got[0].Data, &ms); err != nil {
+		t.Fatal(err)
+	}
+	if ms.ID != "1" || ms.Brightness != 70 || ms.Key == "" {
+		t.Errorf("brightness event = %s", got[0].Data)
+	}
+	var mons struct {
+		Changed  bool            `json:"changed"`
+		Monitors []monitorStatus `json:"monitors"`
+	}
+	if err := json.Unmarshal(got[2].Data, &mons); err != nil {
+		t.Fatal(err)
+	}
+	if mons.Changed || len(mons.Monitors) != 2 {
+		t.Errorf("monitors event = %s, want the same two monitors", got[2].Data)
+	}
+}
+
+func TestPublishSlowSubscriber(t *testing.T) {
+	events := useEvents(t)
+	for range eventBuffer + 10 {
+		publishUpdate("1.0.0", "available")
+	}
+	got := drain(events)
+	if len(got) != eventBuffer {
+		t.Fatalf("got %d events, want the first %d", len(got), eventBuffer)
+	}
+	for i := 1; i < len(got); i++ {
+		if got[i].ID != got[i-1].ID+1 {
+			t.Fatalf("ids %d, %d not consecutive", got[i-1].ID, got[i].ID)
+		}
+	}
+}
+
+func TestColorTempEventsSettle(t *testing.T) {
+	prevTemp := currentColorTemp
+	t.Cleanup(func() { currentColorTemp = prevTemp })
+	events := useEvents(t)
+	useColorTempSettle(t, 100*time.Millisecond)
+
+	// An animation: a frame every few milliseconds.
+	for k := 6500; k >= 3500; k -= 100 {
+		requestColorTemp(k)
+	}
+	ev := waitEvent(t, events, "color_temp")
+	var ct apiColorTemp
+	if err := json.Unmarshal(ev.Data, &ct); err != nil {
+		t.Fatal(err)
+	}
+	if ct.Temp != 3500 {
+		t.Errorf("color_temp event = %s, want the settled 3500K", ev.Data)
+	}
+	time.Sleep(5 * colorTempSettle)
+	if rest := drain(events); len(rest) != 0 {
+		t.Errorf("%d more events after the animation settled", len(rest))
+	}
+}
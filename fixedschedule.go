@@ -0,0 +1,91 @@
+package main
+
+import (
+	"time"
+)
+
+// Schedule modes, selected by cfg.ScheduleMode.
+const (
+	modeSun   = "sun"   // follow sunrise and sunset at the configured location
+	modeFixed = "fixed" // follow the wake and bed times in cfg.FixedTimes
+)
+
+// fixedTimes is the fixed-times schedule, for people who don't follow the
+// sun. Weekend defaults to Weekday.
+type fixedTimes struct {
+	Weekday dayTimes  `json:"weekday"`
+	Weekend *dayTimes `json:"weekend,omitempty"`
+}
+
+// dayTimes holds the wake and bed times ("07:00", "23:00") of one kind of
+// day. The display is fully day after the wake transition and fully night at
+// bed time. A bed time before the wake time is on the next day, for night
+// shifts.
+type dayTimes struct {
+	Wake           string `json:"wake"`
+	Bed            string `json:"bed"`
+	WakeTransition int    `json:"wake_transition"` // minutes
+	BedTransition  int    `json:"bed_transition"`  // minutes
+}
+
+// forDay returns the times for a day starting on weekday d.
+func (f fixedTimes) forDay(d time.Weekday) dayTimes {
+	if f.Weekend != nil && (d == time.Saturday || d == time.Sunday) {
+		return *f.Weekend
+	}
+	return f.Weekday
+}
+
+// withDefaults fills in unset or invalid fields.
+func (t dayTimes) withDefaults() dayTimes {
+	if _, err := time.Parse("15:04", t.Wake); err != nil {
+		t.Wake = "07:00"
+	}
+	if _, err := time.Parse("15:04", t.Bed); err != nil {
+		t.Bed = "23:00"
+	}
+	if t.WakeTransition <= 0 {
+		t.WakeTransition = 30
+	}
+	if t.BedTransition <= 0 {
+		t.BedTransition = 60
+	}
+	return t
+}
+
+// fixedScheduleAt returns the fixed-times schedule in effect at now: the one
+// for the day of the latest wake time at or before now.
+func fixedScheduleAt(now time.Time) sunSchedule {
+	s := fixedScheduleFor(now)
+	if now.Before(s.CivilTwBegin) {
+		s = fixedScheduleFor(now.AddDate(0, 0, -1))
+	}
+	return s
+}
+
+// fixedScheduleFor builds the fixed-times schedule for the day of date, in
+// date's location, as a sunSchedule so the same ramps apply: the morning
+// ramp runs from wake through "sunrise", the evening ramp through "sunset"
+// to bed.
+func fixedScheduleFor(date time.Time) sunSchedule {
+	t := cfgSnapshot().FixedTimes.forDay(date.Weekday()).withDefaults()
+	at := func(day time.Time, hhmm string) time.Time {
+		c, _ := time.Parse("15:04", hhmm)
+		return time.Date(day.Year(), day.Month(), day.Day(), c.Hour(), c.Minute(), 0, 0, day.Location())
+	}
+	wake := at(date, t.Wake)
+	bed := at(date, t.Bed)
+	if !bed.After(wake) {
+		bed = at(date.AddDate(0, 0, 1), t.Bed)
+	}
+	wakeRamp := time.Duration(t.WakeTransition) * time.Minute
+	bedRamp := time.Duration(t.BedTransition) * time.Minute
+	return sunSchedule{
+		Fixed:        true,
+		Noon:         wake,
+		CivilTwBegin: wake,
+		Sunrise:      wake.Add(wakeRamp / 2),
+		Sunset:       bed.Add(-bedRamp / 2),
+		CivilTwEnd:   bed,
+	}
+}
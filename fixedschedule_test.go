@@ -0,0 +1,127 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFixedSchedule(t *testing.T) {
+	loc := useSchedLocation(t, "Europe/Berlin", 0, 0)
+	cfg.ScheduleMode = modeFixed
+	cfg.FixedTimes = fixedTimes{
+		Weekday: dayTimes{Wake: "07:00", Bed: "23:00", WakeTransition: 30, BedTransition: 60},
+		Weekend: &dayTimes{Wake: "10:00", Bed: "01:00", WakeTransition: 30, BedTransition: 60},
+	}
+
+	tests := []struct {
+		at   string
+		want int
+	}{
+		// Wednesday.
+		{"2024-03-27 03:00", 3000},
+		{"2024-03-27 06:59", 3000},
+		{"2024-03-27 07:15", 5000},
+		{"2024-03-27 07:30", 7000},
+		{"2024-03-27 15:00", 7000},
+		{"2024-03-27 22:30", 5000},
+		{"2024-03-27 23:00", 3000},
+		// Friday night keeps weekday times; Saturday wakes late and goes
+		// to bed after midnight.
+		{"2024-03-29 23:30", 3000},
+		{"2024-03-30 07:15", 3000},
+		{"2024-03-30 10:15", 5000},
+		{"2024-03-30 23:30", 7000},
+		{"2024-03-31 00:30", 5000}, // DST starts at 02:00
+		{"2024-03-31 02:00", 3000},
+		{"2024-03-31 04:00", 3000},
+		// Sunday wake on the short day, then Monday is a weekday again.
+		{"2024-03-31 10:15", 5000},
+		{"2024-03-31 12:00", 7000},
+		{"2024-04-01 00:30", 5000},
+		{"2024-04-01 07:15", 5000},
+	}
+	for _, tt := range tests {
+		now, err := time.ParseInLocation("2006-01-02 15:04", tt.at, loc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sched := sunScheduleAt(now)
+		if got := interpolateTemp(now, sched, 7000, 3000); got != tt.want {
+			t.Errorf("%s (%s): got %dK, want %dK", tt.at, sched, got, tt.want)
+		}
+	}
+}
+
+func TestFixedScheduleNightShift(t *testing.T) {
+	loc := useSchedLocation(t, "America/New_York", 40.71, -74.01)
+	cfg.ScheduleMode = modeFixed
+	cfg.TransitionModel = modelElevation // ignored for fixed times
+	cfg.FixedTimes = fixedTimes{
+		Weekday: dayTimes{Wake: "18:00", Bed: "10:00", WakeTransition: 30, BedTransition: 60},
+	}
+
+	tests := []struct {
+		at   string
+		want int
+	}{
+		{"2024-11-01 17:00", 30},
+		{"2024-11-01 18:15", 65},
+		{"2024-11-01 22:00", 100},
+		{"2024-11-02 03:00", 100},
+		{"2024-11-02 09:30", 65},
+		{"2024-11-02 12:00", 30},
+		{"2024-11-03 01:30", 100}, // DST ends at 02:00
+		{"2024-11-03 09:30", 65},
+	}
+	for _, tt := range tests {
+		now, err := time.ParseInLocation("2006-01-02 15:04", tt.at, loc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sched := sunScheduleAt(now)
+		if got := interpolateBrightness(now, sched, 100, 30); got != tt.want {
+			t.Errorf("%s (%s): got %d%%, want %d%%", tt.at, sched, got, tt.want)
+		}
+	}
+}
+
+func TestDayTimesDefaults(t *testing.T) {
+	got := dayTimes{Wake: "7am", Bed: "22:15", BedTransition: -5}.withDefaults()
+	want := dayTimes{Wake: "07:00", Bed: "22:15", WakeTransition: 30, BedTransition: 60}
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestDefaultConfigOmitsSchedule(t *testing.T) {
+	prevCfg, prevDataDir := cfg, dataDir
+	t.Cleanup(func() { cfg, dataDir = prevCfg, prevDataDir })
+	dataDir = t.TempDir()
+	cfg = config{}
+
+	applyConfigDefaults()
+	saveConfig()
+
+	data, err := os.ReadFile(configPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{`"schedule_mode"`, `"fixed_times"`} {
+		if strings.Contains(string(data), key) {
+			t.Errorf("default config saved %s:\n%s", key, data)
+		}
+	}
+}
+
+func TestFixedScheduleDefaultTimes(t *testing.T) {
+	loc := useSchedLocation(t, "Europe/Berlin", 0, 0)
+	cfg.ScheduleMode = modeFixed
+	cfg.FixedTimes = fixedTimes{}
+
+	s := fixedScheduleFor(time.Date(2024, 6, 12, 12, 0, 0, 0, loc))
+	if got := s.CivilTwBegin.Format("15:04"); got != "07:00" {
+		t.Errorf("wake = %s, want the default 07:00", got)
+	}
+}
@@ -3,14 +3,19 @@ module github.com/alex-vit/monibright
 go 1.26
 
 require (
+	github.com/eclipse/paho.mqtt.golang v1.5.1
 	github.com/energye/systray v1.0.3
 	github.com/niluan304/ddcci v0.0.0-20240921162643-87d7400ff137
+	golang.org/x/crypto v0.48.0
 	golang.org/x/sys v0.41.0
+	gopkg.in/Knetic/govaluate.v3 v3.0.0
 )
 
 require (
 	github.com/godbus/dbus/v5 v5.1.0 // indirect
+	github.com/gorilla/websocket v1.5.3 // indirect
 	github.com/lxn/walk v0.0.0-20210112085537-c389da54e794 // indirect
 	github.com/lxn/win v0.0.0-20210218163916-a377121e959e // indirect
-	gopkg.in/Knetic/govaluate.v3 v3.0.0 // indirect
+	golang.org/x/net v0.49.0 // indirect
+	golang.org/x/sync v0.17.0 // indirect
 )
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Hook delivery. Each hook has its own queue and worker, so a slow hook
+// delays only itself; events that don't fit its queue are dropped.
+var (
+	hookQueue   = 32
+	hookBackoff = time.Second // before the first retry, doubling after
+)
+
+// hook runs on state changes: an HTTP POST of the event as JSON, or a
+// command with the event in its environment, e.g.
+// {"events": ["profile"], "command": "C:\\scripts\\pause-video.cmd"} or
+// {"url": "http://nas.local:8080/monibright", "retries": 5}.
+// Event types are brightness, color_temp, auto, monitors (plugged or
+// unplugged), profile and update; no events means all of them.
+type hook struct {
+	Events  []string `json:"events,omitempty"`
+	URL     string   `json:"url,omitempty"`
+	Command string   `json:"command,omitempty"`
+	Args    []string `json:"args,omitempty"`
+	Timeout int      `json:"timeout,omitempty"` // seconds per attempt, default 10
+	Retries *int     `json:"retries,omitempty"` // after the first attempt, default 3; 0 for none
+}
+
+// hookPayload is the body of a webhook POST.
+type hookPayload struct {
+	Event string          `json:"event"`
+	ID    uint64          `json:"id"`
+	Time  time.Time       `json:"time"`
+	Data  json.RawMessage `json:"data"`
+}
+
+// errHookPermanent marks failures that retrying won't fix.
+var errHookPermanent = errors.New("not retried")
+
+func (h hook) String() string {
+	if h.URL != "" {
+		return h.URL
+	}
+	return h.Command
+}
+
+// wants reports whether h runs for ev. Monitor re-enumerations only count
+// when monitors were plugged in or out.
+func (h hook) wants(ev event) bool {
+	if len(h.Events) > 0 && !slices.Contains(h.Events, ev.Type) {
+		return false
+	}
+	if ev.Type == "monitors" {
+		var mons struct {
+			Changed bool `json:"changed"`
+		}
+		if json.Unmarshal(ev.Data, &mons) != nil || !mons.Changed {
+			return false
+		}
+	}
+	return true
+}
+
+// startHooks runs the hooks in config for the life of the process.
+func startHooks() {
+	if hooks := cfgSnapshot().Hooks; len(hooks) > 0 {
+		runHooks(hooks)
+	}
+}
+
+// runHooks delivers events to hooks until the returned function is called.
+func runHooks(hooks []hook) (stop func()) {
+	events, unsubscribe := subscribe()
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+
+	var active []hook
+	var queues []chan event
+	for _, h := range hooks {
+		if (h.URL == "") == (h.Command == "") {
+			log.Printf("hooks: need one of url or command, skipping %+v", h)
+			continue
+		}
+		q := make(chan event, hookQueue)
+		active, queues = append(active, h), append(queues, q)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ev := range q {
+				h.deliver(ev, done)
+			}
+		}()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer func() {
+			for _, q := range queues {
+				close(q)
+			}
+		}()
+		for {
+			select {
+			case <-done:
+				return
+			case ev := <-events:
+				for i, h := range active {
+					if !h.wants(ev) {
+						continue
+					}
+					select {
+					case queues[i] <- ev:
+					default:
+						log.Printf("hooks: %s is behind, dropped %s event", h, ev.Type)
+					}
+				}
+			}
+		}
+	}()
+
+	return func() {
+		unsubscribe()
+		close(done)
+		wg.Wait()
+	}
+}
+
+// deliver runs h for ev, retrying with backoff until it succeeds, fails
+// permanently or done is closed.
+func (h hook) deliver(ev event, done <-chan struct{}) {
+	timeout := 10 * time.Second
+	if h.Timeout > 0 {
+		timeout = time.Duration(h.Timeout) * time.Second
+	}
+	retries := 3
+	if h.Retries != nil {
+		retries = max(*h.Retries, 0)
+	}
+	backoff := hookBackoff
+	for attempt := 0; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		var err error
+		if h.URL != "" {
+			err = h.post(ctx, ev)
+		} else {
+			err = h.run(ctx, ev)
+		}
+		cancel()
+		if err == nil {
+			return
+		}
+		if errors.Is(err, errHookPermanent) || attempt == retries {
+			log.Printf("hooks: %s for %s event: %v", h, ev.Type, err)
+			return
+		}
+		log.Printf("hooks: %s for %s event: %v, retrying in %s", h, ev.Type, err, backoff)
+		select {
+		case <-done:
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (h hook) post(ctx context.Context, ev event) error {
+	body, _ := json.Marshal(hookPayload{Event: ev.Type, ID: ev.ID, Time: ev.Time, Data: ev.Data})
+	req, err := http.NewRequestWithContext(ctx, "POST", h.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("%w: %w", errHookPermanent, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "MoniBright/"+displayVersion())
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests:
+		return fmt.Errorf("%w: %s", errHookPermanent, resp.Status)
+	default:
+		return errors.New(resp.Status)
+	}
+}
+
+// run starts the command with the event in its environment:
+// MONIBRIGHT_EVENT, MONIBRIGHT_DATA (the JSON payload) and one
+// MONIBRIGHT_<FIELD> per top-level field, e.g. MONIBRIGHT_BRIGHTNESS=70.
+func (h hook) run(ctx context.Context, ev event) error {
+	cmd := exec.CommandContext(ctx, h.Command, h.Args...)
+	cmd.Env = append(os.Environ(), hookEnv(ev)...)
+	hideWindow(cmd)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			err = fmt.Errorf("%w: %w", errHookPermanent, err)
+		}
+		if out := strings.TrimSpace(string(out)); out != "" {
+			return fmt.Errorf("%w: %s", err, out)
+		}
+		return err
+	}
+	return nil
+}
+
+func hookEnv(ev event) []string {
+	env := []string{
+		"MONIBRIGHT_EVENT=" + ev.Type,
+		"MONIBRIGHT_DATA=" + string(ev.Data),
+	}
+	var fields map[string]any
+	_ = json.Unmarshal(ev.Data, &fields)
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		var v string
+		switch f := fields[k].(type) {
+		case string:
+			v = f
+		case float64:
+			v = strconv.FormatFloat(f, 'f', -1, 64)
+		case bool:
+			v = strconv.FormatBool(f)
+		default:
+			continue
+		}
+		env = append(env, "MONIBRIGHT_"+strings.ToUpper(k)+"="+v)
+	}
+	return env
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func useHooks(t *testing.T, hooks ...hook) {
+	t.Helper()
+	useEvents(t)
+	prevBackoff := hookBackoff
+	hookBackoff = time.Millisecond
+	stop := runHooks(hooks)
+	t.Cleanup(func() {
+		stop()
+		hookBackoff = prevBackoff
+	})
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if cond() {
+			return
+		}
+	}
+	t.Fatalf("timed out waiting for %s", what)
+}
+
+func TestWebhook(t *testing.T) {
+	var mu sync.Mutex
+	var got []hookPayload
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The first delivery fails and is retried.
+		if calls.Add(1) == 1 {
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+			return
+		}
+		var p hookPayload
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			t.Error(err)
+		}
+		mu.Lock()
+		got = append(got, p)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+	useHooks(t, hook{URL: srv.URL, Events: []string{"auto", "monitors"}})
+
+	publishAuto("color", true)
+	publishUpdate("2.0.0", "available") // not subscribed
+	publishMonitors(false)              // same monitors, fresh handles: not a hotplug
+	publishMonitors(true)
+
+	waitFor(t, "two webhooks", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(got) == 2
+	})
+	time.Sleep(20 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	if len(got) != 2 || got[0].Event != "auto" || got[1].Event != "monitors" {
+		t.Fatalf("webhooks = %+v, want auto then monitors", got)
+	}
+	if string(got[0].Data) != `{"mode":"color","on":true}` || got[0].Time.IsZero() {
+		t.Errorf("auto payload = %+v", got[0])
+	}
+	if n := calls.Load(); n != 3 {
+		t.Errorf("%d requests, want 3 (one retry)", n)
+	}
+}
+
+func TestWebhookPermanentFailure(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		http.Error(w, "no such hook", http.StatusNotFound)
+	}))
+	defer srv.Close()
+	useHooks(t, hook{URL: srv.URL, Retries: intp(5)})
+
+	publishAuto("brightness", false)
+	waitFor(t, "webhook", func() bool { return calls.Load() > 0 })
+	time.Sleep(50 * time.Millisecond)
+	if n := calls.Load(); n != 1 {
+		t.Errorf("%d requests for a 404, want 1", n)
+	}
+}
+
+func TestWebhookNoRetries(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		http.Error(w, "busy", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	useHooks(t, hook{URL: srv.URL, Retries: intp(0)})
+
+	publishAuto("brightness", false)
+	waitFor(t, "webhook", func() bool { return calls.Load() > 0 })
+	time.Sleep(50 * time.Millisecond)
+	if n := calls.Load(); n != 1 {
+		t.Errorf("%d requests with retries 0, want 1", n)
+	}
+}
+
+func TestSlowHookDropsEvents(t *testing.T) {
+	release := make(chan struct{})
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+	useHooks(t, hook{URL: srv.URL, Timeout: 60})
+
+	start := time.Now()
+	for range hookQueue * 2 {
+		publishAuto("color", true)
+	}
+	if d := time.Since(start); d > 100*time.Millisecond {
+		t.Errorf("publishing took %s with a stuck hook", d)
+	}
+	waitFor(t, "first webhook", func() bool { return calls.Load() == 1 })
+}
+
+func TestCommandHook(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	out := filepath.Join(t.TempDir(), "out")
+	useHooks(t, hook{
+		Command: "sh",
+		Args:    []string{"-c", `printf '%s %s %s' "$MONIBRIGHT_EVENT" "$MONIBRIGHT_MODE" "$MONIBRIGHT_ON" > "$0"`, out},
+		Events:  []string{"auto"},
+	})
+
+	publishAuto("brightness", true)
+	var got []byte
+	waitFor(t, "hook output", func() bool {
+		got, _ = os.ReadFile(out)
+		return len(got) > 0
+	})
+	if string(got) != "auto brightness true" {
+		t.Errorf("hook saw %q", got)
+	}
+}
+
+func TestHookEnv(t *testing.T) {
+	env := hookEnv(event{Type: "brightness", Data: []byte(`{"id":"1","key":"DEL-U2722D","brightness":70,"inputs":["DP1"]}`)})
+	want := []string{
+		"MONIBRIGHT_EVENT=brightness",
+		`MONIBRIGHT_DATA={"id":"1","key":"DEL-U2722D","brightness":70,"inputs":["DP1"]}`,
+		"MONIBRIGHT_BRIGHTNESS=70",
+		"MONIBRIGHT_ID=1",
+		"MONIBRIGHT_KEY=DEL-U2722D",
+	}
+	if !slices.Equal(env, want) {
+		t.Errorf("hookEnv =\n%s\nwant\n%s", strings.Join(env, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -22,11 +22,7 @@ var (
 	procGetMessageW      = user32.NewProc("GetMessageW")
 )
 
-const (
-	modWin = 0x8
-
-	wmHotkey = 0x0312
-)
+const wmHotkey = 0x0312
 
 type wmMsg struct {
 	hwnd    uintptr
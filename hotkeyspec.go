@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// RegisterHotKey modifier flags and virtual-key codes.
+const (
+	VKNumpad0 = 0x60
+	VKF1      = 0x70
+
+	modAlt   = 0x1
+	modCtrl  = 0x2
+	modShift = 0x4
+	modWin   = 0x8
+)
+
+var hotkeyMods = map[string]int{
+	"alt":     modAlt,
+	"ctrl":    modCtrl,
+	"control": modCtrl,
+	"shift":   modShift,
+	"win":     modWin,
+}
+
+var hotkeyKeys = map[string]int{
+	"space":    0x20,
+	"pageup":   0x21,
+	"pagedown": 0x22,
+	"end":      0x23,
+	"home":     0x24,
+	"left":     0x25,
+	"up":       0x26,
+	"right":    0x27,
+	"down":     0x28,
+	"insert":   0x2D,
+	"delete":   0x2E,
+}
+
+// parseHotkey parses a hotkey like "Win+Alt+1", "Ctrl+Shift+F9" or
+// "Win+Numpad5" into RegisterHotKey modifiers and a virtual-key code.
+// Names are case-insensitive; at least one modifier is required so a config
+// typo can't swallow a plain key system-wide.
+func parseHotkey(s string) (mods, vk int, err error) {
+	parts := strings.Split(s, "+")
+	for _, p := range parts[:len(parts)-1] {
+		m, ok := hotkeyMods[strings.ToLower(strings.TrimSpace(p))]
+		if !ok {
+			return 0, 0, fmt.Errorf("hotkey %q: unknown modifier %q", s, p)
+		}
+		mods |= m
+	}
+	if mods == 0 {
+		return 0, 0, fmt.Errorf("hotkey %q: needs a modifier (Win, Ctrl, Alt or Shift)", s)
+	}
+
+	key := strings.ToLower(strings.TrimSpace(parts[len(parts)-1]))
+	switch {
+	case len(key) == 1 && key[0] >= 'a' && key[0] <= 'z':
+		vk = int(key[0]-'a') + 'A'
+	case len(key) == 1 && key[0] >= '0' && key[0] <= '9':
+		vk = int(key[0])
+	case numbered(key, "numpad", 0, 9):
+		vk = VKNumpad0 + int(key[6]-'0')
+	case numbered(key, "f", 1, 24):
+		n, _ := strconv.Atoi(key[1:])
+		vk = VKF1 + n - 1
+	default:
+		var ok bool
+		if vk, ok = hotkeyKeys[key]; !ok {
+			return 0, 0, fmt.Errorf("hotkey %q: unknown key %q", s, parts[len(parts)-1])
+		}
+	}
+	return mods, vk, nil
+}
+
+// numbered reports whether key is prefix followed by a number in [lo, hi].
+func numbered(key, prefix string, lo, hi int) bool {
+	rest, ok := strings.CutPrefix(key, prefix)
+	if !ok {
+		return false
+	}
+	n, err := strconv.Atoi(rest)
+	return err == nil && n >= lo && n <= hi && strconv.Itoa(n) == rest
+}
@@ -0,0 +1,39 @@
+package main
+
+import "testing"
+
+func TestParseHotkey(t *testing.T) {
+	tests := []struct {
+		in       string
+		wantMods int
+		wantVK   int
+		wantErr  bool
+	}{
+		{"Win+Alt+1", modWin | modAlt, '1', false},
+		{"ctrl+shift+F9", modCtrl | modShift, VKF1 + 8, false},
+		{"Win+Numpad5", modWin, VKNumpad0 + 5, false},
+		{"Control + Alt + H", modCtrl | modAlt, 'H', false},
+		{"Win+F24", modWin, VKF1 + 23, false},
+		{"Alt+PageUp", modAlt, 0x21, false},
+		{"Win+Shift+Left", modWin | modShift, 0x25, false},
+		{"F5", 0, 0, true},
+		{"H", 0, 0, true},
+		{"Hyper+1", 0, 0, true},
+		{"Win+F25", 0, 0, true},
+		{"Win+F0", 0, 0, true},
+		{"Win+Numpad10", 0, 0, true},
+		{"Win+Numpad", 0, 0, true},
+		{"Win+Enter", 0, 0, true},
+		{"Win+", 0, 0, true},
+	}
+	for _, tt := range tests {
+		mods, vk, err := parseHotkey(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseHotkey(%q) err = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && (mods != tt.wantMods || vk != tt.wantVK) {
+			t.Errorf("parseHotkey(%q) = (0x%X, 0x%X), want (0x%X, 0x%X)", tt.in, mods, vk, tt.wantMods, tt.wantVK)
+		}
+	}
+}
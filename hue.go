@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Hue bridge timing. Bridges handle about ten commands a second, so
+// changes are sent at most once per hueInterval, latest value winning.
+var (
+	hueInterval      = time.Second
+	huePairRetry     = 5 * time.Second
+	huePairTimeout   = 2 * time.Minute
+	hueHTTPClient    = &http.Client{Timeout: 5 * time.Second}
+	errHueLinkButton = errors.New("link button not pressed")
+)
+
+// hueConfig mirrors the color temperature to Philips Hue lights, e.g.
+// {"bridge": "192.168.1.20", "lights": ["3", "4"], "brightness": true}.
+// Username is the bridge's pairing key; when empty MoniBright pairs on start
+// (press the bridge's link button) and saves it.
+type hueConfig struct {
+	Bridge     string   `json:"bridge"`
+	Username   string   `json:"username,omitempty"`
+	Lights     []string `json:"lights"`
+	Brightness bool     `json:"brightness,omitempty"` // also follow the tray monitor's brightness
+}
+
+// hueState is what the lights should show. Zero fields are left alone.
+type hueState struct {
+	Temp       int // kelvin
+	Brightness int // percent, 0 for unset
+}
+
+// hueSync sends state changes to the bridge.
+type hueSync struct {
+	base       string // bridge URL
+	username   string
+	lights     []string
+	brightness bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// startHue starts mirroring to the Hue bridge in config, if any.
+func startHue() {
+	c := cfgSnapshot().Hue
+	if c == nil || c.Bridge == "" || len(c.Lights) == 0 {
+		return
+	}
+	newHueSync(*c)
+}
+
+func newHueSync(c hueConfig) *hueSync {
+	base := c.Bridge
+	if !strings.Contains(base, "://") {
+		base = "http://" + base
+	}
+	h := &hueSync{
+		base:       strings.TrimSuffix(base, "/"),
+		username:   c.Username,
+		lights:     c.Lights,
+		brightness: c.Brightness,
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	events, unsubscribe := subscribe()
+	go func() {
+		defer close(h.done)
+		defer unsubscribe()
+		h.run(events)
+	}()
+	return h
+}
+
+func (h *hueSync) close() {
+	close(h.stop)
+	<-h.done
+}
+
+func (h *hueSync) run(events <-chan event) {
+	if h.username == "" {
+		if !h.pair() {
+			return
+		}
+	}
+	log.Printf("hue: syncing lights %v on %s", h.lights, h.base)
+
+	want := hueState{Temp: colorTemp()}
+	if m := trayMonitor(); h.brightness && m != nil {
+		want.Brightness = monitorBrightness(m)
+	}
+	var sent hueState
+	var last time.Time
+	var timer <-chan time.Time
+	send := func() {
+		last, timer = time.Now(), nil
+		if err := h.push(want, sent); err != nil {
+			// Try again next interval, even if nothing else changes.
+			log.Printf("hue: %v", err)
+			timer = time.After(hueInterval)
+			return
+		}
+		sent = want
+	}
+	send()
+
+	for {
+		select {
+		case <-h.stop:
+			return
+		case <-timer:
+			send()
+			continue
+		case ev := <-events:
+			switch ev.Type {
+			case "color_temp":
+				var ct apiColorTemp
+				if json.Unmarshal(ev.Data, &ct) == nil {
+					want.Temp = ct.Temp
+				}
+			case "brightness":
+				var ms monitorStatus
+				if m := trayMonitor(); h.brightness && m != nil && json.Unmarshal(ev.Data, &ms) == nil && ms.Key == monitorKey(m) {
+					want.Brightness = ms.Brightness
+				}
+			}
+		}
+		if want == sent || timer != nil {
+			continue
+		}
+		if wait := hueInterval - time.Since(last); wait > 0 {
+			timer = time.After(wait)
+		} else {
+			send()
+		}
+	}
+}
+
+// pair registers with the bridge, retrying while the user finds the link
+// button, and saves the key. It reports whether pairing succeeded.
+func (h *hueSync) pair() bool {
+	log.Printf("hue: press the link button on the bridge at %s to pair", h.base)
+	deadline := time.Now().Add(huePairTimeout)
+	for {
+		username, err := pairHue(h.base)
+		if err == nil {
+			h.username = username
+			updateConfig(func(c *config) {
+				if c.Hue != nil {
+					c.Hue.Username = username
+				}
+			})
+			log.Printf("hue: paired with %s", h.base)
+			return true
+		}
+		if !errors.Is(err, errHueLinkButton) || time.Now().After(deadline) {
+			log.Printf("hue: pairing failed: %v", err)
+			return false
+		}
+		select {
+		case <-h.stop:
+			return false
+		case <-time.After(huePairRetry):
+		}
+	}
+}
+
+// hueResult is one entry of a bridge response.
+type hueResult struct {
+	Success map[string]any `json:"success"`
+	Error   *struct {
+		Type        int    `json:"type"`
+		Description string `json:"description"`
+	} `json:"error"`
+}
+
+// pairHue asks the bridge at base for a new username.
+func pairHue(base string) (string, error) {
+	host, _ := os.Hostname()
+	body, _ := json.Marshal(map[string]string{"devicetype": "monibright#" + host})
+	results, err := hueCall("POST", base+"/api", body)
+	if err != nil {
+		return "", err
+	}
+	for _, r := range results {
+		if r.Error != nil {
+			if r.Error.Type == 101 {
+				return "", errHueLinkButton
+			}
+			return "", errors.New(r.Error.Description)
+		}
+		if u, ok := r.Success["username"].(string); ok {
+			return u, nil
+		}
+	}
+	return "", errors.New("no username in response")
+}
+
+// push sends the fields of want that differ from sent to every light.
+func (h *hueSync) push(want, sent hueState) error {
+	body := map[string]int{"transitiontime": 4} // 400 ms
+	if want.Temp != sent.Temp && want.Temp > 0 {
+		body["ct"] = clamp(kelvinToMireds(want.Temp), 153, 500)
+	}
+	if want.Brightness != sent.Brightness && want.Brightness > 0 {
+		body["bri"] = clamp((want.Brightness*254+50)/100, 1, 254)
+	}
+	if len(body) == 1 {
+		return nil
+	}
+	data, _ := json.Marshal(body)
+	var errs []error
+	for _, light := range h.lights {
+		results, err := hueCall("PUT", h.base+"/api/"+h.username+"/lights/"+light+"/state", data)
+		if err == nil {
+			for _, r := range results {
+				if r.Error != nil {
+					err = errors.New(r.Error.Description)
+					break
+				}
+			}
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("light %s: %w", light, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func hueCall(method, url string, body []byte) ([]hueResult, error) {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := hueHTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("bridge returned %s", resp.Status)
+	}
+	var results []hueResult
+	if err := json.Unmarshal(data, &results); err != nil {
+		return nil, fmt.Errorf("bad bridge response: %w", err)
+	}
+	return results, nil
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alex-vit/monibright/monitor"
+)
+
+// fakeBridge is a local stand-in for a Hue bridge. Pairing fails with
+// "link button not pressed" until linkPresses runs out, and light commands
+// fail until failures does.
+type fakeBridge struct {
+	mu          sync.Mutex
+	linkPresses int // pairing attempts before the button counts as pressed
+	failures    int // light commands answered with an error
+	puts        map[string][]map[string]int
+}
+
+func newFakeBridge(t *testing.T) (*fakeBridge, string) {
+	b := &fakeBridge{puts: map[string][]map[string]int{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api", func(w http.ResponseWriter, r *http.Request) {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if b.linkPresses > 0 {
+			b.linkPresses--
+			_, _ = io.WriteString(w, `[{"error":{"type":101,"address":"","description":"link button not pressed"}}]`)
+			return
+		}
+		_, _ = io.WriteString(w, `[{"success":{"username":"paired-key"}}]`)
+	})
+	mux.HandleFunc("PUT /api/{user}/lights/{id}/state", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("user") != "paired-key" {
+			_, _ = io.WriteString(w, `[{"error":{"type":1,"description":"unauthorized user"}}]`)
+			return
+		}
+		var body map[string]int
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if b.failures > 0 {
+			b.failures--
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+			return
+		}
+		b.puts[r.PathValue("id")] = append(b.puts[r.PathValue("id")], body)
+		_, _ = io.WriteString(w, `[{"success":{}}]`)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return b, srv.URL
+}
+
+func (b *fakeBridge) lightPuts(id string) []map[string]int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]map[string]int(nil), b.puts[id]...)
+}
+
+// waitPuts waits until light id has received n commands.
+func (b *fakeBridge) waitPuts(t *testing.T, id string, n int) []map[string]int {
+	t.Helper()
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if puts := b.lightPuts(id); len(puts) >= n {
+			return puts
+		}
+	}
+	t.Fatalf("light %s got %v, want %d commands", id, b.lightPuts(id), n)
+	return nil
+}
+
+func useHue(t *testing.T, c hueConfig) *hueSync {
+	t.Helper()
+	useEvents(t)
+	prevCfg, prevTemp, prevInterval, prevRetry := cfg, currentColorTemp, hueInterval, huePairRetry
+	t.Cleanup(func() {
+		cfg, currentColorTemp, hueInterval, huePairRetry = prevCfg, prevTemp, prevInterval, prevRetry
+	})
+	hueInterval, huePairRetry = 100*time.Millisecond, time.Millisecond
+	cfg.Hue = &c
+	h := newHueSync(c)
+	t.Cleanup(h.close)
+	return h
+}
+
+func TestHuePairing(t *testing.T) {
+	useFakeBackend(t, monitor.NewFakeMonitor(0, 50))
+	bridge, url := newFakeBridge(t)
+	bridge.linkPresses = 2
+	currentColorTemp = 4000
+	useHue(t, hueConfig{Bridge: url, Lights: []string{"3"}})
+
+	puts := bridge.waitPuts(t, "3", 1)
+	if puts[0]["ct"] != 250 {
+		t.Errorf("first command = %v, want ct 250", puts[0])
+	}
+	if cfg.Hue.Username != "paired-key" {
+		t.Errorf("saved username = %q, want paired-key", cfg.Hue.Username)
+	}
+}
+
+func TestHueRateLimit(t *testing.T) {
+	useFakeBackend(t, monitor.NewFakeMonitor(0, 50))
+	bridge, url := newFakeBridge(t)
+	useHue(t, hueConfig{Bridge: url, Username: "paired-key", Lights: []string{"1", "2"}, Brightness: true})
+	bridge.waitPuts(t, "1", 1)
+
+	// An animation: many changes in quick succession.
+	for k := 6000; k >= 3500; k -= 100 {
+		requestColorTemp(k)
+	}
+	applyBrightness(allMonitors, 80)
+
+	bridge.waitPuts(t, "2", 2)
+	time.Sleep(3 * hueInterval)
+	puts := bridge.lightPuts("2")
+	if len(puts) > 3 {
+		t.Errorf("light got %d commands for one animation, want it rate-limited: %v", len(puts), puts)
+	}
+	got := map[string]int{}
+	for _, p := range puts {
+		for k, v := range p {
+			got[k] = v
+		}
+	}
+	if got["ct"] != 286 || got["bri"] != 203 {
+		t.Errorf("light ended at %v, want ct 286 (3500K), bri 203 (80%%)", got)
+	}
+}
+
+func TestHueRetriesFailedCommand(t *testing.T) {
+	useFakeBackend(t, monitor.NewFakeMonitor(0, 50))
+	bridge, url := newFakeBridge(t)
+	bridge.failures = 1
+	currentColorTemp = 4000
+	useHue(t, hueConfig{Bridge: url, Username: "paired-key", Lights: []string{"1"}})
+
+	// Nothing changes after the failed first command; it is sent again.
+	puts := bridge.waitPuts(t, "1", 1)
+	if puts[0]["ct"] != 250 {
+		t.Errorf("retried command = %v, want ct 250", puts[0])
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/alex-vit/monibright/monitor"
+	"github.com/energye/systray"
+)
+
+// inputSwitchSettle is how long to wait after switching inputs before
+// re-enumerating. Monitors drop their DDC/CI handles while switching, which
+// otherwise leaves brightness commands silently failing afterwards.
+var inputSwitchSettle = 2 * time.Second
+
+type inputMenuItem struct {
+	item    *systray.MenuItem
+	monitor string // monitorKey
+	value   int
+}
+
+var inputMenuItems []inputMenuItem
+
+// monitorInputs returns the input sources m advertises, or nil if unknown.
+func monitorInputs(m monitor.Monitor) []int {
+	caps := capsFor(m)
+	if caps == nil {
+		return nil
+	}
+	return caps.Values(monitor.VCPInputSource)
+}
+
+// switchInput switches the selected monitors to the named input, skipping
+// monitors that don't advertise it, then re-enumerates monitors.
+func switchInput(sel, name string) error {
+	value, ok := monitor.ParseInput(name)
+	if !ok {
+		return fmt.Errorf("unknown input %q", name)
+	}
+	brightnessMu.Lock()
+	switched := 0
+	for _, m := range selectMonitors(sel) {
+		if inputs := monitorInputs(m); inputs != nil && !slices.Contains(inputs, value) {
+			log.Printf("monitor %s: input %s not supported", monitorLabel(m), monitor.InputName(value))
+			continue
+		}
+		if err := m.SetVCP(monitor.VCPInputSource, value); err != nil {
+			log.Printf("monitor %s: set input %s: %v", monitorLabel(m), monitor.InputName(value), err)
+			continue
+		}
+		log.Printf("monitor %s: switched input to %s", monitorLabel(m), monitor.InputName(value))
+		switched++
+	}
+	brightnessMu.Unlock()
+	if switched == 0 {
+		return errors.New("no monitor switched input")
+	}
+
+	// Don't hold up brightness changes while the monitor settles.
+	time.Sleep(inputSwitchSettle)
+	brightnessMu.Lock()
+	refreshMonitors()
+	brightnessMu.Unlock()
+	return nil
+}
+
+// buildInputMenu adds one entry per advertised input under parent, grouped by
+// monitor when there is more than one. parent stays hidden if no monitor
+// advertises inputs.
+func buildInputMenu(parent *systray.MenuItem) {
+	var withInputs []monitor.Monitor
+	for _, m := range monitorList() {
+		if len(monitorInputs(m)) > 0 {
+			withInputs = append(withInputs, m)
+		}
+	}
+	if len(withInputs) == 0 {
+		return
+	}
+
+	for _, m := range withInputs {
+		menu := parent
+		if len(withInputs) > 1 {
+			menu = parent.AddSubMenuItem(monitorLabel(m), "")
+		}
+		key := monitorKey(m)
+		for _, v := range monitorInputs(m) {
+			name := monitor.InputName(v)
+			item := menu.AddSubMenuItemCheckbox(name, "Switch to "+name, false)
+			item.Click(func() {
+				go func() {
+					if err := switchInput(monitorIDForKey(key), name); err != nil {
+						log.Printf("input menu: %v", err)
+					}
+				}()
+			})
+			inputMenuItems = append(inputMenuItems, inputMenuItem{item: item, monitor: key, value: v})
+		}
+	}
+	parent.Show()
+}
+
+// syncInputMenu checks the active input of each monitor in the tray menu.
+func syncInputMenu() {
+	current := map[string]int{}
+	for _, m := range monitorList() {
+		if len(monitorInputs(m)) == 0 {
+			continue
+		}
+		if v, err := monitor.GetInput(m); err == nil {
+			current[monitorKey(m)] = v
+		}
+	}
+	for _, mi := range inputMenuItems {
+		if v, ok := current[mi.monitor]; ok && v == mi.value {
+			mi.item.Check()
+		} else {
+			mi.item.Uncheck()
+		}
+	}
+}
+
+func monitorIDForKey(key string) string {
+	for _, m := range monitorList() {
+		if monitorKey(m) == key {
+			return m.ID()
+		}
+	}
+	return key
+}
+
+// monitorLabel names m for menus, tooltips and logs, e.g.
+// "DELL U2722D #ABC123".
+func monitorLabel(m monitor.Monitor) string {
+	if e := monitor.Identify(m); e != nil {
+		return e.DisplayName()
+	}
+	if caps := capsFor(m); caps != nil && caps.Model != "" {
+		return caps.Model
+	}
+	return m.Name()
+}
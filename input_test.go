@@ -0,0 +1,82 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/alex-vit/monibright/monitor"
+)
+
+func withCapabilities(t *testing.T) {
+	t.Helper()
+	t.Cleanup(func() { monitorCaps = map[string]*monitor.Capabilities{} })
+	loadCapabilities(allMonitors)
+}
+
+func TestSwitchInput(t *testing.T) {
+	prevSettle := inputSwitchSettle
+	inputSwitchSettle = 0
+	t.Cleanup(func() { inputSwitchSettle = prevSettle })
+
+	dell := monitor.NewFakeMonitor(0, 50)
+	dell.Caps = "(prot(monitor)type(LCD)model(U2722D)vcp(10 60(0F 11 1B)))"
+	dell.VCP[monitor.VCPInputSource] = 0x0F
+	tv := monitor.NewFakeMonitor(1, 50)
+	tv.Caps = "(prot(monitor)type(LCD)model(TV)vcp(10 60(11 12)))"
+	fake := useFakeBackend(t, dell, tv)
+	withCapabilities(t)
+
+	if err := switchInput("", "usb-c"); err != nil {
+		t.Fatalf("switchInput: %v", err)
+	}
+	if got := dell.VCP[monitor.VCPInputSource]; got != 0x1B {
+		t.Errorf("dell input = 0x%02X, want 0x1B", got)
+	}
+	if len(tv.Sets) != 0 {
+		t.Errorf("tv doesn't advertise USB-C but got writes %v", tv.Sets)
+	}
+	if fake.Enumerations != 2 {
+		t.Errorf("enumerations = %d, want 2 (re-enumerate after switch)", fake.Enumerations)
+	}
+
+	if err := switchInput("tv", "HDMI2"); err != nil {
+		t.Fatalf("switchInput(tv): %v", err)
+	}
+	if got := tv.VCP[monitor.VCPInputSource]; got != 0x12 {
+		t.Errorf("tv input = 0x%02X, want 0x12", got)
+	}
+	if got := dell.VCP[monitor.VCPInputSource]; got != 0x1B {
+		t.Errorf("dell input changed to 0x%02X by tv-only switch", got)
+	}
+
+	if err := switchInput("", "DP2"); err == nil {
+		t.Error("switchInput to an input no monitor has succeeded")
+	}
+	if err := switchInput("", "Thunderbolt"); err == nil {
+		t.Error("switchInput to unknown input succeeded")
+	}
+}
+
+func TestSelectMonitors(t *testing.T) {
+	a, b := monitor.NewFakeMonitor(0, 50), monitor.NewFakeMonitor(1, 50)
+	a.Caps = "(model(U2722D)vcp(10))"
+	b.Description = "Generic PnP Monitor"
+	useFakeBackend(t, a, b)
+	withCapabilities(t)
+
+	tests := []struct {
+		sel  string
+		want int
+	}{
+		{"", 2},
+		{"0", 1},
+		{"u2722", 1},
+		{"generic", 1},
+		{"monitor", 2},
+		{"nope", 0},
+	}
+	for _, tt := range tests {
+		if got := len(selectMonitors(tt.sel)); got != tt.want {
+			t.Errorf("selectMonitors(%q) = %d monitors, want %d", tt.sel, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"time"
+)
+
+// The running instance answers commands from other processes over a named
+// pipe on Windows and a Unix socket elsewhere. Each connection carries one
+// request and one reply, each a JSON object with the protocol version in
+// "v", e.g.
+//
+//	→ {"v": 1, "command": "set", "level": 60}
+//	← {"v": 1, "status": {"monitors": [...], "temp": 6500, ...}}
+//
+// The server refuses requests of another version rather than guess.
+const ipcVersion = 1
+
+// ipcTimeout bounds how long a connection may take to send its request.
+const ipcTimeout = 10 * time.Second
+
+// ipcMaxRequest bounds the size of a request.
+const ipcMaxRequest = 64 << 10
+
+type ipcRequest struct {
+	Version int `json:"v"`
+	cliRequest
+}
+
+type ipcReply struct {
+	Version int `json:"v"`
+	cliReply
+}
+
+// ipcListener accepts connections from command-line instances: a named pipe
+// on Windows, a Unix socket elsewhere.
+type ipcListener interface {
+	Accept() (io.ReadWriteCloser, error)
+	Close() error
+}
+
+// startIPC listens for commands from other instances in the background.
+func startIPC() {
+	l, err := listenIPC()
+	if err != nil {
+		log.Printf("ipc: %v", err)
+		return
+	}
+	log.Printf("ipc: listening on %s", ipcAddress())
+	go serveIPC(l)
+}
+
+// ipcRetry is the pause after a failed Accept, so a lasting failure doesn't
+// spin.
+var ipcRetry = 100 * time.Millisecond
+
+// serveIPC answers requests until l is closed. Other Accept errors, such as
+// a client that gave up before it was served, only cost that connection.
+func serveIPC(l ipcListener) {
+	for {
+		c, err := l.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+		if err != nil {
+			log.Printf("ipc: accept: %v", err)
+			time.Sleep(ipcRetry)
+			continue
+		}
+		go serveIPCConn(c)
+	}
+}
+
+// serveIPCConn answers the request on one connection and closes it.
+func serveIPCConn(c io.ReadWriteCloser) {
+	defer func() { _ = c.Close() }()
+	if d, ok := c.(interface{ SetReadDeadline(time.Time) error }); ok {
+		_ = d.SetReadDeadline(time.Now().Add(ipcTimeout))
+	}
+	var req ipcRequest
+	var reply ipcReply
+	if err := json.NewDecoder(io.LimitReader(c, ipcMaxRequest)).Decode(&req); err != nil {
+		log.Printf("ipc: bad request: %v", err)
+		reply.Error = "bad request: " + err.Error()
+	} else if req.Version != ipcVersion {
+		log.Printf("ipc: protocol version %d, want %d", req.Version, ipcVersion)
+		reply.Error = fmt.Sprintf("protocol version %d not supported, running instance speaks %d", req.Version, ipcVersion)
+	} else {
+		reply.cliReply = handleRequest(req.cliRequest, false)
+	}
+	reply.Version = ipcVersion
+	if err := json.NewEncoder(c).Encode(reply); err != nil {
+		log.Printf("ipc: reply: %v", err)
+	}
+}
+
+// ipcClient sends requests to the running instance through dial, which
+// returns errNeedsInstance when there is none.
+type ipcClient struct {
+	dial func() (io.ReadWriteCloser, error)
+}
+
+// do sends req and waits for the reply.
+func (cl ipcClient) do(req cliRequest) (cliReply, error) {
+	c, err := cl.dial()
+	if err != nil {
+		return cliReply{}, err
+	}
+	defer func() { _ = c.Close() }()
+	if err := json.NewEncoder(c).Encode(ipcRequest{Version: ipcVersion, cliRequest: req}); err != nil {
+		return cliReply{}, fmt.Errorf("send to running instance: %w", err)
+	}
+	var reply ipcReply
+	if err := json.NewDecoder(c).Decode(&reply); err != nil {
+		return cliReply{}, fmt.Errorf("reply from running instance: %w", err)
+	}
+	if reply.Version != ipcVersion && reply.Error == "" {
+		return cliReply{}, fmt.Errorf("running instance speaks protocol version %d, want %d; restart it", reply.Version, ipcVersion)
+	}
+	return reply.cliReply, nil
+}
+
+// forwardRequest sends req to the running instance. It returns
+// errNeedsInstance when there is none.
+func forwardRequest(req cliRequest) (cliReply, error) {
+	return ipcClient{dial: dialIPC}.do(req)
+}
@@ -0,0 +1,50 @@
+//go:build !windows
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// ipcAddress is the Unix socket of the running instance, private to the user.
+func ipcAddress() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "monibright.sock")
+	}
+	return filepath.Join(os.TempDir(), "monibright-"+strconv.Itoa(os.Getuid())+".sock")
+}
+
+type unixListener struct{ net.Listener }
+
+func (l unixListener) Accept() (io.ReadWriteCloser, error) { return l.Listener.Accept() }
+
+func listenIPC() (ipcListener, error) {
+	path := ipcAddress()
+	if c, err := net.Dial("unix", path); err == nil {
+		_ = c.Close()
+		return nil, fmt.Errorf("%s: another instance is listening", path)
+	}
+	_ = os.Remove(path) // stale socket from a crashed instance
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		_ = l.Close()
+		return nil, err
+	}
+	return unixListener{l}, nil
+}
+
+func dialIPC() (io.ReadWriteCloser, error) {
+	c, err := net.Dial("unix", ipcAddress())
+	if err != nil {
+		return nil, errNeedsInstance // no socket, or a stale one
+	}
+	return c, nil
+}
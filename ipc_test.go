@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alex-vit/monibright/monitor"
+)
+
+// pipeClient returns a client served in-process by serveIPCConn.
+func pipeClient() ipcClient {
+	return ipcClient{dial: func() (io.ReadWriteCloser, error) {
+		client, server := net.Pipe()
+		go serveIPCConn(server)
+		return client, nil
+	}}
+}
+
+func TestIPCCommands(t *testing.T) {
+	a, b := monitor.NewFakeMonitor(0, 50), monitor.NewFakeMonitor(1, 40)
+	useFakeBackend(t, a, b)
+	useProfiles(t, profile{Name: "Reading", Temp: intp(4500), profileLevels: profileLevels{Brightness: intp(35)}})
+	prevTemp := currentColorTemp
+	t.Cleanup(func() { currentColorTemp = prevTemp })
+	cl := pipeClient()
+
+	steps := []struct {
+		req   cliRequest
+		check func(*cliStatus) bool
+	}{
+		{cliRequest{Command: "set", Level: 80, Monitor: "0"}, func(st *cliStatus) bool {
+			return len(st.Monitors) == 1 && st.Monitors[0].Brightness == 80
+		}},
+		{cliRequest{Command: "temp", Temp: 5200}, func(st *cliStatus) bool { return st.Temp == 5200 }},
+		{cliRequest{Command: "profile", Profile: "reading"}, func(st *cliStatus) bool {
+			return st.Temp == 4500 && st.Monitors[0].Brightness == 35 && st.Monitors[1].Brightness == 35
+		}},
+		{cliRequest{Command: "show"}, func(*cliStatus) bool { return true }},
+		{cliRequest{Command: "get"}, func(st *cliStatus) bool { return len(st.Monitors) == 2 && !st.AutoColor }},
+	}
+	for _, s := range steps {
+		reply, err := cl.do(s.req)
+		if err != nil {
+			t.Fatalf("%s: %v", s.req.Command, err)
+		}
+		if reply.Error != "" || reply.Status == nil || !s.check(reply.Status) {
+			t.Errorf("%s: reply %+v", s.req.Command, reply)
+		}
+	}
+
+	reply, err := cl.do(cliRequest{Command: "profile", Profile: "Missing"})
+	if err != nil || !strings.Contains(reply.Error, "no profile") {
+		t.Errorf("missing profile: reply %+v, err %v", reply, err)
+	}
+}
+
+// scriptedConn is a connection with a fixed request that records the reply.
+type scriptedConn struct {
+	in  io.Reader
+	out bytes.Buffer
+}
+
+func (c *scriptedConn) Read(p []byte) (int, error)  { return c.in.Read(p) }
+func (c *scriptedConn) Write(p []byte) (int, error) { return c.out.Write(p) }
+func (c *scriptedConn) Close() error                { return nil }
+
+func TestIPCBadRequests(t *testing.T) {
+	useFakeBackend(t, monitor.NewFakeMonitor(0, 50))
+	tests := []struct {
+		name, req, wantErr string
+	}{
+		{"newer client", `{"v": 2, "command": "get"}`, "protocol version 2"},
+		{"unversioned", `{"command": "get"}`, "protocol version 0"},
+		{"truncated", `{"v": 1, "command": `, "bad request"},
+		{"oversized", `{"v": 1, "command": "` + strings.Repeat("x", ipcMaxRequest) + `"}`, "bad request"},
+	}
+	for _, tt := range tests {
+		c := &scriptedConn{in: strings.NewReader(tt.req)}
+		serveIPCConn(c)
+		var reply ipcReply
+		if err := json.Unmarshal(c.out.Bytes(), &reply); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if reply.Version != ipcVersion || !strings.Contains(reply.Error, tt.wantErr) || reply.Status != nil {
+			t.Errorf("%s: reply %+v", tt.name, reply)
+		}
+	}
+}
+
+func TestIPCClientVersionMismatch(t *testing.T) {
+	cl := ipcClient{dial: func() (io.ReadWriteCloser, error) {
+		return &scriptedConn{in: strings.NewReader(`{"v": 2, "status": {"monitors": []}}`)}, nil
+	}}
+	if _, err := cl.do(cliRequest{Command: "get"}); err == nil || !strings.Contains(err.Error(), "version 2") {
+		t.Errorf("err = %v", err)
+	}
+}
+
+// flakyListener fails its first Accept, then serves conns, then reports
+// itself closed.
+type flakyListener struct {
+	failed bool
+	conns  []io.ReadWriteCloser
+}
+
+func (l *flakyListener) Accept() (io.ReadWriteCloser, error) {
+	if !l.failed {
+		l.failed = true
+		return nil, errors.New("client went away")
+	}
+	if len(l.conns) == 0 {
+		return nil, net.ErrClosed
+	}
+	c := l.conns[0]
+	l.conns = l.conns[1:]
+	return c, nil
+}
+
+func (l *flakyListener) Close() error { return nil }
+
+func TestServeIPCSurvivesAcceptError(t *testing.T) {
+	useFakeBackend(t, monitor.NewFakeMonitor(0, 50))
+	prevRetry := ipcRetry
+	t.Cleanup(func() { ipcRetry = prevRetry })
+	ipcRetry = time.Millisecond
+
+	c := &scriptedConn{in: strings.NewReader(`{"v": 1, "command": "get"}`)}
+	served := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		serveIPC(&flakyListener{conns: []io.ReadWriteCloser{closeNotifier{c, served}}})
+		close(done)
+	}()
+	select {
+	case <-served:
+	case <-time.After(2 * time.Second):
+		t.Fatal("connection after a failed Accept not served")
+	}
+	<-done
+	if !strings.Contains(c.out.String(), `"monitors"`) {
+		t.Errorf("reply = %s", c.out.String())
+	}
+}
+
+// closeNotifier closes done when the connection is closed.
+type closeNotifier struct {
+	*scriptedConn
+	done chan struct{}
+}
+
+func (c closeNotifier) Close() error {
+	close(c.done)
+	return nil
+}
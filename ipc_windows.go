@@ -0,0 +1,172 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// ipcAddress is the named pipe of the running instance, one per user. It is
+// named after the user's SID, which unlike %USERNAME% another user can't
+// take on.
+func ipcAddress() string {
+	sid, err := userSID()
+	if err != nil {
+		return `\\.\pipe\MoniBright`
+	}
+	return `\\.\pipe\MoniBright-` + sid.String()
+}
+
+// userSID is the SID of the user running this process.
+var userSID = sync.OnceValues(func() (*windows.SID, error) {
+	tu, err := windows.GetCurrentProcessToken().GetTokenUser()
+	if err != nil {
+		return nil, err
+	}
+	return tu.User.Sid.Copy()
+})
+
+// pipeListener serves one pipe instance per connection. next is the instance
+// waiting for the next client. Instances are overlapped, so a connection's
+// read deadline works, and only the user may open them.
+type pipeListener struct {
+	name    string
+	sa      *windows.SecurityAttributes
+	next    windows.Handle
+	connect windows.Overlapped // for ConnectNamedPipe; Accept is not concurrent
+	closed  atomic.Bool
+}
+
+func listenIPC() (ipcListener, error) {
+	sid, err := userSID()
+	if err != nil {
+		return nil, err
+	}
+	sd, err := windows.SecurityDescriptorFromString("D:P(A;;GA;;;" + sid.String() + ")")
+	if err != nil {
+		return nil, err
+	}
+	l := &pipeListener{name: ipcAddress(), sa: &windows.SecurityAttributes{SecurityDescriptor: sd}}
+	l.sa.Length = uint32(unsafe.Sizeof(*l.sa))
+	if l.connect.HEvent, err = windows.CreateEvent(nil, 1, 0, nil); err != nil {
+		return nil, err
+	}
+	h, err := l.createPipe(true)
+	if err != nil {
+		_ = windows.CloseHandle(l.connect.HEvent)
+		return nil, err
+	}
+	l.next = h
+	return l, nil
+}
+
+// createPipe creates a pipe instance. The first one claims the name, so
+// another process can't already be serving it.
+func (l *pipeListener) createPipe(first bool) (windows.Handle, error) {
+	name, err := windows.UTF16PtrFromString(l.name)
+	if err != nil {
+		return windows.InvalidHandle, err
+	}
+	flags := uint32(windows.PIPE_ACCESS_DUPLEX | windows.FILE_FLAG_OVERLAPPED)
+	if first {
+		flags |= windows.FILE_FLAG_FIRST_PIPE_INSTANCE
+	}
+	mode := uint32(windows.PIPE_TYPE_BYTE | windows.PIPE_READMODE_BYTE | windows.PIPE_WAIT | windows.PIPE_REJECT_REMOTE_CLIENTS)
+	return windows.CreateNamedPipe(name, flags, mode, windows.PIPE_UNLIMITED_INSTANCES, 4096, 4096, 0, l.sa)
+}
+
+// waitClient waits for a client to open the pipe instance h.
+func (l *pipeListener) waitClient(h windows.Handle) error {
+	err := windows.ConnectNamedPipe(h, &l.connect)
+	switch {
+	case errors.Is(err, windows.ERROR_IO_PENDING):
+		var n uint32
+		return windows.GetOverlappedResult(h, &l.connect, &n, true)
+	case errors.Is(err, windows.ERROR_PIPE_CONNECTED):
+		return nil
+	}
+	return err
+}
+
+// Accept waits for the next client. After an error, next is recreated on
+// the following call, so one failed instance doesn't stop the server.
+func (l *pipeListener) Accept() (io.ReadWriteCloser, error) {
+	if l.closed.Load() {
+		return nil, net.ErrClosed
+	}
+	if l.next == windows.InvalidHandle {
+		h, err := l.createPipe(false)
+		if err != nil {
+			return nil, err
+		}
+		l.next = h
+	}
+	h := l.next
+	if err := l.waitClient(h); err != nil {
+		if l.closed.Load() {
+			return nil, net.ErrClosed
+		}
+		_ = windows.CloseHandle(h)
+		l.next = windows.InvalidHandle
+		return nil, err
+	}
+	next, err := l.createPipe(false)
+	if err != nil {
+		next = windows.InvalidHandle
+	}
+	l.next = next
+	return pipeConn{os.NewFile(uintptr(h), l.name)}, nil
+}
+
+func (l *pipeListener) Close() error {
+	l.closed.Store(true)
+	return windows.CloseHandle(l.next)
+}
+
+// pipeConn is the server end of a connected pipe instance. The overlapped
+// handle goes to the runtime poller, so SetReadDeadline applies.
+type pipeConn struct{ *os.File }
+
+// Close waits for the client to read the reply before disconnecting, which
+// would otherwise discard it. A client that doesn't read within ipcTimeout
+// is disconnected anyway, which also ends the wait.
+func (c pipeConn) Close() error {
+	h := windows.Handle(c.Fd())
+	flushed := make(chan struct{})
+	go func() {
+		_ = windows.FlushFileBuffers(h)
+		close(flushed)
+	}()
+	select {
+	case <-flushed:
+	case <-time.After(ipcTimeout):
+		log.Printf("ipc: client didn't read its reply, disconnecting")
+	}
+	_ = windows.DisconnectNamedPipe(h)
+	<-flushed
+	return c.File.Close()
+}
+
+func dialIPC() (io.ReadWriteCloser, error) {
+	for range 10 {
+		f, err := os.OpenFile(ipcAddress(), os.O_RDWR, 0)
+		if err == nil {
+			return f, nil
+		}
+		if !errors.Is(err, windows.ERROR_PIPE_BUSY) {
+			return nil, errNeedsInstance
+		}
+		time.Sleep(100 * time.Millisecond) // all instances busy
+	}
+	return nil, errors.New("running instance is busy")
+}
@@ -1,58 +1,40 @@
+//go:build windows
+
 package main
 
 import (
+	"errors"
 	"fmt"
-	"io"
 	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"syscall"
-	"time"
 	"unsafe"
 
 	"github.com/alex-vit/monibright/icon"
+	"github.com/alex-vit/monibright/monitor"
 	"github.com/energye/systray"
-	"github.com/niluan304/ddcci"
 	"golang.org/x/sys/windows/registry"
 )
 
 var kernel32 = syscall.NewLazyDLL("kernel32.dll")
-var procCreateMutexW = kernel32.NewProc("CreateMutexW")
-
-var version = ""
-
-var logPath string
-var dataDir string
+var (
+	procCreateMutexW  = kernel32.NewProc("CreateMutexW")
+	procAttachConsole = kernel32.NewProc("AttachConsole")
+)
 
 const (
-	VKNumpad0    = 0x60
 	registryKey  = `Software\Microsoft\Windows\CurrentVersion\Run`
 	registryName = "MoniBright"
 )
 
 var (
-	allMonitors []*ddcci.PhysicalMonitor
-	mAutostart  *systray.MenuItem
+	mAutostart      *systray.MenuItem
+	mAutoBrightness *systray.MenuItem
 )
 
-type isoLogWriter struct{ w io.Writer }
-
-func (lw isoLogWriter) Write(p []byte) (int, error) {
-	return fmt.Fprintf(lw.w, "%s %s", time.Now().Format("2006-01-02 15:04:05"), p)
-}
-
-func displayVersion() string {
-	if version != "" {
-		return version
-	}
-	return "dev"
-}
-
 func main() {
-	name, _ := syscall.UTF16PtrFromString("MoniBrightMutex")
-	procCreateMutexW.Call(0, 0, uintptr(unsafe.Pointer(name))) //nolint:errcheck
-
 	log.SetFlags(0)
 	dataDir = filepath.Join(os.Getenv("LocalAppData"), "MoniBright")
 	_ = os.MkdirAll(dataDir, 0o755)
@@ -60,13 +42,74 @@ func main() {
 	if f, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644); err == nil {
 		log.SetOutput(isoLogWriter{f})
 	}
-	log.Printf("MoniBright %s starting", displayVersion())
 
-	cleanOldBinary()
+	// Command line: forward to the running instance, or run once without a tray.
+	if len(os.Args) > 1 {
+		attachConsole()
+		os.Exit(runCLI(os.Args[1:], os.Stdout, os.Stderr, setupCLI))
+	}
+
+	// A second launch brings up the running instance instead of adding a
+	// duplicate tray icon.
+	name, _ := syscall.UTF16PtrFromString("MoniBrightMutex")
+	ret, _, err := procCreateMutexW.Call(0, 0, uintptr(unsafe.Pointer(name)))
+	if ret != 0 && errors.Is(err, syscall.ERROR_ALREADY_EXISTS) {
+		if _, err := forwardRequest(cliRequest{Command: "show"}); err == nil {
+			log.Printf("already running, showing its slider")
+			return
+		}
+		log.Printf("already running but not answering (%v), starting anyway", err)
+	}
+
+	log.Printf("MoniBright %s starting", displayVersion())
 
 	loadConfig()
+	if checkBoot() {
+		// Hand over to the restored version, letting it take the mutex.
+		if ret != 0 {
+			_ = syscall.CloseHandle(syscall.Handle(ret))
+		}
+		if exe, err := executable(); err == nil {
+			if err := exec.Command(exe).Start(); err != nil { //nolint:noctx
+				log.Printf("starting %s: %v", exe, err)
+			}
+		}
+		return
+	}
 	saveGammaRamp()
-	systray.Run(onReady, func() { restoreGammaRamp() })
+	systray.Run(onReady, func() {
+		stopMQTT()
+		restoreGammaRamp()
+	})
+}
+
+// setupCLI prepares this process to run a command when no instance is
+// running.
+func setupCLI() error {
+	loadConfig()
+	backend = monitor.DDCCI{}
+	monitors, err := backend.Enumerate()
+	if err != nil {
+		return fmt.Errorf("monitor enumeration failed: %w", err)
+	}
+	setMonitors(monitors)
+	return nil
+}
+
+// attachConsole connects stdout and stderr to the console the command was
+// run from. The exe is a GUI app with no console of its own; output
+// redirected to a file or pipe works without this.
+func attachConsole() {
+	if h, err := syscall.GetStdHandle(syscall.STD_OUTPUT_HANDLE); err == nil && h != 0 {
+		return
+	}
+	const attachParentProcess = uintptr(^uint32(0))
+	if ret, _, _ := procAttachConsole.Call(attachParentProcess); ret == 0 {
+		return
+	}
+	if f, err := os.OpenFile("CONOUT$", os.O_WRONLY, 0); err == nil {
+		os.Stdout, os.Stderr = f, f
+	}
 }
 
 func onReady() {
@@ -81,34 +124,34 @@ func onReady() {
 	})
 	systray.AddSeparator()
 
-	go autoUpdate()
+	c := cfgSnapshot()
+	startIPC()
+	startAPI()
+	// Hooks subscribe first, so they see every event, including an update
+	// found right after start.
+	startHooks()
 
-	sysMonitors, err := ddcci.NewSystemMonitors()
-	log.Printf("enumerated %d system monitors (err=%v)", len(sysMonitors), err)
-	if err != nil || len(sysMonitors) == 0 {
+	backend = monitor.DDCCI{}
+	monitors, err := backend.Enumerate()
+	if err != nil {
+		log.Printf("monitor enumeration failed: %v", err)
 		mErr := systray.AddMenuItem("No monitors found", "")
 		mErr.Disable()
 		systray.AddSeparator()
 		addQuit()
+		trayReady()
 		return
 	}
-
-	for i := range sysMonitors {
-		m, err := ddcci.NewPhysicalMonitor(&sysMonitors[i])
-		if err != nil {
-			log.Printf("monitor %d: %v", i, err)
-			continue
-		}
-		allMonitors = append(allMonitors, m)
-	}
-	log.Printf("initialized %d physical monitors", len(allMonitors))
+	setMonitors(monitors)
+	log.Printf("initialized %d physical monitors", len(monitors))
 	go runSlider()
 	go runSettings()
-	if len(allMonitors) == 0 {
+	if len(monitors) == 0 {
 		mErr := systray.AddMenuItem("No usable monitors", "")
 		mErr.Disable()
 		systray.AddSeparator()
 		addQuit()
+		trayReady()
 		return
 	}
 
@@ -122,6 +165,33 @@ func onReady() {
 	// Settings
 	systray.AddMenuItem("Settings...", "Open settings").Click(func() { showSettings() })
 
+	// Input source submenu, filled in once monitor capabilities are read.
+	mInput := systray.AddMenuItem("Input source", "Switch monitor input")
+	mInput.Hide()
+	go func() {
+		loadCapabilities(monitors)
+		buildInputMenu(mInput)
+	}()
+
+	// Profiles submenu
+	if len(c.Profiles) > 0 {
+		mProfiles := systray.AddMenuItem("Profiles", "Apply a saved profile")
+		for _, p := range c.Profiles {
+			mProfiles.AddSubMenuItem(p.Name, "Apply "+p.Name).Click(func() {
+				go func() {
+					if err := applyProfile(p.Name); err != nil {
+						log.Printf("profile menu: %v", err)
+					}
+				}()
+			})
+		}
+	}
+
+	// Auto brightness toggle
+	mAutoBrightness = systray.AddMenuItemCheckbox("Auto brightness",
+		"Follow the sun between day and night brightness", c.AutoBrightnessEnabled)
+	mAutoBrightness.Click(toggleAutoBrightness)
+
 	// Autostart toggle
 	mAutostart = systray.AddMenuItem("Start with Windows", "Launch MoniBright at login")
 	if isAutostartEnabled() {
@@ -134,125 +204,99 @@ func onReady() {
 
 	// Hotkeys: Win+Numpad1=10%, Win+Numpad2=20%, ..., Win+Numpad0=100%
 	var hkeys [][2]int
-	var levels []int
+	var actions []func()
 	for i := 0; i <= 9; i++ {
 		hkeys = append(hkeys, [2]int{modWin, VKNumpad0 + i})
 		level := i * 10
 		if level == 0 {
 			level = 100
 		}
-		levels = append(levels, level)
+		actions = append(actions, func() { manualBrightness(level) })
+	}
+	// Config-defined input source hotkeys.
+	for _, hk := range c.InputHotkeys {
+		mods, vk, err := parseHotkey(hk.Key)
+		if err != nil {
+			log.Printf("input hotkey: %v", err)
+			continue
+		}
+		hkeys = append(hkeys, [2]int{mods, vk})
+		actions = append(actions, func() {
+			if err := switchInput(hk.Monitor, hk.Input); err != nil {
+				log.Printf("input hotkey %s: %v", hk.Key, err)
+			}
+		})
+	}
+	// Config-defined profile hotkeys.
+	for _, p := range c.Profiles {
+		if p.Hotkey == "" {
+			continue
+		}
+		mods, vk, err := parseHotkey(p.Hotkey)
+		if err != nil {
+			log.Printf("profile hotkey: %v", err)
+			continue
+		}
+		hkeys = append(hkeys, [2]int{mods, vk})
+		actions = append(actions, func() {
+			go func() {
+				if err := applyProfile(p.Name); err != nil {
+					log.Printf("profile hotkey %s: %v", p.Hotkey, err)
+				}
+			}()
+		})
 	}
 	go func() {
 		if err := registerHotkeys(hkeys, func(id int) {
-			setBrightness(levels[id])
+			actions[id]()
 		}); err != nil {
 			log.Printf("hotkey registration error: %v", err)
 		}
 	}()
 
-	lastManualTemp = cfg.ManualTemp
-	currentColorTemp = cfg.ManualTemp
-	if cfg.AutoColorEnabled {
+	lastManualTemp = c.ManualTemp
+	setColorTemp(c.ManualTemp)
+	if c.AutoColorEnabled {
 		go startAutoColor(0)
-	} else if cfg.ManualTemp != 6500 {
-		applyColorTemp(cfg.ManualTemp)
-		syncColorTempSlider(cfg.ManualTemp)
+	} else if c.ManualTemp != 6500 {
+		applyColorTemp(c.ManualTemp)
+		syncColorTempSlider(c.ManualTemp)
 	}
+	if c.AutoBrightnessEnabled {
+		startAutoBrightness()
+	}
+	startMQTT()
+	startHue()
+	trayReady()
 }
 
-func updateIcon(level int) {
-	systray.SetIcon(icon.Generate(level))
-	systray.SetTooltip(fmt.Sprintf("MoniBright — %d%%", level))
+// trayReady runs once the tray is up: this start counts as healthy, and
+// only then may an update replace the binary.
+func trayReady() {
+	bootOK()
+	go autoUpdate()
 }
 
-func refreshCheck() {
-	_, current, _, err := allMonitors[0].GetBrightness()
-	if err != nil {
-		log.Printf("GetBrightness failed: %v", err)
-		return
-	}
-	log.Printf("GetBrightness: current=%d", current)
-
-	// DDC/CI handles go stale after monitor sleep/wake and return 0.
-	// Re-enumerate monitors and retry once.
-	if current == 0 {
-		log.Printf("brightness=0 is suspicious, re-enumerating monitors")
-		if refreshMonitors() {
-			_, current, _, err = allMonitors[0].GetBrightness()
-			if err != nil {
-				log.Printf("GetBrightness retry failed: %v", err)
-				return
-			}
-			log.Printf("GetBrightness retry: current=%d", current)
-		}
-	}
-
-	updateIcon(current)
+func showMenu(menu systray.IMenu) {
+	refreshCheck()
+	syncInputMenu()
+	menu.ShowMenu()
 }
 
-func refreshMonitors() bool {
-	sysMonitors, err := ddcci.NewSystemMonitors()
-	if err != nil || len(sysMonitors) == 0 {
-		log.Printf("re-enumerate failed: %d monitors, err=%v", len(sysMonitors), err)
-		return false
-	}
-	var monitors []*ddcci.PhysicalMonitor
-	for i := range sysMonitors {
-		m, err := ddcci.NewPhysicalMonitor(&sysMonitors[i])
-		if err != nil {
-			log.Printf("re-enumerate monitor %d: %v", i, err)
-			continue
-		}
-		monitors = append(monitors, m)
-	}
-	if len(monitors) == 0 {
-		log.Printf("re-enumerate: no usable monitors")
-		return false
-	}
-	allMonitors = monitors
-	log.Printf("re-enumerated %d physical monitors", len(allMonitors))
-	return true
+func toggleAutoBrightness() {
+	setAutoBrightness(!mAutoBrightness.Checked())
 }
 
-func setBrightness(level int) {
-	log.Printf("setting brightness to %d%%", level)
-
-	setAll := func() {
-		for i, m := range allMonitors {
-			if err := m.SetBrightness(level); err != nil {
-				log.Printf("monitor %d: SetBrightness(%d) error: %v", i, level, err)
-			} else {
-				log.Printf("monitor %d: SetBrightness(%d) ok", i, level)
-			}
-		}
-	}
-
-	setAll()
-
-	// Verify the write took effect. Stale DDC/CI handles after sleep/wake
-	// silently fail: SetBrightness returns nil but the monitor doesn't change.
-	// Re-enumerate for fresh handles and retry.
-	_, cur, _, err := allMonitors[0].GetBrightness()
-	log.Printf("post-set verify: current=%d expected=%d err=%v", cur, level, err)
-	diff := cur - level
-	if diff < 0 {
-		diff = -diff
+// syncAutoBrightnessMenu updates the tray checkbox to match the config.
+func syncAutoBrightnessMenu() {
+	if mAutoBrightness == nil {
+		return
 	}
-	if err != nil || diff > 5 {
-		log.Printf("stale handle detected, refreshing monitors and retrying")
-		if refreshMonitors() {
-			setAll()
-		}
+	if cfgSnapshot().AutoBrightnessEnabled {
+		mAutoBrightness.Check()
+	} else {
+		mAutoBrightness.Uncheck()
 	}
-
-	updateIcon(level)
-	syncSlider(level)
-}
-
-func showMenu(menu systray.IMenu) {
-	refreshCheck()
-	menu.ShowMenu()
 }
 
 func toggleAutostart() {
@@ -0,0 +1,50 @@
+//go:build linux
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/alex-vit/monibright/monitor"
+)
+
+// Linux has no tray UI; the binary runs the command-line interface against
+// all monitors through the i2c-dev backend, using the same code path as the
+// tray app. For compatibility, no arguments means get and a bare number
+// means set.
+func main() {
+	log.SetFlags(0)
+	log.SetOutput(io.Discard)
+	if os.Getenv("MONIBRIGHT_DEBUG") != "" {
+		log.SetOutput(isoLogWriter{os.Stderr})
+	}
+
+	args := os.Args[1:]
+	if len(args) == 0 {
+		args = []string{"get"}
+	} else if _, err := strconv.Atoi(args[0]); err == nil && len(args) == 1 {
+		args = []string{"set", args[0]}
+	}
+	os.Exit(runCLI(args, os.Stdout, os.Stderr, setupCLI))
+}
+
+// setupCLI loads the config and enumerates monitors to run a command in this
+// process.
+func setupCLI() error {
+	if dir, err := os.UserConfigDir(); err == nil {
+		dataDir = filepath.Join(dir, "monibright")
+	}
+	loadConfig()
+	backend = monitor.I2C{}
+	monitors, err := backend.Enumerate()
+	if err != nil {
+		return fmt.Errorf("monitor enumeration failed: %w", err)
+	}
+	setMonitors(monitors)
+	return nil
+}
@@ -0,0 +1,13 @@
+//go:build !windows && !linux
+
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func main() {
+	fmt.Fprintln(os.Stderr, "MoniBright requires Windows or Linux")
+	os.Exit(1)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// updatePublicKey is the minisign public key releases are signed with, set
+// at build time with -ldflags "-X main.updatePublicKey=RWQ...". Builds
+// without one refuse every update.
+var updatePublicKey = ""
+
+var errUnsigned = errors.New("release is not signed")
+
+// minisignKey is a minisign Ed25519 public key.
+type minisignKey struct {
+	id  [8]byte
+	key ed25519.PublicKey
+}
+
+// parseMinisignKey parses a public key as printed by minisign: the base64
+// line, optionally preceded by its "untrusted comment:" line.
+func parseMinisignKey(s string) (*minisignKey, error) {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[len(lines)-1]))
+	if err != nil || len(b) != 42 || string(b[:2]) != "Ed" {
+		return nil, errors.New("malformed minisign public key")
+	}
+	k := &minisignKey{key: ed25519.PublicKey(b[10:])}
+	copy(k.id[:], b[2:10])
+	return k, nil
+}
+
+// verifyMinisign checks the file at path against a minisign signature file,
+// including the signature over its trusted comment. Both the prehashed
+// ("ED", the default since minisign 0.10) and legacy ("Ed") formats are
+// accepted.
+func verifyMinisign(k *minisignKey, path string, sigFile []byte) error {
+	lines := strings.Split(strings.ReplaceAll(string(sigFile), "\r\n", "\n"), "\n")
+	if len(lines) < 4 || !strings.HasPrefix(lines[2], "trusted comment: ") {
+		return errors.New("malformed signature file")
+	}
+	sig, err := base64.StdEncoding.DecodeString(lines[1])
+	if err != nil || len(sig) != 74 {
+		return errors.New("malformed signature")
+	}
+	global, err := base64.StdEncoding.DecodeString(lines[3])
+	if err != nil || len(global) != ed25519.SignatureSize {
+		return errors.New("malformed trusted comment signature")
+	}
+	if !bytes.Equal(sig[2:10], k.id[:]) {
+		return fmt.Errorf("signed with key %X, want %X", sig[2:10], k.id)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var msg []byte
+	switch string(sig[:2]) {
+	case "ED":
+		h, _ := blake2b.New512(nil)
+		if _, err := io.Copy(h, f); err != nil {
+			return err
+		}
+		msg = h.Sum(nil)
+	case "Ed":
+		if msg, err = io.ReadAll(f); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown signature algorithm %q", sig[:2])
+	}
+	if !ed25519.Verify(k.key, msg, sig[10:]) {
+		return errors.New("signature does not match")
+	}
+
+	trusted := strings.TrimPrefix(lines[2], "trusted comment: ")
+	if !ed25519.Verify(k.key, slices.Concat(sig[10:], []byte(trusted)), global) {
+		return errors.New("trusted comment signature does not match")
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package monitor
+
+import (
+	"fmt"
+	"time"
+)
+
+// Write is one VCP write in a batch.
+type Write struct {
+	Monitor Monitor
+	Code    byte
+	Value   int
+}
+
+// ApplyAll performs writes in order as one unit: a target that can't be read
+// fails the batch before anything changes, and a failed write undoes the
+// writes already made.
+func ApplyAll(writes []Write) error {
+	return Transition(writes, 1, 0)
+}
+
+// Transition is ApplyAll stepping continuous features (brightness, contrast)
+// from their current values to the new ones over steps frames, pause apart.
+// Other features, like the input source, are written on the last frame.
+//
+// Current values are read before the first write. If any write fails, every
+// feature written so far is restored to its value from before the batch, in
+// reverse order, and the write error is returned.
+func Transition(writes []Write, steps int, pause time.Duration) error {
+	prev := make([]int, len(writes))
+	for i, w := range writes {
+		cur, _, err := w.Monitor.GetVCP(w.Code)
+		if err != nil {
+			return fmt.Errorf("%s: read VCP 0x%02X: %w", w.Monitor.Name(), w.Code, err)
+		}
+		prev[i] = cur
+	}
+
+	steps = max(steps, 1)
+	written := make([]bool, len(writes))
+	for frame := 1; frame <= steps; frame++ {
+		if frame > 1 {
+			time.Sleep(pause)
+		}
+		for i, w := range writes {
+			value := w.Value
+			if frame < steps {
+				if !continuous(w.Code) {
+					continue
+				}
+				value = prev[i] + (w.Value-prev[i])*frame/steps
+			}
+			if err := w.Monitor.SetVCP(w.Code, value); err != nil {
+				for j := len(writes) - 1; j >= 0; j-- {
+					if written[j] {
+						_ = writes[j].Monitor.SetVCP(writes[j].Code, prev[j])
+					}
+				}
+				return fmt.Errorf("%s: set VCP 0x%02X to %d: %w", w.Monitor.Name(), w.Code, value, err)
+			}
+			written[i] = true
+		}
+	}
+	return nil
+}
+
+// continuous reports whether code is a continuous feature that can be
+// stepped through intermediate values.
+func continuous(code byte) bool {
+	return code == VCPBrightness || code == VCPContrast
+}
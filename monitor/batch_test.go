@@ -0,0 +1,77 @@
+package monitor
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestApplyAll(t *testing.T) {
+	a, b := NewFakeMonitor(0, 50), NewFakeMonitor(1, 40)
+	a.VCP[VCPInputSource] = 0x0F
+	err := ApplyAll([]Write{
+		{a, VCPBrightness, 80},
+		{a, VCPInputSource, 0x11},
+		{b, VCPBrightness, 20},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.VCP[VCPBrightness] != 80 || a.VCP[VCPInputSource] != 0x11 || b.VCP[VCPBrightness] != 20 {
+		t.Errorf("a = %v, b = %v", a.VCP, b.VCP)
+	}
+}
+
+func TestApplyAllRollback(t *testing.T) {
+	a, b := NewFakeMonitor(0, 50), NewFakeMonitor(1, 40)
+	a.VCP[VCPContrast] = 70
+	b.SetErr = errors.New("bus error")
+	err := ApplyAll([]Write{
+		{a, VCPBrightness, 80},
+		{a, VCPContrast, 60},
+		{b, VCPBrightness, 20},
+	})
+	if err == nil {
+		t.Fatal("no error")
+	}
+	want := []FakeSet{{VCPBrightness, 80}, {VCPContrast, 60}, {VCPContrast, 70}, {VCPBrightness, 50}}
+	if !slices.Equal(a.Sets, want) {
+		t.Errorf("a writes = %v, want %v", a.Sets, want)
+	}
+}
+
+func TestApplyAllUnreadable(t *testing.T) {
+	a, b := NewFakeMonitor(0, 50), NewFakeMonitor(1, 40)
+	err := ApplyAll([]Write{
+		{a, VCPBrightness, 80},
+		{b, VCPContrast, 60}, // b has no contrast
+	})
+	if err == nil {
+		t.Fatal("no error")
+	}
+	if len(a.Sets) != 0 || len(b.Sets) != 0 {
+		t.Errorf("wrote before failing: a %v, b %v", a.Sets, b.Sets)
+	}
+}
+
+func TestTransition(t *testing.T) {
+	a := NewFakeMonitor(0, 20)
+	a.VCP[VCPInputSource] = 0x0F
+	err := Transition([]Write{
+		{a, VCPBrightness, 60},
+		{a, VCPInputSource, 0x11},
+	}, 4, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []FakeSet{
+		{VCPBrightness, 30},
+		{VCPBrightness, 40},
+		{VCPBrightness, 50},
+		{VCPBrightness, 60},
+		{VCPInputSource, 0x11},
+	}
+	if !slices.Equal(a.Sets, want) {
+		t.Errorf("writes = %v, want %v", a.Sets, want)
+	}
+}
@@ -0,0 +1,193 @@
+package monitor
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Capabilities is a parsed MCCS capabilities string, e.g.
+//
+//	(prot(monitor)type(LCD)model(U2415)cmds(01 02 03 07 0C E3 F3)
+//	 vcp(02 04 10 12 14(05 08 0B) 60(0F 11 12))mccs_ver(2.1))
+type Capabilities struct {
+	Protocol    string // prot(...), usually "monitor"
+	Type        string // type(...), e.g. "LCD"
+	Model       string // model(...)
+	MCCSVersion string // mccs_ver(...), e.g. "2.1"
+	Commands    []byte // cmds(...): supported DDC/CI opcodes
+
+	// VCP maps each supported VCP code to its allowed values. Continuous
+	// features (brightness, contrast) have no value list.
+	VCP map[byte][]int
+}
+
+// Supports reports whether the monitor lists the VCP code.
+func (c *Capabilities) Supports(code byte) bool {
+	_, ok := c.VCP[code]
+	return ok
+}
+
+// Values returns the allowed values for a non-continuous VCP code, or nil.
+func (c *Capabilities) Values(code byte) []int {
+	return c.VCP[code]
+}
+
+// ParseCapabilities parses an MCCS capabilities string. Real monitors emit
+// plenty of malformed strings, so the parser is lenient: the outer
+// parentheses are optional, unbalanced or stray parentheses are tolerated,
+// hex bytes may run together without spaces ("0210(01 02)12"), and unknown
+// sections are ignored. It fails only when no vcp(...) section is found.
+func ParseCapabilities(s string) (*Capabilities, error) {
+	s = strings.TrimSpace(strings.Trim(s, "\x00"))
+	if strings.HasPrefix(s, "(") {
+		s = s[1:]
+	}
+
+	caps := &Capabilities{}
+	foundVCP := false
+	for name, body := range capsSections(s) {
+		switch strings.ToLower(name) {
+		case "prot":
+			caps.Protocol = strings.TrimSpace(body)
+		case "type":
+			caps.Type = strings.TrimSpace(body)
+		case "model":
+			caps.Model = strings.TrimSpace(body)
+		case "mccs_ver":
+			caps.MCCSVersion = strings.TrimSpace(body)
+		case "cmds":
+			for _, t := range parseHexList(body) {
+				caps.Commands = append(caps.Commands, t.code)
+			}
+		case "vcp":
+			foundVCP = true
+			if caps.VCP == nil {
+				caps.VCP = map[byte][]int{}
+			}
+			for _, t := range parseHexList(body) {
+				caps.VCP[t.code] = append(caps.VCP[t.code], t.values...)
+			}
+		}
+	}
+	if !foundVCP {
+		return nil, errors.New("capabilities: no vcp section")
+	}
+	return caps, nil
+}
+
+// capsSections yields the top-level name(body) sections of a capabilities
+// string. A section whose closing parenthesis is missing runs to the end of
+// the string.
+func capsSections(s string) func(yield func(name, body string) bool) {
+	return func(yield func(name, body string) bool) {
+		for s != "" {
+			open := strings.IndexByte(s, '(')
+			if open < 0 {
+				return
+			}
+			name := strings.TrimSpace(s[:open])
+			// Drop stray ')' and whitespace left over from malformed input.
+			name = strings.TrimLeft(name, ") \t\r\n")
+			if i := strings.LastIndexAny(name, " \t\r\n)"); i >= 0 {
+				name = name[i+1:]
+			}
+
+			depth, end := 0, len(s)
+			for i := open; i < len(s); i++ {
+				if s[i] == '(' {
+					depth++
+				} else if s[i] == ')' {
+					depth--
+					if depth == 0 {
+						end = i
+						break
+					}
+				}
+			}
+			body := s[open+1 : end]
+			if end < len(s) {
+				s = s[end+1:]
+			} else {
+				s = ""
+			}
+			if name != "" && !yield(name, body) {
+				return
+			}
+		}
+	}
+}
+
+type hexEntry struct {
+	code   byte
+	values []int
+}
+
+// parseHexList parses a space-separated list of hex bytes where each byte may
+// be followed by a parenthesised list of values, e.g. "10 14(05 08) 60(0F)".
+// Runs of hex digits without spaces are split into bytes; anything that isn't
+// hex is skipped.
+func parseHexList(s string) []hexEntry {
+	var entries []hexEntry
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case isHex(c):
+			j := i
+			for j < len(s) && isHex(s[j]) {
+				j++
+			}
+			for _, b := range splitHexRun(s[i:j]) {
+				entries = append(entries, hexEntry{code: b})
+			}
+			i = j
+		case c == '(':
+			depth, j := 0, i
+			for ; j < len(s); j++ {
+				if s[j] == '(' {
+					depth++
+				} else if s[j] == ')' {
+					depth--
+					if depth == 0 {
+						break
+					}
+				}
+			}
+			end := min(j, len(s))
+			if n := len(entries); n > 0 {
+				// Nested lists (MCCS 3 sub-values) are flattened.
+				for _, v := range parseHexList(s[i+1 : end]) {
+					for _, x := range append([]int{int(v.code)}, v.values...) {
+						if !slices.Contains(entries[n-1].values, x) {
+							entries[n-1].values = append(entries[n-1].values, x)
+						}
+					}
+				}
+			}
+			i = end + 1
+		default:
+			i++
+		}
+	}
+	return entries
+}
+
+// splitHexRun splits "0210" into 0x02, 0x10. A lone trailing digit is read as
+// a single-digit byte.
+func splitHexRun(run string) []byte {
+	var out []byte
+	for len(run) > 0 {
+		n := min(2, len(run))
+		v, err := strconv.ParseUint(run[:n], 16, 8)
+		if err == nil {
+			out = append(out, byte(v))
+		}
+		run = run[n:]
+	}
+	return out
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
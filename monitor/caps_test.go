@@ -0,0 +1,128 @@
+package monitor
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func readCapsFixture(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "caps", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// TestParseCapabilitiesCorpus parses every fixture in testdata/caps. Files
+// named invalid-* must fail; every other one must yield brightness support.
+func TestParseCapabilitiesCorpus(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "caps", "*.txt"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no fixtures: %v", err)
+	}
+	for _, f := range files {
+		name := filepath.Base(f)
+		t.Run(name, func(t *testing.T) {
+			caps, err := ParseCapabilities(readCapsFixture(t, name))
+			if strings.HasPrefix(name, "invalid-") {
+				if err == nil {
+					t.Errorf("parsed invalid fixture: %+v", caps)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseCapabilities: %v", err)
+			}
+			if !caps.Supports(VCPBrightness) {
+				t.Errorf("brightness (0x10) not supported; vcp = %v", caps.VCP)
+			}
+			if caps.Model == "" {
+				t.Error("empty model")
+			}
+		})
+	}
+}
+
+func TestParseCapabilities(t *testing.T) {
+	caps, err := ParseCapabilities(readCapsFixture(t, "dell-u2415.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if caps.Protocol != "monitor" || caps.Type != "LCD" || caps.Model != "U2415" || caps.MCCSVersion != "2.1" {
+		t.Errorf("header = %q %q %q %q", caps.Protocol, caps.Type, caps.Model, caps.MCCSVersion)
+	}
+	if want := []byte{0x01, 0x02, 0x03, 0x07, 0x0C, 0xE3, 0xF3}; !slices.Equal(caps.Commands, want) {
+		t.Errorf("commands = % x, want % x", caps.Commands, want)
+	}
+	if got := caps.Values(0x60); !slices.Equal(got, []int{0x01, 0x0F, 0x11}) {
+		t.Errorf("input values = %v", got)
+	}
+	if got := caps.Values(0x14); !slices.Equal(got, []int{0x01, 0x04, 0x05, 0x06, 0x08, 0x09, 0x0B, 0x0C}) {
+		t.Errorf("color preset values = %v", got)
+	}
+	if caps.Values(VCPBrightness) != nil {
+		t.Errorf("brightness has values %v, want continuous", caps.Values(VCPBrightness))
+	}
+	if caps.Supports(0x62) {
+		t.Error("audio volume (0x62) reported supported")
+	}
+	if len(caps.VCP) != 30 {
+		t.Errorf("len(VCP) = %d, want 30", len(caps.VCP))
+	}
+}
+
+func TestParseCapabilitiesMalformed(t *testing.T) {
+	tests := []struct {
+		fixture    string
+		model      string
+		mccs       string
+		code       byte
+		wantValues []int
+		absent     byte
+	}{
+		{"acer-compact.txt", "ACER", "2.0", 0x60, []int{0x01, 0x03, 0x0F, 0x11}, 0x62},
+		{"acer-nested-values.txt", "XB271HU", "2.2", 0x60, []int{0x0F, 0x11, 0x12, 0x13}, 0x62},
+		{"benq-no-outer-parens.txt", "BenQ GW2480", "2.2", 0x8D, []int{0x01, 0x02}, 0xE0},
+		{"hp-truncated.txt", "HP 24mh", "", 0xCA, []int{0x01, 0x02}, 0xE0},
+		{"asus-whitespace-stray-paren.txt", "VG27AQ", "2.2", 0x60, []int{0x0F, 0x11, 0x12}, 0x52},
+		{"dell-p2419h-nul.txt", "P2419H", "2.1", 0xF1, []int{0x01, 0x02}, 0x62},
+		{"eizo-lowercase-vcpname.txt", "EV2785", "2.2", 0x60, []int{0x0F, 0x11, 0x12, 0x1B}, 0x52},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			caps, err := ParseCapabilities(readCapsFixture(t, tt.fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if caps.Model != tt.model {
+				t.Errorf("model = %q, want %q", caps.Model, tt.model)
+			}
+			if caps.MCCSVersion != tt.mccs {
+				t.Errorf("mccs_ver = %q, want %q", caps.MCCSVersion, tt.mccs)
+			}
+			if got := caps.Values(tt.code); !slices.Equal(got, tt.wantValues) {
+				t.Errorf("values(0x%02X) = %v, want %v", tt.code, got, tt.wantValues)
+			}
+			if caps.Supports(tt.absent) {
+				t.Errorf("0x%02X reported supported", tt.absent)
+			}
+		})
+	}
+}
+
+func TestParseHexListCompact(t *testing.T) {
+	got := parseHexList("0210(01 02)12 1")
+	want := []hexEntry{{code: 0x02}, {code: 0x10, values: []int{1, 2}}, {code: 0x12}, {code: 0x01}}
+	if len(got) != len(want) {
+		t.Fatalf("parseHexList = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i].code != want[i].code || !slices.Equal(got[i].values, want[i].values) {
+			t.Errorf("entry %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package monitor
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// DDC/CI framing per the VESA DDC/CI and MCCS standards. The monitor listens
+// on 7-bit i2c address 0x37 (0x6E/0x6F on the wire); the host identifies
+// itself as 0x51 in requests, and replies are checksummed against 0x50.
+const (
+	ddcAddr       = 0x37
+	ddcDestAddr   = 0x6E // monitor address as written on the wire
+	ddcHostAddr   = 0x51 // source address in host requests
+	ddcReplyXOR   = 0x50 // checksum seed for monitor replies
+	ddcLengthFlag = 0x80
+
+	opGetVCP      = 0x01
+	opGetVCPReply = 0x02
+	opSetVCP      = 0x03
+	opCapsRequest = 0xF3
+	opCapsReply   = 0xE3
+
+	capsFragmentLen = 32   // max capabilities bytes per reply
+	capsMaxLen      = 4096 // give up on monitors that never send an empty fragment
+)
+
+// Timing from the DDC/CI spec: the monitor needs 40 ms to prepare a reply and
+// 50 ms to apply a write before it accepts the next command.
+const (
+	ddcReplyDelay = 40 * time.Millisecond
+	ddcWriteDelay = 50 * time.Millisecond
+	ddcRetryDelay = 100 * time.Millisecond
+	ddcRetries    = 3
+)
+
+var (
+	// ErrUnsupported is returned when the monitor reports a VCP code as
+	// unsupported.
+	ErrUnsupported = errors.New("unsupported VCP code")
+
+	errNullReply = errors.New("null reply (monitor busy)")
+)
+
+// ddcMonitor speaks DDC/CI over a raw i2c channel that is already bound to
+// the monitor's slave address.
+type ddcMonitor struct {
+	dev   io.ReadWriteCloser
+	id    string
+	name  string
+	edid  []byte
+	sleep func(time.Duration)
+}
+
+func newDDCMonitor(dev io.ReadWriteCloser, id, name string) *ddcMonitor {
+	return &ddcMonitor{dev: dev, id: id, name: name, sleep: time.Sleep}
+}
+
+func (m *ddcMonitor) ID() string   { return m.id }
+func (m *ddcMonitor) Name() string { return m.name }
+func (m *ddcMonitor) EDID() []byte { return m.edid }
+
+func (m *ddcMonitor) Close() error { return m.dev.Close() }
+
+func (m *ddcMonitor) GetVCP(code byte) (current, maxValue int, err error) {
+	for attempt := range ddcRetries {
+		if attempt > 0 {
+			m.sleep(ddcRetryDelay)
+		}
+		var payload []byte
+		payload, err = m.transact([]byte{opGetVCP, code}, 8)
+		if err != nil {
+			continue
+		}
+		current, maxValue, err = decodeVCPReply(code, payload)
+		if err == nil || errors.Is(err, ErrUnsupported) {
+			return current, maxValue, err
+		}
+	}
+	return 0, 0, fmt.Errorf("get VCP 0x%02x: %w", code, err)
+}
+
+func (m *ddcMonitor) SetVCP(code byte, value int) error {
+	if value < 0 || value > 0xFFFF {
+		return fmt.Errorf("set VCP 0x%02x: value %d out of range", code, value)
+	}
+	var err error
+	for attempt := range ddcRetries {
+		if attempt > 0 {
+			m.sleep(ddcRetryDelay)
+		}
+		if _, err = m.dev.Write(encodeRequest([]byte{opSetVCP, code, byte(value >> 8), byte(value)})); err == nil {
+			m.sleep(ddcWriteDelay)
+			return nil
+		}
+	}
+	return fmt.Errorf("set VCP 0x%02x: %w", code, err)
+}
+
+// CapabilitiesString reads the capabilities string in fragments: each request
+// carries an offset, each reply echoes it followed by up to 32 bytes, and an
+// empty fragment marks the end.
+func (m *ddcMonitor) CapabilitiesString() (string, error) {
+	var caps []byte
+	for len(caps) < capsMaxLen {
+		offset := len(caps)
+		var payload []byte
+		var err error
+		for attempt := range ddcRetries {
+			if attempt > 0 {
+				m.sleep(ddcRetryDelay)
+			}
+			payload, err = m.transact([]byte{opCapsRequest, byte(offset >> 8), byte(offset)}, capsFragmentLen+3)
+			if err == nil {
+				err = checkCapsFragment(payload, offset)
+			}
+			if err == nil {
+				break
+			}
+		}
+		if err != nil {
+			return "", fmt.Errorf("capabilities at offset %d: %w", offset, err)
+		}
+		data := payload[3:]
+		if len(data) == 0 {
+			break
+		}
+		caps = append(caps, data...)
+	}
+	return string(bytes.TrimRight(caps, "\x00")), nil
+}
+
+func checkCapsFragment(p []byte, offset int) error {
+	if len(p) < 3 || p[0] != opCapsReply {
+		return fmt.Errorf("unexpected reply % x", p)
+	}
+	if got := int(p[1])<<8 | int(p[2]); got != offset {
+		return fmt.Errorf("reply offset %d, want %d", got, offset)
+	}
+	return nil
+}
+
+// transact writes a request and reads back a reply with up to maxPayload
+// payload bytes.
+func (m *ddcMonitor) transact(request []byte, maxPayload int) ([]byte, error) {
+	if _, err := m.dev.Write(encodeRequest(request)); err != nil {
+		return nil, err
+	}
+	m.sleep(ddcReplyDelay)
+	buf := make([]byte, maxPayload+3) // source, length, payload, checksum
+	n, err := m.dev.Read(buf)
+	if err != nil {
+		return nil, err
+	}
+	return decodeReply(buf[:n])
+}
+
+// encodeRequest frames a host→monitor payload:
+// source address, length|0x80, payload, checksum.
+func encodeRequest(payload []byte) []byte {
+	pkt := make([]byte, 0, len(payload)+3)
+	pkt = append(pkt, ddcHostAddr, ddcLengthFlag|byte(len(payload)))
+	pkt = append(pkt, payload...)
+	return append(pkt, checksum(ddcDestAddr, pkt))
+}
+
+// decodeReply validates a monitor→host frame and returns its payload.
+func decodeReply(buf []byte) ([]byte, error) {
+	if len(buf) < 3 {
+		return nil, fmt.Errorf("short reply (%d bytes)", len(buf))
+	}
+	if buf[0] != ddcDestAddr {
+		return nil, fmt.Errorf("bad reply source address 0x%02x", buf[0])
+	}
+	if buf[1]&ddcLengthFlag == 0 {
+		return nil, fmt.Errorf("bad reply length byte 0x%02x", buf[1])
+	}
+	n := int(buf[1] &^ ddcLengthFlag)
+	if len(buf) < n+3 {
+		return nil, fmt.Errorf("truncated reply: length %d, got %d bytes", n, len(buf))
+	}
+	if want := checksum(ddcReplyXOR, buf[:n+2]); buf[n+2] != want {
+		return nil, fmt.Errorf("reply checksum 0x%02x, want 0x%02x", buf[n+2], want)
+	}
+	if n == 0 {
+		return nil, errNullReply
+	}
+	return buf[2 : n+2], nil
+}
+
+// decodeVCPReply parses a Get VCP Feature reply payload:
+// opcode, result, VCP code, type, max hi, max lo, current hi, current lo.
+func decodeVCPReply(code byte, p []byte) (current, maxValue int, err error) {
+	if len(p) != 8 || p[0] != opGetVCPReply {
+		return 0, 0, fmt.Errorf("unexpected reply % x", p)
+	}
+	if p[1] == 1 {
+		return 0, 0, ErrUnsupported
+	}
+	if p[1] != 0 {
+		return 0, 0, fmt.Errorf("reply result code %d", p[1])
+	}
+	if p[2] != code {
+		return 0, 0, fmt.Errorf("reply for VCP 0x%02x, want 0x%02x", p[2], code)
+	}
+	maxValue = int(p[4])<<8 | int(p[5])
+	current = int(p[6])<<8 | int(p[7])
+	return current, maxValue, nil
+}
+
+func checksum(seed byte, b []byte) byte {
+	for _, c := range b {
+		seed ^= c
+	}
+	return seed
+}
@@ -0,0 +1,245 @@
+package monitor
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"slices"
+	"testing"
+	"time"
+)
+
+// fakeI2C replays canned monitor replies, one per Read, and records writes.
+type fakeI2C struct {
+	replies [][]byte
+	writes  [][]byte
+	readErr error
+	closed  bool
+}
+
+func (f *fakeI2C) Write(p []byte) (int, error) {
+	f.writes = append(f.writes, bytes.Clone(p))
+	return len(p), nil
+}
+
+func (f *fakeI2C) Read(p []byte) (int, error) {
+	if f.readErr != nil {
+		return 0, f.readErr
+	}
+	if len(f.replies) == 0 {
+		return 0, io.EOF
+	}
+	r := f.replies[0]
+	f.replies = f.replies[1:]
+	return copy(p, r), nil
+}
+
+func (f *fakeI2C) Close() error {
+	f.closed = true
+	return nil
+}
+
+// reply frames a monitor→host payload with a valid checksum.
+func reply(payload ...byte) []byte {
+	b := append([]byte{ddcDestAddr, ddcLengthFlag | byte(len(payload))}, payload...)
+	return append(b, checksum(ddcReplyXOR, b))
+}
+
+func newTestMonitor(dev *fakeI2C) (*ddcMonitor, *[]time.Duration) {
+	var sleeps []time.Duration
+	m := newDDCMonitor(dev, "i2c-4", "test")
+	m.sleep = func(d time.Duration) { sleeps = append(sleeps, d) }
+	return m, &sleeps
+}
+
+func TestEncodeRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload []byte
+		want    []byte
+	}{
+		// Reference frames as seen in ddcutil traces.
+		{"get brightness", []byte{opGetVCP, 0x10}, []byte{0x51, 0x82, 0x01, 0x10, 0xAC}},
+		{"set brightness 50", []byte{opSetVCP, 0x10, 0x00, 0x32}, []byte{0x51, 0x84, 0x03, 0x10, 0x00, 0x32, 0x9A}},
+		{"get input source", []byte{opGetVCP, 0x60}, []byte{0x51, 0x82, 0x01, 0x60, 0xDC}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := encodeRequest(tt.payload); !bytes.Equal(got, tt.want) {
+				t.Errorf("encodeRequest(% x) = % x, want % x", tt.payload, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeReply(t *testing.T) {
+	valid := reply(opGetVCPReply, 0x00, 0x10, 0x00, 0x00, 0x64, 0x00, 0x32)
+
+	tests := []struct {
+		name    string
+		buf     []byte
+		wantErr bool
+	}{
+		{"valid", valid, false},
+		{"trailing garbage ignored", append(bytes.Clone(valid), 0xFF, 0xFF), false},
+		{"too short", []byte{0x6E, 0x80}, true},
+		{"wrong source", append([]byte{0x6F}, valid[1:]...), true},
+		{"missing length flag", append([]byte{0x6E, 0x08}, valid[2:]...), true},
+		{"truncated", valid[:6], true},
+		{"bad checksum", append(bytes.Clone(valid[:len(valid)-1]), valid[len(valid)-1]^0xFF), true},
+		{"null reply", reply(), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := decodeReply(tt.buf)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("decodeReply(% x) err = %v, wantErr %v", tt.buf, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDDCGetVCP(t *testing.T) {
+	dev := &fakeI2C{replies: [][]byte{
+		reply(opGetVCPReply, 0x00, 0x10, 0x00, 0x00, 0x64, 0x00, 0x4B),
+	}}
+	m, sleeps := newTestMonitor(dev)
+
+	cur, maxValue, err := m.GetVCP(VCPBrightness)
+	if err != nil {
+		t.Fatalf("GetVCP: %v", err)
+	}
+	if cur != 75 || maxValue != 100 {
+		t.Errorf("GetVCP = (%d, %d), want (75, 100)", cur, maxValue)
+	}
+	if len(dev.writes) != 1 || !bytes.Equal(dev.writes[0], []byte{0x51, 0x82, 0x01, 0x10, 0xAC}) {
+		t.Errorf("writes = % x", dev.writes)
+	}
+	if len(*sleeps) != 1 || (*sleeps)[0] != ddcReplyDelay {
+		t.Errorf("sleeps = %v, want [%v]", *sleeps, ddcReplyDelay)
+	}
+}
+
+func TestDDCGetVCPRetries(t *testing.T) {
+	bad := reply(opGetVCPReply, 0x00, 0x10, 0x00, 0x00, 0x64, 0x00, 0x4B)
+	bad[len(bad)-1] ^= 0x01
+
+	dev := &fakeI2C{replies: [][]byte{
+		reply(), // null: monitor busy
+		bad,     // corrupted on the wire
+		reply(opGetVCPReply, 0x00, 0x10, 0x00, 0x00, 0x64, 0x00, 0x1E),
+	}}
+	m, sleeps := newTestMonitor(dev)
+
+	cur, _, err := m.GetVCP(VCPBrightness)
+	if err != nil {
+		t.Fatalf("GetVCP: %v", err)
+	}
+	if cur != 30 {
+		t.Errorf("current = %d, want 30", cur)
+	}
+	if len(dev.writes) != 3 {
+		t.Errorf("requests = %d, want 3", len(dev.writes))
+	}
+	want := []time.Duration{ddcReplyDelay, ddcRetryDelay, ddcReplyDelay, ddcRetryDelay, ddcReplyDelay}
+	if len(*sleeps) != len(want) {
+		t.Errorf("sleeps = %v, want %v", *sleeps, want)
+	}
+}
+
+func TestDDCGetVCPGivesUp(t *testing.T) {
+	dev := &fakeI2C{readErr: errors.New("remote I/O error")}
+	m, _ := newTestMonitor(dev)
+
+	if _, _, err := m.GetVCP(VCPBrightness); err == nil {
+		t.Fatal("GetVCP succeeded on a dead bus")
+	}
+	if len(dev.writes) != ddcRetries {
+		t.Errorf("requests = %d, want %d", len(dev.writes), ddcRetries)
+	}
+}
+
+func TestDDCGetVCPUnsupported(t *testing.T) {
+	dev := &fakeI2C{replies: [][]byte{
+		reply(opGetVCPReply, 0x01, 0x60, 0x00, 0x00, 0x00, 0x00, 0x00),
+	}}
+	m, _ := newTestMonitor(dev)
+
+	if _, _, err := m.GetVCP(0x60); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("err = %v, want ErrUnsupported", err)
+	}
+	if len(dev.writes) != 1 {
+		t.Errorf("requests = %d, want 1 (no retry on unsupported)", len(dev.writes))
+	}
+}
+
+func TestDDCGetVCPWrongCode(t *testing.T) {
+	dev := &fakeI2C{replies: [][]byte{
+		reply(opGetVCPReply, 0x00, 0x12, 0x00, 0x00, 0x64, 0x00, 0x32),
+		reply(opGetVCPReply, 0x00, 0x10, 0x00, 0x00, 0x64, 0x00, 0x32),
+	}}
+	m, _ := newTestMonitor(dev)
+
+	cur, _, err := m.GetVCP(VCPBrightness)
+	if err != nil || cur != 50 {
+		t.Errorf("GetVCP = %d, %v; want 50, nil after retry", cur, err)
+	}
+}
+
+func TestDDCSetVCP(t *testing.T) {
+	dev := &fakeI2C{}
+	m, sleeps := newTestMonitor(dev)
+
+	if err := m.SetVCP(VCPBrightness, 50); err != nil {
+		t.Fatalf("SetVCP: %v", err)
+	}
+	want := []byte{0x51, 0x84, 0x03, 0x10, 0x00, 0x32, 0x9A}
+	if len(dev.writes) != 1 || !bytes.Equal(dev.writes[0], want) {
+		t.Errorf("writes = % x, want [% x]", dev.writes, want)
+	}
+	if len(*sleeps) != 1 || (*sleeps)[0] != ddcWriteDelay {
+		t.Errorf("sleeps = %v, want [%v]", *sleeps, ddcWriteDelay)
+	}
+
+	if err := m.SetVCP(VCPBrightness, 70000); err == nil {
+		t.Error("SetVCP accepted out-of-range value")
+	}
+}
+
+func TestDDCMonitorClose(t *testing.T) {
+	dev := &fakeI2C{}
+	m, _ := newTestMonitor(dev)
+	_ = m.Close()
+	if !dev.closed {
+		t.Error("Close did not close the device")
+	}
+}
+
+func TestDDCCapabilitiesString(t *testing.T) {
+	const caps = "(prot(monitor)type(LCD)model(U2415)cmds(01 02 03 07 0C E3 F3)vcp(02 04 10 12 60(0F 11))mccs_ver(2.1))"
+	var replies [][]byte
+	for off := 0; off < len(caps); off += capsFragmentLen {
+		end := min(off+capsFragmentLen, len(caps))
+		replies = append(replies, reply(append([]byte{opCapsReply, byte(off >> 8), byte(off)}, caps[off:end]...)...))
+	}
+	replies = append(replies, reply(opCapsReply, byte(len(caps)>>8), byte(len(caps)))) // end marker
+	// The monitor answers the second request with the first fragment's
+	// offset; the reader must retry rather than splice it in.
+	replies = slices.Insert(replies, 1, replies[0])
+
+	dev := &fakeI2C{replies: replies}
+	m, _ := newTestMonitor(dev)
+	got, err := m.CapabilitiesString()
+	if err != nil {
+		t.Fatalf("CapabilitiesString: %v", err)
+	}
+	if got != caps {
+		t.Errorf("CapabilitiesString = %q, want %q", got, caps)
+	}
+	want := encodeRequest([]byte{opCapsRequest, 0x00, capsFragmentLen})
+	for _, i := range []int{1, 2} {
+		if !bytes.Equal(dev.writes[i], want) {
+			t.Errorf("request %d = % x, want % x", i, dev.writes[i], want)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+//go:build windows
+
+package monitor
+
+import (
+	"log"
+	"strconv"
+
+	"github.com/niluan304/ddcci"
+	"github.com/niluan304/ddcci/vcp"
+)
+
+// DDCCI is the Windows backend, built on the dxva2 monitor configuration API.
+type DDCCI struct{}
+
+// Enumerate returns one Monitor per physical monitor. Monitors that fail to
+// open are logged and skipped.
+func (DDCCI) Enumerate() ([]Monitor, error) {
+	sysMonitors, err := ddcci.NewSystemMonitors()
+	if err != nil {
+		return nil, err
+	}
+	edids := displayEDIDs()
+	var monitors []Monitor
+	for i := range sysMonitors {
+		m, err := ddcci.NewPhysicalMonitor(&sysMonitors[i])
+		if err != nil {
+			log.Printf("monitor %d: %v", i, err)
+			continue
+		}
+		dm := &ddcciMonitor{pm: m, index: i}
+		if i < len(edids) {
+			dm.edid = edids[i]
+		}
+		monitors = append(monitors, dm)
+	}
+	return monitors, nil
+}
+
+type ddcciMonitor struct {
+	pm    *ddcci.PhysicalMonitor
+	index int
+	edid  []byte
+}
+
+func (m *ddcciMonitor) ID() string   { return strconv.Itoa(m.index) }
+func (m *ddcciMonitor) Name() string { return m.pm.Description() }
+func (m *ddcciMonitor) EDID() []byte { return m.edid }
+
+func (m *ddcciMonitor) GetVCP(code byte) (current, maxValue int, err error) {
+	return m.pm.GetVCPFeatureAndVCPFeatureReply(vcp.NewVCP(int(code), 0))
+}
+
+func (m *ddcciMonitor) SetVCP(code byte, value int) error {
+	return m.pm.SetVCPFeature(vcp.NewVCP(int(code), value))
+}
+
+func (m *ddcciMonitor) CapabilitiesString() (string, error) {
+	return m.pm.CapabilitiesRequestAndCapabilitiesReply()
+}
+
+// Close is a no-op: the ddcci package doesn't expose the physical monitor
+// handle, so it can't be passed to DestroyPhysicalMonitor.
+func (m *ddcciMonitor) Close() error { return nil }
@@ -0,0 +1,133 @@
+package monitor
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// EDID is the identity and native mode parsed from an EDID 1.x base block.
+type EDID struct {
+	Manufacturer string // three-letter PNP ID, e.g. "DEL"
+	ProductCode  uint16
+	SerialNumber uint32 // numeric serial from the header, often 0
+	Serial       string // serial number descriptor (0xFF), e.g. "ABC123"
+	ModelName    string // monitor name descriptor (0xFC), e.g. "DELL U2722D"
+	Week         int    // week of manufacture, 0 if unknown; 255 means Year is a model year
+	Year         int
+	Version      string // EDID version, e.g. "1.4"
+	Width        int    // native (preferred) resolution from the first detailed timing
+	Height       int
+	Extensions   int // number of extension blocks that follow the base block
+}
+
+var edidHeader = []byte{0x00, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x00}
+
+// ParseEDID parses the 128-byte EDID base block at the start of b. Extension
+// blocks are counted but not parsed. A bad checksum is an error: it usually
+// means a partial read over a flaky DDC bus.
+func ParseEDID(b []byte) (*EDID, error) {
+	if len(b) < 128 {
+		return nil, fmt.Errorf("edid: %d bytes, want at least 128", len(b))
+	}
+	if !bytes.Equal(b[:8], edidHeader) {
+		return nil, errors.New("edid: bad header")
+	}
+	var sum byte
+	for _, c := range b[:128] {
+		sum += c
+	}
+	if sum != 0 {
+		return nil, fmt.Errorf("edid: bad checksum (sum 0x%02x)", sum)
+	}
+
+	e := &EDID{
+		Manufacturer: pnpID(uint16(b[8])<<8 | uint16(b[9])),
+		ProductCode:  uint16(b[10]) | uint16(b[11])<<8,
+		SerialNumber: uint32(b[12]) | uint32(b[13])<<8 | uint32(b[14])<<16 | uint32(b[15])<<24,
+		Week:         int(b[16]),
+		Year:         int(b[17]) + 1990,
+		Version:      fmt.Sprintf("%d.%d", b[18], b[19]),
+		Extensions:   int(b[126]),
+	}
+
+	for off := 54; off <= 108; off += 18 {
+		d := b[off : off+18]
+		if d[0] != 0 || d[1] != 0 {
+			// Detailed timing descriptor; the first one is the preferred mode.
+			if e.Width == 0 {
+				e.Width = int(d[2]) | int(d[4]&0xF0)<<4
+				e.Height = int(d[5]) | int(d[7]&0xF0)<<4
+			}
+			continue
+		}
+		switch d[3] {
+		case 0xFC:
+			e.ModelName = descriptorText(d[5:])
+		case 0xFF:
+			e.Serial = descriptorText(d[5:])
+		}
+	}
+	return e, nil
+}
+
+// pnpID decodes the big-endian manufacturer ID: three 5-bit letters, 1 = 'A'.
+func pnpID(v uint16) string {
+	letter := func(c uint16) byte {
+		if c < 1 || c > 26 {
+			return '?'
+		}
+		return byte('A' + c - 1)
+	}
+	return string([]byte{letter(v >> 10 & 0x1F), letter(v >> 5 & 0x1F), letter(v & 0x1F)})
+}
+
+// descriptorText decodes a 13-byte display descriptor string, which ends at
+// a newline and is padded with spaces.
+func descriptorText(b []byte) string {
+	if i := bytes.IndexByte(b, '\n'); i >= 0 {
+		b = b[:i]
+	}
+	return strings.TrimSpace(strings.Map(func(r rune) rune {
+		if r < 0x20 || r > 0x7E {
+			return -1
+		}
+		return r
+	}, string(b)))
+}
+
+// serial returns the serial number descriptor, falling back to the numeric
+// header serial. Empty when the monitor reports neither.
+func (e *EDID) serial() string {
+	if e.Serial != "" {
+		return e.Serial
+	}
+	if e.SerialNumber != 0 {
+		return fmt.Sprint(e.SerialNumber)
+	}
+	return ""
+}
+
+// ID returns an identifier that stays the same across reboots, ports and
+// driver updates, e.g. "DEL-A1B2-ABC123". Two identical monitors without
+// serial numbers share an ID.
+func (e *EDID) ID() string {
+	id := fmt.Sprintf("%s-%04X", e.Manufacturer, e.ProductCode)
+	if s := e.serial(); s != "" {
+		id += "-" + s
+	}
+	return id
+}
+
+// DisplayName returns a human-readable name, e.g. "DELL U2722D #ABC123".
+func (e *EDID) DisplayName() string {
+	name := e.ModelName
+	if name == "" {
+		name = fmt.Sprintf("%s %04X", e.Manufacturer, e.ProductCode)
+	}
+	if s := e.serial(); s != "" {
+		name += " #" + s
+	}
+	return name
+}
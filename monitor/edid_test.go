@@ -0,0 +1,117 @@
+package monitor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestParseEDIDCorpus parses every fixture in testdata/edid. Files named
+// invalid-* must fail; every other one must yield a stable ID, a display name
+// and a native resolution.
+func TestParseEDIDCorpus(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "edid", "*.bin"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no fixtures: %v", err)
+	}
+	for _, f := range files {
+		name := filepath.Base(f)
+		t.Run(name, func(t *testing.T) {
+			data, err := os.ReadFile(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			e, err := ParseEDID(data)
+			if strings.HasPrefix(name, "invalid-") {
+				if err == nil {
+					t.Errorf("parsed invalid fixture: %+v", e)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseEDID: %v", err)
+			}
+			if e.ID() == "" || e.DisplayName() == "" || e.Width == 0 || e.Height == 0 {
+				t.Errorf("incomplete: %+v", e)
+			}
+		})
+	}
+}
+
+func TestParseEDID(t *testing.T) {
+	tests := []struct {
+		file string
+		want EDID
+		id   string
+		name string
+	}{
+		{"dell-u2722d.bin", EDID{Manufacturer: "DEL", ProductCode: 0x4277, SerialNumber: 0x4C4A3032,
+			Serial: "7MT0182C2XYL", ModelName: "DELL U2722D", Week: 12, Year: 2022, Version: "1.4",
+			Width: 2560, Height: 1440, Extensions: 1},
+			"DEL-4277-7MT0182C2XYL", "DELL U2722D #7MT0182C2XYL"},
+		// No serial descriptor: fall back to the numeric header serial.
+		{"lg-27uk850.bin", EDID{Manufacturer: "GSM", ProductCode: 0x7707, SerialNumber: 123848,
+			ModelName: "LG HDR 4K", Week: 5, Year: 2019, Version: "1.4",
+			Width: 3840, Height: 2160, Extensions: 1},
+			"GSM-7707-123848", "LG HDR 4K #123848"},
+		{"samsung-s27e390.bin", EDID{Manufacturer: "SAM", ProductCode: 0x0D33, SerialNumber: 0x4D583130,
+			Serial: "H4ZK300123", ModelName: "S27E390", Week: 40, Year: 2017, Version: "1.3",
+			Width: 1920, Height: 1080, Extensions: 1},
+			"SAM-0D33-H4ZK300123", "S27E390 #H4ZK300123"},
+		// Laptop panels often have no name or serial, only 0xFE text.
+		{"auo-laptop-panel.bin", EDID{Manufacturer: "AUO", ProductCode: 0x243D,
+			Year: 2018, Version: "1.4", Width: 1920, Height: 1080},
+			"AUO-243D", "AUO 243D"},
+		// Week 0xFF marks Year as the model year.
+		{"hp-model-year.bin", EDID{Manufacturer: "HWP", ProductCode: 0x3254, SerialNumber: 0x01010101,
+			Serial: "CN41234ABC", ModelName: "HP E24 G4", Week: 255, Year: 2020, Version: "1.4",
+			Width: 1920, Height: 1080, Extensions: 1},
+			"HWP-3254-CN41234ABC", "HP E24 G4 #CN41234ABC"},
+		// A 13-character name fills the descriptor with no newline.
+		{"benq-unterminated-name.bin", EDID{Manufacturer: "BNQ", ProductCode: 0x7F5B, SerialNumber: 0x5445,
+			Serial: "ET5AH01234SL0", ModelName: "BenQ GW2765HT", Week: 21, Year: 2016, Version: "1.3",
+			Width: 2560, Height: 1440},
+			"BNQ-7F5B-ET5AH01234SL0", "BenQ GW2765HT #ET5AH01234SL0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", "edid", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			e, err := ParseEDID(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *e != tt.want {
+				t.Errorf("got  %+v\nwant %+v", *e, tt.want)
+			}
+			if got := e.ID(); got != tt.id {
+				t.Errorf("ID() = %q, want %q", got, tt.id)
+			}
+			if got := e.DisplayName(); got != tt.name {
+				t.Errorf("DisplayName() = %q, want %q", got, tt.name)
+			}
+		})
+	}
+}
+
+func TestParseEDIDErrors(t *testing.T) {
+	good, err := os.ReadFile(filepath.Join("testdata", "edid", "samsung-s27e390.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	badHeader := append([]byte(nil), good...)
+	badHeader[0] = 0x01
+
+	for name, data := range map[string][]byte{
+		"nil":        nil,
+		"short":      good[:127],
+		"bad header": badHeader,
+	} {
+		if _, err := ParseEDID(data); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+//go:build windows
+
+package monitor
+
+import (
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+var (
+	user32                  = syscall.NewLazyDLL("user32.dll")
+	procEnumDisplayMonitors = user32.NewProc("EnumDisplayMonitors")
+	procGetMonitorInfoW     = user32.NewProc("GetMonitorInfoW")
+	procEnumDisplayDevicesW = user32.NewProc("EnumDisplayDevicesW")
+)
+
+const eddGetDeviceInterfaceName = 0x1
+
+type monitorInfoEx struct {
+	cbSize    uint32
+	rcMonitor [4]int32
+	rcWork    [4]int32
+	dwFlags   uint32
+	szDevice  [32]uint16
+}
+
+type displayDevice struct {
+	cb           uint32
+	deviceName   [32]uint16
+	deviceString [128]uint16
+	stateFlags   uint32
+	deviceID     [128]uint16
+	deviceKey    [128]uint16
+}
+
+// displayEDIDs returns the EDID of each display in EnumDisplayMonitors order,
+// which is the order the ddcci package enumerates in. Entries are nil where
+// the EDID can't be found.
+//
+// dxva2 has no EDID API, so this goes the long way round: HMONITOR → GDI
+// device name (\\.\DISPLAY1) → monitor device interface path
+// (\\?\DISPLAY#DELA0C5#5&2f1c3a7b&0&UID4353#{...}) → the EDID value the
+// display driver stores under HKLM\SYSTEM\CurrentControlSet\Enum\DISPLAY.
+func displayEDIDs() [][]byte {
+	var handles []uintptr
+	cb := syscall.NewCallback(func(h, _, _, _ uintptr) uintptr {
+		handles = append(handles, h)
+		return 1
+	})
+	procEnumDisplayMonitors.Call(0, 0, cb, 0)
+
+	edids := make([][]byte, len(handles))
+	for i, h := range handles {
+		edids[i] = monitorEDID(h)
+	}
+	return edids
+}
+
+func monitorEDID(hMonitor uintptr) []byte {
+	mi := monitorInfoEx{cbSize: uint32(unsafe.Sizeof(monitorInfoEx{}))}
+	if r, _, _ := procGetMonitorInfoW.Call(hMonitor, uintptr(unsafe.Pointer(&mi))); r == 0 {
+		return nil
+	}
+	dd := displayDevice{cb: uint32(unsafe.Sizeof(displayDevice{}))}
+	r, _, _ := procEnumDisplayDevicesW.Call(uintptr(unsafe.Pointer(&mi.szDevice[0])), 0,
+		uintptr(unsafe.Pointer(&dd)), eddGetDeviceInterfaceName)
+	if r == 0 {
+		return nil
+	}
+
+	// \\?\DISPLAY#<model>#<instance>#{<interface class>}
+	parts := strings.Split(syscall.UTF16ToString(dd.deviceID[:]), "#")
+	if len(parts) < 3 {
+		return nil
+	}
+	path := `SYSTEM\CurrentControlSet\Enum\DISPLAY\` + parts[1] + `\` + parts[2] + `\Device Parameters`
+	k, err := registry.OpenKey(registry.LOCAL_MACHINE, path, registry.QUERY_VALUE)
+	if err != nil {
+		return nil
+	}
+	defer k.Close()
+	edid, _, err := k.GetBinaryValue("EDID")
+	if err != nil {
+		return nil
+	}
+	return edid
+}
@@ -0,0 +1,114 @@
+package monitor
+
+import (
+	"errors"
+	"strconv"
+)
+
+// Fake is an in-memory Backend for tests. Each FakeMonitor models a physical
+// display; Enumerate hands out fresh (non-stale) handles to all of them.
+type Fake struct {
+	Displays []*FakeMonitor
+	Err      error // returned by Enumerate when set
+
+	Enumerations int // number of Enumerate calls
+}
+
+func (f *Fake) Enumerate() ([]Monitor, error) {
+	f.Enumerations++
+	if f.Err != nil {
+		return nil, f.Err
+	}
+	monitors := make([]Monitor, len(f.Displays))
+	for i, d := range f.Displays {
+		d.Stale = false
+		d.Closed = false
+		monitors[i] = d
+	}
+	return monitors, nil
+}
+
+// FakeMonitor is an in-memory Monitor. A stale FakeMonitor behaves like a
+// Windows handle after sleep/wake: reads return 0 and writes silently do
+// nothing.
+type FakeMonitor struct {
+	Index       int
+	Description string
+	Caps        string // raw capabilities string
+	RawEDID     []byte
+	VCP         map[byte]int // current values
+	Max         map[byte]int // maximum values; 100 when unset
+	Stale       bool
+	GetErr      error
+	SetErr      error
+	Closed      bool
+
+	Sets []FakeSet // successful SetVCP calls, in order
+}
+
+// FakeSet records one SetVCP call.
+type FakeSet struct {
+	Code  byte
+	Value int
+}
+
+// NewFakeMonitor returns a FakeMonitor with brightness at the given level.
+func NewFakeMonitor(index, brightness int) *FakeMonitor {
+	return &FakeMonitor{
+		Index:       index,
+		Description: "Fake Monitor " + strconv.Itoa(index),
+		VCP:         map[byte]int{VCPBrightness: brightness},
+	}
+}
+
+func (m *FakeMonitor) ID() string   { return strconv.Itoa(m.Index) }
+func (m *FakeMonitor) Name() string { return m.Description }
+func (m *FakeMonitor) EDID() []byte { return m.RawEDID }
+
+func (m *FakeMonitor) GetVCP(code byte) (current, maxValue int, err error) {
+	if m.GetErr != nil {
+		return 0, 0, m.GetErr
+	}
+	maxValue = 100
+	if v, ok := m.Max[code]; ok {
+		maxValue = v
+	}
+	if m.Stale {
+		return 0, maxValue, nil
+	}
+	v, ok := m.VCP[code]
+	if !ok {
+		return 0, 0, errors.New("unsupported VCP code")
+	}
+	return v, maxValue, nil
+}
+
+func (m *FakeMonitor) SetVCP(code byte, value int) error {
+	if m.SetErr != nil {
+		return m.SetErr
+	}
+	if m.Stale {
+		return nil
+	}
+	if m.VCP == nil {
+		m.VCP = map[byte]int{}
+	}
+	m.VCP[code] = value
+	m.Sets = append(m.Sets, FakeSet{Code: code, Value: value})
+	return nil
+}
+
+func (m *FakeMonitor) CapabilitiesString() (string, error) {
+	if m.GetErr != nil {
+		return "", m.GetErr
+	}
+	if m.Caps == "" {
+		return "", errors.New("no capabilities reply")
+	}
+	return m.Caps, nil
+}
+
+func (m *FakeMonitor) Close() error {
+	m.Closed = true
+	return nil
+}
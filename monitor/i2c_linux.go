@@ -0,0 +1,113 @@
+//go:build linux
+
+package monitor
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// i2cSlave is the I2C_SLAVE ioctl from <linux/i2c-dev.h>.
+const i2cSlave = 0x0703
+
+// edidAddr is the DDC slave address of the monitor's EDID EEPROM.
+const edidAddr = 0x50
+
+// I2C is the Linux backend. It speaks DDC/CI directly over /dev/i2c-*
+// device files, which needs the i2c-dev kernel module and read/write access
+// to the devices (usually via the i2c group).
+type I2C struct{}
+
+// Enumerate probes every i2c bus for a DDC/CI monitor. Buses that can't be
+// opened or don't answer a brightness query are skipped.
+func (I2C) Enumerate() ([]Monitor, error) {
+	paths, err := filepath.Glob("/dev/i2c-*")
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, errors.New("no /dev/i2c-* devices (is the i2c-dev module loaded?)")
+	}
+	slices.SortFunc(paths, func(a, b string) int { return busNumber(a) - busNumber(b) })
+
+	var monitors []Monitor
+	for _, path := range paths {
+		bus := filepath.Base(path)
+		adapter := adapterName(bus)
+		if strings.Contains(strings.ToLower(adapter), "smbus") {
+			continue // never a display; probing can upset some chipsets
+		}
+		dev, err := openI2C(path, ddcAddr)
+		if err != nil {
+			log.Printf("%s: %v", path, err)
+			continue
+		}
+		m := newDDCMonitor(dev, bus, adapter)
+		if _, _, err := m.GetVCP(VCPBrightness); err != nil && !errors.Is(err, ErrUnsupported) {
+			_ = dev.Close()
+			continue
+		}
+		m.edid = readEDID(path)
+		monitors = append(monitors, m)
+	}
+	return monitors, nil
+}
+
+// i2cFile is an i2c-dev file bound to one slave address.
+type i2cFile struct{ fd int }
+
+func openI2C(path string, addr int) (*i2cFile, error) {
+	fd, err := unix.Open(path, unix.O_RDWR|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, err
+	}
+	if err := unix.IoctlSetInt(fd, i2cSlave, addr); err != nil {
+		_ = unix.Close(fd)
+		return nil, fmt.Errorf("I2C_SLAVE: %w", err)
+	}
+	return &i2cFile{fd: fd}, nil
+}
+
+func (f *i2cFile) Read(p []byte) (int, error)  { return unix.Read(f.fd, p) }
+func (f *i2cFile) Write(p []byte) (int, error) { return unix.Write(f.fd, p) }
+func (f *i2cFile) Close() error                { return unix.Close(f.fd) }
+
+// readEDID reads the 128-byte EDID base block from the EEPROM on the same
+// bus. Returns nil on failure; the monitor is still usable without it.
+func readEDID(path string) []byte {
+	f, err := openI2C(path, edidAddr)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	if _, err := f.Write([]byte{0}); err != nil { // EEPROM offset
+		return nil
+	}
+	edid := make([]byte, 128)
+	if _, err := io.ReadFull(f, edid); err != nil {
+		return nil
+	}
+	return edid
+}
+
+func adapterName(bus string) string {
+	name, err := os.ReadFile(filepath.Join("/sys/bus/i2c/devices", bus, "name"))
+	if err != nil {
+		return bus
+	}
+	return strings.TrimSpace(string(name))
+}
+
+func busNumber(path string) int {
+	n, _ := strconv.Atoi(strings.TrimPrefix(filepath.Base(path), "i2c-"))
+	return n
+}
@@ -0,0 +1,89 @@
+package monitor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// VCPInputSource selects the active video input (MCCS VCP 0x60).
+const VCPInputSource = 0x60
+
+// inputNames maps MCCS input source values to short names. 0x1B is not in
+// the standard but is what Dell, LG and others use for USB-C.
+var inputNames = map[int]string{
+	0x01: "VGA1",
+	0x02: "VGA2",
+	0x03: "DVI1",
+	0x04: "DVI2",
+	0x05: "Composite1",
+	0x06: "Composite2",
+	0x07: "S-Video1",
+	0x08: "S-Video2",
+	0x09: "Tuner1",
+	0x0A: "Tuner2",
+	0x0B: "Tuner3",
+	0x0C: "Component1",
+	0x0D: "Component2",
+	0x0E: "Component3",
+	0x0F: "DP1",
+	0x10: "DP2",
+	0x11: "HDMI1",
+	0x12: "HDMI2",
+	0x1B: "USB-C",
+}
+
+// InputName returns the display name of an input source value, e.g. "HDMI2".
+func InputName(value int) string {
+	if name, ok := inputNames[value]; ok {
+		return name
+	}
+	return fmt.Sprintf("Input 0x%02X", value)
+}
+
+// ParseInput resolves an input name to its VCP 0x60 value. Matching ignores
+// case, spaces, dashes and underscores, so "hdmi 2", "HDMI-2" and "HDMI2" are
+// equivalent; "DisplayPort1" is accepted for DP1, and "USBC" for USB-C.
+// A raw value like "0x11" is also accepted.
+func ParseInput(name string) (int, bool) {
+	if v, err := strconv.ParseUint(name, 0, 8); err == nil && strings.HasPrefix(strings.ToLower(name), "0x") {
+		return int(v), true
+	}
+	key := normalizeInput(name)
+	key = strings.Replace(key, "displayport", "dp", 1)
+	if key == "" {
+		return 0, false
+	}
+	for v, n := range inputNames {
+		if normalizeInput(n) == key {
+			return v, true
+		}
+	}
+	// Names without a number refer to the first port: "HDMI" → HDMI1.
+	for v, n := range inputNames {
+		if normalizeInput(n) == key+"1" {
+			return v, true
+		}
+	}
+	return 0, false
+}
+
+func normalizeInput(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '_':
+			return -1
+		}
+		return r
+	}, strings.ToLower(s))
+}
+
+// GetInput reads the active input source. Only the low byte is meaningful;
+// some monitors put garbage in the high byte.
+func GetInput(m Monitor) (int, error) {
+	cur, _, err := m.GetVCP(VCPInputSource)
+	if err != nil {
+		return 0, err
+	}
+	return cur & 0xFF, nil
+}
@@ -0,0 +1,57 @@
+package monitor
+
+import "testing"
+
+func TestParseInput(t *testing.T) {
+	tests := []struct {
+		name   string
+		want   int
+		wantOK bool
+	}{
+		{"HDMI1", 0x11, true},
+		{"hdmi 2", 0x12, true},
+		{"HDMI-2", 0x12, true},
+		{"HDMI", 0x11, true},
+		{"DP1", 0x0F, true},
+		{"dp2", 0x10, true},
+		{"DisplayPort1", 0x0F, true},
+		{"DisplayPort 2", 0x10, true},
+		{"USB-C", 0x1B, true},
+		{"usbc", 0x1B, true},
+		{"USB_C", 0x1B, true},
+		{"VGA", 0x01, true},
+		{"0x11", 0x11, true},
+		{"0X0f", 0x0F, true},
+		{"", 0, false},
+		{"HDMI3", 0, false},
+		{"17", 0, false},
+		{"Thunderbolt", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := ParseInput(tt.name)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("ParseInput(%q) = (0x%02X, %v), want (0x%02X, %v)", tt.name, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestInputNameRoundTrip(t *testing.T) {
+	for v, name := range inputNames {
+		got, ok := ParseInput(name)
+		if !ok || got != v {
+			t.Errorf("ParseInput(InputName(0x%02X) = %q) = (0x%02X, %v)", v, name, got, ok)
+		}
+	}
+	if got := InputName(0x42); got != "Input 0x42" {
+		t.Errorf("InputName(0x42) = %q", got)
+	}
+}
+
+func TestGetInputMasksHighByte(t *testing.T) {
+	m := NewFakeMonitor(0, 50)
+	m.VCP[VCPInputSource] = 0x0211
+	got, err := GetInput(m)
+	if err != nil || got != 0x11 {
+		t.Errorf("GetInput = (0x%X, %v), want (0x11, nil)", got, err)
+	}
+}
@@ -0,0 +1,53 @@
+// Package monitor abstracts DDC/CI access to physical monitors so the
+// brightness logic doesn't depend on a particular platform API.
+package monitor
+
+// VCP feature codes from the VESA MCCS standard.
+const (
+	VCPBrightness = 0x10
+	VCPContrast   = 0x12
+)
+
+// Monitor is a handle to one physical monitor reachable over DDC/CI.
+// Handles can go stale (e.g. after sleep/wake); callers recover by
+// re-enumerating through the Backend.
+type Monitor interface {
+	// ID identifies the monitor within one enumeration.
+	ID() string
+	// Name is a human-readable description, e.g. "Generic PnP Monitor".
+	Name() string
+	// EDID returns the raw EDID, or nil if it couldn't be read.
+	EDID() []byte
+	// GetVCP reads the current and maximum value of a VCP feature.
+	GetVCP(code byte) (current, maxValue int, err error)
+	// SetVCP writes a VCP feature value.
+	SetVCP(code byte, value int) error
+	// CapabilitiesString reads the raw MCCS capabilities string.
+	CapabilitiesString() (string, error)
+	// Close releases the underlying handle.
+	Close() error
+}
+
+// Backend enumerates the physical monitors attached to the system.
+// Each call returns fresh handles.
+type Backend interface {
+	Enumerate() ([]Monitor, error)
+}
+
+// ReadCapabilities reads and parses the capabilities of m.
+func ReadCapabilities(m Monitor) (*Capabilities, error) {
+	s, err := m.CapabilitiesString()
+	if err != nil {
+		return nil, err
+	}
+	return ParseCapabilities(s)
+}
+
+// Identify parses the EDID of m. It returns nil when m has no readable EDID.
+func Identify(m Monitor) *EDID {
+	e, err := ParseEDID(m.EDID())
+	if err != nil {
+		return nil
+	}
+	return e
+}
@@ -0,0 +1,385 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/alex-vit/monibright/monitor"
+)
+
+const mqttTimeout = 10 * time.Second
+
+// mqttCoalesce is how long state changes are collected before they are
+// published, so a color temperature animation sends one update.
+var mqttCoalesce = 250 * time.Millisecond
+
+// mqttConfig connects MoniBright to an MQTT broker, where each monitor shows
+// up in Home Assistant as a light with brightness and color temperature, e.g.
+// {"broker": "tcp://homeassistant.local:1883", "username": "monibright", "password": "..."}.
+type mqttConfig struct {
+	Broker          string `json:"broker"`
+	Username        string `json:"username,omitempty"`
+	Password        string `json:"password,omitempty"`
+	TopicPrefix     string `json:"topic_prefix,omitempty"`     // default "monibright"
+	DiscoveryPrefix string `json:"discovery_prefix,omitempty"` // default "homeassistant"
+}
+
+// mqttConn is a broker connection. Replaced by a stand-in in tests.
+type mqttConn interface {
+	Publish(topic string, payload []byte, retain bool) error
+	Subscribe(topic string, handle func(topic string, payload []byte)) error
+	Disconnect()
+}
+
+// mqttConnect connects to the broker with will as the last-will topic
+// (payload "offline", retained). onConnect runs after every connect and
+// reconnect; the broker may be unreachable when mqttConnect returns.
+var mqttConnect = func(c mqttConfig, clientID, will string, onConnect func(mqttConn)) (mqttConn, error) {
+	opts := paho.NewClientOptions().
+		AddBroker(c.Broker).
+		SetClientID(clientID).
+		SetUsername(c.Username).
+		SetPassword(c.Password).
+		SetWill(will, "offline", 1, true).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetOrderMatters(false)
+	conn := &pahoConn{}
+	opts.SetOnConnectHandler(func(paho.Client) { onConnect(conn) })
+	conn.c = paho.NewClient(opts)
+	conn.c.Connect()
+	return conn, nil
+}
+
+type pahoConn struct{ c paho.Client }
+
+func (p *pahoConn) Publish(topic string, payload []byte, retain bool) error {
+	return pahoWait(p.c.Publish(topic, 1, retain, payload))
+}
+
+func (p *pahoConn) Subscribe(topic string, handle func(string, []byte)) error {
+	return pahoWait(p.c.Subscribe(topic, 1, func(_ paho.Client, msg paho.Message) {
+		handle(msg.Topic(), msg.Payload())
+	}))
+}
+
+func (p *pahoConn) Disconnect() { p.c.Disconnect(250) }
+
+func pahoWait(t paho.Token) error {
+	if !t.WaitTimeout(mqttTimeout) {
+		return errors.New("timed out")
+	}
+	return t.Error()
+}
+
+// mqttBridge publishes monitors as Home Assistant lights using the JSON
+// light schema, and carries out the commands sent to them.
+type mqttBridge struct {
+	conn      mqttConn
+	node      string // this PC, unique on the broker
+	base      string // topic_prefix/node
+	discovery string
+
+	mu        sync.Mutex
+	announced map[string]bool // slugs with a discovery config published
+	lastOn    map[string]int  // brightness to restore on "ON", per slug
+
+	stop        chan struct{}
+	done        chan struct{} // closed when run returns
+	unsubscribe func()
+}
+
+var mqttActive *mqttBridge
+
+// startMQTT connects to the broker in config, if any.
+func startMQTT() {
+	c := cfgSnapshot().MQTT
+	if c == nil || c.Broker == "" {
+		return
+	}
+	b, err := newMQTTBridge(*c)
+	if err != nil {
+		log.Printf("mqtt: %v", err)
+		return
+	}
+	mqttActive = b
+}
+
+// stopMQTT marks this PC offline and disconnects.
+func stopMQTT() {
+	if mqttActive == nil {
+		return
+	}
+	mqttActive.close()
+}
+
+func newMQTTBridge(c mqttConfig) (*mqttBridge, error) {
+	if c.TopicPrefix == "" {
+		c.TopicPrefix = "monibright"
+	}
+	if c.DiscoveryPrefix == "" {
+		c.DiscoveryPrefix = "homeassistant"
+	}
+	host, _ := os.Hostname()
+	node := "monibright_" + mqttSlug(host)
+	b := &mqttBridge{
+		node:      node,
+		base:      c.TopicPrefix + "/" + node,
+		discovery: c.DiscoveryPrefix,
+		announced: map[string]bool{},
+		lastOn:    map[string]int{},
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	events, unsubscribe := subscribe()
+	conn, err := mqttConnect(c, node, b.base+"/availability", b.onConnect)
+	if err != nil {
+		unsubscribe()
+		return nil, fmt.Errorf("connect %s: %w", c.Broker, err)
+	}
+	b.conn, b.unsubscribe = conn, unsubscribe
+	log.Printf("mqtt: connecting to %s as %s", c.Broker, node)
+	go b.run(events)
+	return b, nil
+}
+
+func (b *mqttBridge) close() {
+	close(b.stop)
+	<-b.done
+	b.unsubscribe()
+	_ = b.conn.Publish(b.base+"/availability", []byte("offline"), true)
+	b.conn.Disconnect()
+}
+
+// onConnect announces the monitors and subscribes to commands. Home
+// Assistant's birth message ("online" on <discovery>/status) repeats the
+// announcement after it restarts.
+func (b *mqttBridge) onConnect(conn mqttConn) {
+	log.Printf("mqtt: connected")
+	if err := conn.Subscribe(b.base+"/+/set", func(topic string, payload []byte) {
+		b.handleCommand(conn, topic, payload)
+	}); err != nil {
+		log.Printf("mqtt: subscribe: %v", err)
+	}
+	if err := conn.Subscribe(b.discovery+"/status", func(_ string, payload []byte) {
+		if string(payload) == "online" {
+			b.announce(conn)
+		}
+	}); err != nil {
+		log.Printf("mqtt: subscribe: %v", err)
+	}
+	if err := conn.Publish(b.base+"/availability", []byte("online"), true); err != nil {
+		log.Printf("mqtt: availability: %v", err)
+	}
+	b.announce(conn)
+}
+
+// mqttSlug turns a monitor key or host name into a topic segment.
+func mqttSlug(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			return r
+		}
+		if r >= 'A' && r <= 'Z' {
+			return r + 'a' - 'A'
+		}
+		return '_'
+	}, s)
+	return strings.Trim(s, "_")
+}
+
+func kelvinToMireds(k int) int { return int(math.Round(1e6 / float64(k))) }
+
+func miredsToKelvin(m int) int {
+	if m <= 0 {
+		return tempMax
+	}
+	return clamp(int(math.Round(1e6/float64(m))), tempMin, tempMax)
+}
+
+// announce publishes a discovery config and state for every monitor, and
+// removes the lights of monitors that are gone.
+func (b *mqttBridge) announce(conn mqttConn) {
+	present := map[string]bool{}
+	for _, m := range monitorList() {
+		slug := mqttSlug(monitorKey(m))
+		present[slug] = true
+		topic := b.base + "/" + slug
+		config := map[string]any{
+			"name":                  nil, // the device's main feature
+			"unique_id":             b.node + "_" + slug,
+			"schema":                "json",
+			"state_topic":           topic + "/state",
+			"command_topic":         topic + "/set",
+			"availability_topic":    b.base + "/availability",
+			"brightness":            true,
+			"brightness_scale":      100,
+			"supported_color_modes": []string{"color_temp"},
+			"min_mireds":            kelvinToMireds(tempMax),
+			"max_mireds":            kelvinToMireds(tempMin),
+			"device": map[string]any{
+				"identifiers": []string{b.node + "_" + slug},
+				"name":        monitorLabel(m),
+			},
+		}
+		data, _ := json.Marshal(config)
+		if err := conn.Publish(b.configTopic(slug), data, true); err != nil {
+			log.Printf("mqtt: discovery for %s: %v", monitorLabel(m), err)
+			continue
+		}
+		b.mu.Lock()
+		b.announced[slug] = true
+		b.mu.Unlock()
+		if _, err := readBrightness(m); err != nil {
+			log.Printf("mqtt: read %s: %v", monitorLabel(m), err)
+		}
+		b.publishState(conn, m)
+	}
+
+	b.mu.Lock()
+	var gone []string
+	for slug := range b.announced {
+		if !present[slug] {
+			gone = append(gone, slug)
+			delete(b.announced, slug)
+		}
+	}
+	b.mu.Unlock()
+	for _, slug := range gone {
+		if err := conn.Publish(b.configTopic(slug), nil, true); err != nil {
+			log.Printf("mqtt: removing %s: %v", slug, err)
+		}
+	}
+}
+
+func (b *mqttBridge) configTopic(slug string) string {
+	return b.discovery + "/light/" + b.node + "_" + slug + "/config"
+}
+
+// mqttLight is the JSON light schema's state and command payload.
+type mqttLight struct {
+	State      string `json:"state,omitempty"` // "ON" or "OFF"
+	Brightness *int   `json:"brightness,omitempty"`
+	ColorMode  string `json:"color_mode,omitempty"`
+	ColorTemp  *int   `json:"color_temp,omitempty"` // mireds
+}
+
+// publishState publishes the brightness of m and the color temperature.
+// A monitor at 0% is reported as off.
+func (b *mqttBridge) publishState(conn mqttConn, m monitor.Monitor) {
+	level := monitorBrightness(m)
+	mireds := kelvinToMireds(colorTemp())
+	st := mqttLight{State: "ON", Brightness: &level, ColorMode: "color_temp", ColorTemp: &mireds}
+	if level == 0 {
+		st.State = "OFF"
+	}
+	data, _ := json.Marshal(st)
+	if err := conn.Publish(b.base+"/"+mqttSlug(monitorKey(m))+"/state", data, true); err != nil {
+		log.Printf("mqtt: state of %s: %v", monitorLabel(m), err)
+	}
+}
+
+// handleCommand carries out a command sent to one monitor's light. The
+// color temperature applies to every monitor. "OFF" dims the monitor to 0%;
+// "ON" brings it back to its last level.
+func (b *mqttBridge) handleCommand(conn mqttConn, topic string, payload []byte) {
+	slug := strings.TrimSuffix(strings.TrimPrefix(topic, b.base+"/"), "/set")
+	var m monitor.Monitor
+	for _, mon := range monitorList() {
+		if mqttSlug(monitorKey(mon)) == slug {
+			m = mon
+		}
+	}
+	if m == nil {
+		log.Printf("mqtt: command for unknown monitor %q", slug)
+		return
+	}
+	var cmd mqttLight
+	if err := json.Unmarshal(payload, &cmd); err != nil {
+		log.Printf("mqtt: bad command for %s: %v", monitorLabel(m), err)
+		return
+	}
+	log.Printf("mqtt: command for %s: %s", monitorLabel(m), payload)
+	commandMu.Lock()
+	defer commandMu.Unlock()
+
+	if cmd.ColorTemp != nil {
+		manualColorTemp(miredsToKelvin(*cmd.ColorTemp), false)
+	}
+	cur := monitorBrightness(m)
+	level := -1
+	switch {
+	case cmd.Brightness != nil:
+		level = clamp(*cmd.Brightness, 0, 100)
+	case cmd.State == "OFF":
+		level = 0
+	case cmd.State == "ON" && cur == 0:
+		b.mu.Lock()
+		level = b.lastOn[slug]
+		b.mu.Unlock()
+		if level == 0 {
+			level = 100
+		}
+	}
+	if level >= 0 {
+		if level == 0 && cur > 0 {
+			b.mu.Lock()
+			b.lastOn[slug] = cur
+			b.mu.Unlock()
+		}
+		disengageAutoBrightness()
+		if err := setBrightnessFor(monitorKey(m), level); err != nil {
+			log.Printf("mqtt: %v", err)
+		}
+	}
+	b.publishState(conn, m)
+}
+
+// run publishes state changes from the event bus, a batch per
+// mqttCoalesce.
+func (b *mqttBridge) run(events <-chan event) {
+	defer close(b.done)
+	var flush <-chan time.Time
+	dirty := map[string]bool{} // slugs, or "" for every monitor
+	for {
+		select {
+		case <-b.stop:
+			return
+		case ev := <-events:
+			switch ev.Type {
+			case "brightness":
+				var ms monitorStatus
+				if json.Unmarshal(ev.Data, &ms) == nil {
+					dirty[mqttSlug(ms.Key)] = true
+				}
+			case "color_temp":
+				dirty[""] = true
+			case "monitors":
+				b.announce(b.conn)
+				continue
+			default:
+				continue
+			}
+			if flush == nil {
+				flush = time.After(mqttCoalesce)
+			}
+		case <-flush:
+			flush = nil
+			for _, m := range monitorList() {
+				if dirty[""] || dirty[mqttSlug(monitorKey(m))] {
+					b.publishState(b.conn, m)
+				}
+			}
+			clear(dirty)
+		}
+	}
+}
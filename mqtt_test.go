@@ -0,0 +1,220 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alex-vit/monibright/monitor"
+)
+
+// fakeBroker is an in-process stand-in for an MQTT broker and the bridge's
+// connection to it. It keeps retained messages and routes messages to
+// subscriptions, with + matching one topic level.
+type fakeBroker struct {
+	mu       sync.Mutex
+	retained map[string]string
+	subs     map[string]func(string, []byte)
+}
+
+func (f *fakeBroker) Publish(topic string, payload []byte, retain bool) error {
+	f.mu.Lock()
+	if retain && len(payload) == 0 {
+		delete(f.retained, topic)
+	} else if retain {
+		f.retained[topic] = string(payload)
+	}
+	var handlers []func(string, []byte)
+	for filter, h := range f.subs {
+		if topicMatches(filter, topic) {
+			handlers = append(handlers, h)
+		}
+	}
+	f.mu.Unlock()
+	for _, h := range handlers {
+		h(topic, payload)
+	}
+	return nil
+}
+
+func (f *fakeBroker) Subscribe(topic string, handle func(string, []byte)) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.subs[topic] = handle
+	return nil
+}
+
+func (f *fakeBroker) Disconnect() {}
+
+func (f *fakeBroker) get(topic string) (string, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	payload, ok := f.retained[topic]
+	return payload, ok
+}
+
+func topicMatches(filter, topic string) bool {
+	fs, ts := strings.Split(filter, "/"), strings.Split(topic, "/")
+	if len(fs) != len(ts) {
+		return false
+	}
+	for i := range fs {
+		if fs[i] != "+" && fs[i] != ts[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// useMQTT starts a bridge connected to a fake broker.
+func useMQTT(t *testing.T) (*mqttBridge, *fakeBroker) {
+	t.Helper()
+	useEvents(t)
+	broker := &fakeBroker{retained: map[string]string{}, subs: map[string]func(string, []byte){}}
+	prevConnect, prevCoalesce, prevCfg, prevTemp := mqttConnect, mqttCoalesce, cfg, currentColorTemp
+	t.Cleanup(func() {
+		mqttConnect, mqttCoalesce, cfg, currentColorTemp = prevConnect, prevCoalesce, prevCfg, prevTemp
+	})
+	mqttConnect = func(_ mqttConfig, _, _ string, onConnect func(mqttConn)) (mqttConn, error) {
+		onConnect(broker)
+		return broker, nil
+	}
+	mqttCoalesce = time.Millisecond
+
+	b, err := newMQTTBridge(mqttConfig{Broker: "tcp://test:1883"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(b.close)
+	return b, broker
+}
+
+// waitState waits for the retained state of a monitor to match want.
+func waitState(t *testing.T, broker *fakeBroker, topic string, want func(mqttLight) bool) mqttLight {
+	t.Helper()
+	var st mqttLight
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		payload, _ := broker.get(topic)
+		st = mqttLight{}
+		if json.Unmarshal([]byte(payload), &st) == nil && want(st) {
+			return st
+		}
+	}
+	t.Fatalf("%s never matched, last %+v", topic, st)
+	return st
+}
+
+func TestMQTTDiscovery(t *testing.T) {
+	a, b := monitor.NewFakeMonitor(0, 50), monitor.NewFakeMonitor(1, 0)
+	useFakeBackend(t, a, b)
+	bridge, broker := useMQTT(t)
+
+	if got, _ := broker.get(bridge.base + "/availability"); got != "online" {
+		t.Errorf("availability = %q, want online", got)
+	}
+	for _, m := range allMonitors {
+		slug := mqttSlug(monitorKey(m))
+		payload, ok := broker.get(bridge.configTopic(slug))
+		if !ok {
+			t.Fatalf("no discovery config for %s", slug)
+		}
+		var config struct {
+			UniqueID     string `json:"unique_id"`
+			Schema       string `json:"schema"`
+			CommandTopic string `json:"command_topic"`
+			StateTopic   string `json:"state_topic"`
+			MinMireds    int    `json:"min_mireds"`
+			MaxMireds    int    `json:"max_mireds"`
+		}
+		if err := json.Unmarshal([]byte(payload), &config); err != nil {
+			t.Fatal(err)
+		}
+		if config.Schema != "json" || config.CommandTopic != bridge.base+"/"+slug+"/set" ||
+			config.MinMireds != 154 || config.MaxMireds != 286 || !strings.HasSuffix(config.UniqueID, slug) {
+			t.Errorf("discovery config = %s", payload)
+		}
+	}
+
+	st := waitState(t, broker, bridge.base+"/"+mqttSlug(monitorKey(allMonitors[0]))+"/state", func(mqttLight) bool { return true })
+	if st.State != "ON" || *st.Brightness != 50 || *st.ColorTemp != kelvinToMireds(currentColorTemp) {
+		t.Errorf("state of a = %+v", st)
+	}
+	st = waitState(t, broker, bridge.base+"/"+mqttSlug(monitorKey(allMonitors[1]))+"/state", func(mqttLight) bool { return true })
+	if st.State != "OFF" {
+		t.Errorf("monitor at 0%% reported %q, want OFF", st.State)
+	}
+
+	// A monitor that goes away loses its light.
+	gone := mqttSlug(monitorKey(allMonitors[1]))
+	backend = &monitor.Fake{Displays: []*monitor.FakeMonitor{a}}
+	refreshMonitors()
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if _, ok := broker.get(bridge.configTopic(gone)); !ok {
+			return
+		}
+	}
+	t.Error("discovery config of unplugged monitor not removed")
+}
+
+func TestMQTTCommands(t *testing.T) {
+	a := monitor.NewFakeMonitor(0, 50)
+	useFakeBackend(t, a)
+	bridge, broker := useMQTT(t)
+	topic := bridge.base + "/" + mqttSlug(monitorKey(allMonitors[0]))
+	send := func(cmd string) {
+		t.Helper()
+		if err := broker.Publish(topic+"/set", []byte(cmd), false); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	send(`{"state": "ON", "brightness": 30}`)
+	if got := a.VCP[monitor.VCPBrightness]; got != 30 {
+		t.Errorf("brightness = %d, want 30", got)
+	}
+	send(`{"color_temp": 250}`)
+	if currentColorTemp != 4000 {
+		t.Errorf("color temp = %dK, want 4000K", currentColorTemp)
+	}
+	waitState(t, broker, topic+"/state", func(st mqttLight) bool {
+		return st.Brightness != nil && *st.Brightness == 30 && st.ColorTemp != nil && *st.ColorTemp == 250
+	})
+
+	send(`{"state": "OFF"}`)
+	if got := a.VCP[monitor.VCPBrightness]; got != 0 {
+		t.Errorf("brightness after OFF = %d, want 0", got)
+	}
+	send(`{"state": "ON"}`)
+	if got := a.VCP[monitor.VCPBrightness]; got != 30 {
+		t.Errorf("brightness after ON = %d, want 30 restored", got)
+	}
+
+	// Changes made elsewhere are published too.
+	applyBrightness(allMonitors, 80)
+	waitState(t, broker, topic+"/state", func(st mqttLight) bool { return st.Brightness != nil && *st.Brightness == 80 })
+
+	send(`not json`)
+	if err := broker.Publish(bridge.base+"/unknown/set", []byte(`{"brightness": 10}`), false); err != nil {
+		t.Fatal(err)
+	}
+	if got := a.VCP[monitor.VCPBrightness]; got != 80 {
+		t.Errorf("brightness after bad commands = %d, want 80", got)
+	}
+}
+
+func TestMireds(t *testing.T) {
+	tests := []struct{ kelvin, mireds int }{{6500, 154}, {4000, 250}, {3500, 286}}
+	for _, tt := range tests {
+		if got := kelvinToMireds(tt.kelvin); got != tt.mireds {
+			t.Errorf("kelvinToMireds(%d) = %d, want %d", tt.kelvin, got, tt.mireds)
+		}
+		if got := miredsToKelvin(tt.mireds); got < tt.kelvin-50 || got > tt.kelvin+50 {
+			t.Errorf("miredsToKelvin(%d) = %d, want about %d", tt.mireds, got, tt.kelvin)
+		}
+	}
+	if got := miredsToKelvin(500); got != tempMin {
+		t.Errorf("miredsToKelvin(500) = %d, want clamped to %d", got, tempMin)
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alex-vit/monibright/monitor"
+)
+
+// Profile animation: brightness and contrast step over about half a second.
+const (
+	profileSteps = 8
+	profilePause = 60 * time.Millisecond
+)
+
+var (
+	profileMu   sync.Mutex
+	lastProfile string // name of the last profile applied
+)
+
+// profile is a named scene such as "Reading" or "Movie", applied from the
+// tray menu or a hotkey. The top-level brightness, contrast (percent) and
+// input apply to every monitor; Monitors overrides them per monitor selector
+// or group (see resolveTarget), e.g.
+// {"name": "Movie", "brightness": 40, "temp": 5000, "monitors": {"U2722D": {"input": "HDMI1"}}}.
+// Unset fields are left as they are.
+type profile struct {
+	Name    string `json:"name"`
+	Hotkey  string `json:"hotkey,omitempty"`
+	Temp    *int   `json:"temp,omitempty"`
+	Animate bool   `json:"animate,omitempty"`
+	profileLevels
+	Monitors map[string]profileLevels `json:"monitors,omitempty"`
+}
+
+type profileLevels struct {
+	Brightness *int   `json:"brightness,omitempty"`
+	Contrast   *int   `json:"contrast,omitempty"`
+	Input      string `json:"input,omitempty"`
+}
+
+// over returns l with the fields set in o replaced.
+func (l profileLevels) over(o profileLevels) profileLevels {
+	if o.Brightness != nil {
+		l.Brightness = o.Brightness
+	}
+	if o.Contrast != nil {
+		l.Contrast = o.Contrast
+	}
+	if o.Input != "" {
+		l.Input = o.Input
+	}
+	return l
+}
+
+// profilePlan is a profile resolved against the current monitors.
+type profilePlan struct {
+	writes     []monitor.Write
+	brightness map[monitor.Monitor]int // percent, per monitor written
+	input      bool                    // switches an input source
+}
+
+func findProfile(name string) (*profile, bool) {
+	profiles := cfgSnapshot().Profiles
+	for i := range profiles {
+		if strings.EqualFold(profiles[i].Name, name) {
+			return &profiles[i], true
+		}
+	}
+	return nil, false
+}
+
+// planProfile resolves p into VCP writes for the current monitors without
+// writing anything. Brightness and contrast come first; input switches go
+// last because monitors drop DDC/CI while switching.
+func planProfile(p *profile) (*profilePlan, error) {
+	perMonitor := map[monitor.Monitor]profileLevels{}
+	for _, m := range monitorList() {
+		perMonitor[m] = p.profileLevels
+	}
+	sels := make([]string, 0, len(p.Monitors))
+	for sel := range p.Monitors {
+		sels = append(sels, sel)
+	}
+	slices.Sort(sels)
+	for _, sel := range sels {
+		targets, err := resolveTarget(sel)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range targets {
+			perMonitor[m] = perMonitor[m].over(p.Monitors[sel])
+		}
+	}
+
+	plan := &profilePlan{brightness: map[monitor.Monitor]int{}}
+	var inputs []monitor.Write
+	for _, m := range monitorList() {
+		l := perMonitor[m]
+		if l.Brightness != nil && supportsVCP(m, monitor.VCPBrightness) {
+			level := clamp(*l.Brightness, 0, 100)
+			plan.writes = append(plan.writes, monitor.Write{Monitor: m, Code: monitor.VCPBrightness, Value: brightnessRaw(m, level)})
+			plan.brightness[m] = level
+		}
+		if l.Contrast != nil && supportsVCP(m, monitor.VCPContrast) {
+			_, maxValue, err := m.GetVCP(monitor.VCPContrast)
+			if err != nil {
+				return nil, fmt.Errorf("monitor %s: read contrast: %w", monitorLabel(m), err)
+			}
+			raw := percentToRaw(clamp(*l.Contrast, 0, 100), maxValue)
+			plan.writes = append(plan.writes, monitor.Write{Monitor: m, Code: monitor.VCPContrast, Value: raw})
+		}
+		if l.Input != "" {
+			value, ok := monitor.ParseInput(l.Input)
+			if !ok {
+				return nil, fmt.Errorf("unknown input %q", l.Input)
+			}
+			if known := monitorInputs(m); known != nil && !slices.Contains(known, value) {
+				log.Printf("monitor %s: input %s not supported", monitorLabel(m), monitor.InputName(value))
+				continue
+			}
+			inputs = append(inputs, monitor.Write{Monitor: m, Code: monitor.VCPInputSource, Value: value})
+		}
+	}
+	plan.writes = append(plan.writes, inputs...)
+	plan.input = len(inputs) > 0
+	return plan, nil
+}
+
+// applyProfile applies the named profile. The monitor settings are written as
+// one batch: if any monitor can't be read or written, the ones already
+// changed are restored and the color temperature is left alone. Like other
+// manual changes, a brightness or temperature in the profile turns the
+// matching auto mode off.
+func applyProfile(name string) error {
+	p, ok := findProfile(name)
+	if !ok {
+		return fmt.Errorf("no profile %q", name)
+	}
+	steps := 1
+	if p.Animate {
+		steps = profileSteps
+	}
+	// The plan holds monitor handles, so no rescan may close them before
+	// the writes are done.
+	brightnessMu.Lock()
+	plan, err := planProfile(p)
+	if err == nil {
+		if len(plan.brightness) > 0 {
+			disengageAutoBrightness()
+		}
+		err = monitor.Transition(plan.writes, steps, profilePause)
+	}
+	brightnessMu.Unlock()
+	if err != nil {
+		return fmt.Errorf("profile %q: %w", p.Name, err)
+	}
+	for m, level := range plan.brightness {
+		recordBrightness(m, level)
+	}
+	if p.Temp != nil {
+		manualColorTemp(*p.Temp, p.Animate)
+	}
+	log.Printf("profile %q applied (%d monitor writes)", p.Name, len(plan.writes))
+	profileMu.Lock()
+	lastProfile = p.Name
+	profileMu.Unlock()
+	publishProfile(p.Name)
+
+	if plan.input {
+		time.Sleep(inputSwitchSettle)
+		brightnessMu.Lock()
+		refreshMonitors()
+		brightnessMu.Unlock()
+	}
+	updateIcon()
+	if tray := trayMonitor(); tray != nil {
+		for m, level := range plan.brightness {
+			if monitorKey(m) == monitorKey(tray) {
+				syncSlider(level)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/alex-vit/monibright/monitor"
+)
+
+// useProfiles installs profiles in the config for the test.
+func useProfiles(t *testing.T, profiles ...profile) {
+	t.Helper()
+	prev, prevTemp, prevSettle := cfg, currentColorTemp, inputSwitchSettle
+	t.Cleanup(func() { cfg, currentColorTemp, inputSwitchSettle = prev, prevTemp, prevSettle })
+	cfg.Profiles = profiles
+	inputSwitchSettle = 0
+}
+
+func TestApplyProfile(t *testing.T) {
+	a, b := monitor.NewFakeMonitor(0, 80), monitor.NewFakeMonitor(1, 80)
+	a.VCP[monitor.VCPContrast] = 75
+	b.VCP[monitor.VCPContrast] = 150
+	b.Max = map[byte]int{monitor.VCPContrast: 200}
+	b.VCP[monitor.VCPInputSource] = 0x0F
+	fake := useFakeBackend(t, a, b)
+	useProfiles(t, profile{
+		Name:          "Movie",
+		Temp:          intp(5000),
+		profileLevels: profileLevels{Brightness: intp(40), Contrast: intp(50)},
+		Monitors:      map[string]profileLevels{"1": {Brightness: intp(70), Input: "HDMI1"}},
+	})
+
+	if err := applyProfile("movie"); err != nil {
+		t.Fatal(err)
+	}
+	if got := a.VCP[monitor.VCPBrightness]; got != 40 {
+		t.Errorf("a brightness = %d, want 40", got)
+	}
+	if got := a.VCP[monitor.VCPContrast]; got != 50 {
+		t.Errorf("a contrast = %d, want 50", got)
+	}
+	if got := b.VCP[monitor.VCPBrightness]; got != 70 {
+		t.Errorf("b brightness = %d, want 70", got)
+	}
+	if got := b.VCP[monitor.VCPContrast]; got != 100 {
+		t.Errorf("b contrast = %d, want 100 (50%% of 200)", got)
+	}
+	if got := b.VCP[monitor.VCPInputSource]; got != 0x11 {
+		t.Errorf("b input = 0x%02X, want 0x11", got)
+	}
+	if currentColorTemp != 5000 || cfg.ManualTemp != 5000 {
+		t.Errorf("color temp = %dK, manual = %dK, want 5000K", currentColorTemp, cfg.ManualTemp)
+	}
+	if got := cfg.Monitors[monitorKey(b)].Brightness; got != 70 {
+		t.Errorf("remembered b brightness = %d, want 70", got)
+	}
+	if fake.Enumerations != 2 {
+		t.Errorf("enumerations = %d, want 2 (refresh after input switch)", fake.Enumerations)
+	}
+}
+
+func TestApplyProfileRollsBack(t *testing.T) {
+	a, b := monitor.NewFakeMonitor(0, 80), monitor.NewFakeMonitor(1, 60)
+	b.SetErr = errors.New("bus error")
+	useFakeBackend(t, a, b)
+	useProfiles(t, profile{Name: "Reading", Temp: intp(4500), profileLevels: profileLevels{Brightness: intp(50)}})
+	currentColorTemp = 6500
+
+	if err := applyProfile("Reading"); err == nil {
+		t.Fatal("no error")
+	}
+	if got := a.VCP[monitor.VCPBrightness]; got != 80 {
+		t.Errorf("a brightness = %d, want 80 (restored)", got)
+	}
+	if currentColorTemp != 6500 {
+		t.Errorf("color temp = %dK, want unchanged 6500K", currentColorTemp)
+	}
+}
+
+func TestApplyProfileInvalid(t *testing.T) {
+	a := monitor.NewFakeMonitor(0, 80)
+	useFakeBackend(t, a)
+	useProfiles(t,
+		profile{Name: "Bad monitor", profileLevels: profileLevels{Brightness: intp(10)},
+			Monitors: map[string]profileLevels{"Nonexistent": {Brightness: intp(20)}}},
+		profile{Name: "Bad input", profileLevels: profileLevels{Brightness: intp(10), Input: "HDMI9"}},
+	)
+
+	for _, name := range []string{"Bad monitor", "Bad input", "Missing"} {
+		if err := applyProfile(name); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+	if len(a.Sets) != 0 {
+		t.Errorf("writes = %v, want none", a.Sets)
+	}
+}
+
+func TestApplyProfileDisengagesAutoBrightness(t *testing.T) {
+	a := monitor.NewFakeMonitor(0, 80)
+	useFakeBackend(t, a)
+	useProfiles(t, profile{Name: "Dim", profileLevels: profileLevels{Brightness: intp(20)}})
+	cfg.Latitude, cfg.Longitude = 52.52, 13.41
+	cfg.AutoBrightnessEnabled = true
+	startAutoBrightness()
+	done := autoBrightnessDone
+	t.Cleanup(func() {
+		stopAutoBrightness()
+		<-done
+	})
+
+	if err := applyProfile("Dim"); err != nil {
+		t.Fatal(err)
+	}
+	if autoBrightnessActive || cfg.AutoBrightnessEnabled {
+		t.Error("auto brightness still on")
+	}
+	if got := a.VCP[monitor.VCPBrightness]; got != 20 {
+		t.Errorf("brightness = %d, want 20", got)
+	}
+}
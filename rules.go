@@ -0,0 +1,331 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/Knetic/govaluate.v3"
+)
+
+// rule overrides the day/night levels during a time window, e.g.
+// {"days": "Mon-Fri", "from": "09:00", "to": "17:00", "brightness": 80, "temp": 6500}.
+// A to before from runs past midnight; an empty from or to means start or
+// end of day. The optional when is a govaluate expression over the
+// variables in ruleParams. Rules that match at the same time are layered in
+// priority order (list order on ties), so the highest priority wins per
+// level.
+type rule struct {
+	Name       string `json:"name,omitempty"`
+	Days       string `json:"days,omitempty"` // "Mon-Fri", "Sat,Sun", "weekends"; empty is every day
+	From       string `json:"from,omitempty"` // "22:00"
+	To         string `json:"to,omitempty"`   // "07:00"
+	When       string `json:"when,omitempty"` // e.g. "elevation < 10 && weekday != 'Sun'"
+	Brightness *int   `json:"brightness,omitempty"`
+	Temp       *int   `json:"temp,omitempty"`
+	Priority   int    `json:"priority,omitempty"`
+	Transition int    `json:"transition,omitempty"` // minutes to fade in after from and out before to
+
+	compiled *compiledRule
+}
+
+// compiledRule is the parsed form of a rule; nil for invalid rules.
+type compiledRule struct {
+	days     [7]bool
+	from, to int // minutes since midnight
+	hasFrom  bool
+	hasTo    bool
+	when     *govaluate.EvaluableExpression
+}
+
+// levels are the brightness (percent) and color temperature (K) the
+// schedule asks for.
+type levels struct {
+	Brightness int
+	Temp       int
+}
+
+// ruleInput is everything evaluateRules looks at.
+type ruleInput struct {
+	Now      time.Time // in the schedule zone
+	Lat, Lon float64   // 0, 0 when unknown
+	Daylight float64   // day fraction of the sun or fixed schedule, 0 (night) to 1 (day)
+	Base     levels    // day/night levels from the schedule
+	Current  levels    // levels last applied; 0 when not yet known
+}
+
+var (
+	appliedMu sync.Mutex
+	applied   levels // last levels applied by auto color and auto brightness
+)
+
+// compileRules parses cfg.Rules. Invalid rules are logged and skipped but
+// kept in the config.
+func compileRules() {
+	for i := range cfg.Rules {
+		r := &cfg.Rules[i]
+		c, err := r.compile()
+		if err != nil {
+			log.Printf("config: rule %d (%s): %v, ignoring", i+1, r.label(), err)
+		}
+		r.compiled = c
+	}
+}
+
+func (r *rule) label() string {
+	if r.Name != "" {
+		return r.Name
+	}
+	return strings.TrimSpace(r.Days + " " + r.From + "-" + r.To)
+}
+
+func (r *rule) compile() (*compiledRule, error) {
+	if r.Brightness == nil && r.Temp == nil {
+		return nil, fmt.Errorf("sets neither brightness nor temp")
+	}
+	if r.Transition < 0 {
+		return nil, fmt.Errorf("negative transition")
+	}
+	c := &compiledRule{}
+	var err error
+	if c.days, err = parseDays(r.Days); err != nil {
+		return nil, err
+	}
+	if r.From != "" {
+		if c.from, err = parseClock(r.From); err != nil {
+			return nil, err
+		}
+		c.hasFrom = true
+	}
+	if r.To != "" {
+		if c.to, err = parseClock(r.To); err != nil {
+			return nil, err
+		}
+		c.hasTo = true
+	}
+	if r.When != "" {
+		if c.when, err = govaluate.NewEvaluableExpression(r.When); err != nil {
+			return nil, fmt.Errorf("when: %w", err)
+		}
+		// Catch unknown variables and non-boolean results now rather than
+		// on every tick.
+		sample := ruleParams(ruleInput{Now: time.Now(), Base: levels{100, 6500}, Current: levels{100, 6500}})
+		v, err := c.when.Evaluate(sample)
+		if err != nil {
+			return nil, fmt.Errorf("when: %w", err)
+		}
+		if _, ok := v.(bool); !ok {
+			return nil, fmt.Errorf("when: %q is not a condition", r.When)
+		}
+	}
+	return c, nil
+}
+
+// parseClock parses "HH:MM" into minutes since midnight.
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("bad time %q, want HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// parseDays parses a comma-separated list of days and ranges, e.g.
+// "Mon-Fri", "Sat,Sun" or "Fri-Mon". "weekdays" and "weekends" are
+// shorthands. Empty means every day.
+func parseDays(s string) (days [7]bool, err error) {
+	s = strings.ToLower(strings.ReplaceAll(s, "–", "-"))
+	if strings.TrimSpace(s) == "" {
+		s = "sun-sat"
+	}
+	s = strings.NewReplacer("weekdays", "mon-fri", "weekends", "sat,sun").Replace(s)
+	day := func(name string) (time.Weekday, error) {
+		name = strings.TrimSpace(name)
+		if len(name) >= 3 {
+			if d, ok := weekdayNames[name[:3]]; ok {
+				return d, nil
+			}
+		}
+		return 0, fmt.Errorf("bad day %q", name)
+	}
+	for _, part := range strings.Split(s, ",") {
+		first, last, isRange := strings.Cut(part, "-")
+		from, err := day(first)
+		if err != nil {
+			return days, err
+		}
+		to := from
+		if isRange {
+			if to, err = day(last); err != nil {
+				return days, err
+			}
+		}
+		for d := from; ; d = (d + 1) % 7 {
+			days[d] = true
+			if d == to {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+// window returns the occurrence of c's time window containing now and how
+// far into its transition now is: 0 at the edges, 1 once fully faded in.
+// Windows belong to the day they start on, so an overnight window matches
+// by yesterday's weekday after midnight.
+func (c *compiledRule) window(now time.Time, transition time.Duration) (frac float64, ok bool) {
+	at := func(day time.Time, minutes int) time.Time {
+		return time.Date(day.Year(), day.Month(), day.Day(), 0, minutes, 0, 0, day.Location())
+	}
+	for _, back := range []int{0, -1} {
+		day := now.AddDate(0, 0, back)
+		if !c.days[day.Weekday()] {
+			continue
+		}
+		start := at(day, c.from)
+		end := at(day.AddDate(0, 0, 1), 0)
+		if c.hasTo {
+			if end = at(day, c.to); !end.After(start) {
+				end = at(day.AddDate(0, 0, 1), c.to)
+			}
+		}
+		if now.Before(start) || !now.Before(end) {
+			continue
+		}
+		frac = 1
+		if transition > 0 {
+			if c.hasFrom {
+				frac = min(frac, float64(now.Sub(start))/float64(transition))
+			}
+			if c.hasTo {
+				frac = min(frac, float64(end.Sub(now))/float64(transition))
+			}
+		}
+		return frac, true
+	}
+	return 0, false
+}
+
+// ruleParams returns the variables available to when expressions.
+func ruleParams(in ruleInput) map[string]any {
+	located := in.Lat != 0 || in.Lon != 0
+	elevation := 0.0
+	if located {
+		elevation = solarElevation(in.Now, in.Lat, in.Lon)
+	}
+	wd := in.Now.Weekday()
+	return map[string]any{
+		"hour":       float64(in.Now.Hour()) + float64(in.Now.Minute())/60, // 22.5 is 22:30
+		"weekday":    wd.String()[:3],                                      // "Mon"
+		"weekend":    wd == time.Saturday || wd == time.Sunday,
+		"month":      float64(in.Now.Month()),
+		"located":    located,
+		"elevation":  elevation, // degrees; 0 when not located
+		"daylight":   in.Daylight,
+		"brightness": float64(in.Current.Brightness),
+		"temp":       float64(in.Current.Temp),
+	}
+}
+
+// evaluateRules layers the matching rules over the base levels and returns
+// the result with the names of the rules that applied. It depends only on
+// its arguments.
+func evaluateRules(rules []rule, in ruleInput) (levels, []string) {
+	type match struct {
+		r    *rule
+		frac float64
+	}
+	var matches []match
+	var params map[string]any
+	for i := range rules {
+		r := &rules[i]
+		c := r.compiled
+		if c == nil {
+			continue
+		}
+		frac, ok := c.window(in.Now, time.Duration(r.Transition)*time.Minute)
+		if !ok {
+			continue
+		}
+		if c.when != nil {
+			if params == nil {
+				params = ruleParams(in)
+			}
+			if v, err := c.when.Evaluate(params); err != nil || v != true {
+				continue
+			}
+		}
+		matches = append(matches, match{r, frac})
+	}
+	slices.SortStableFunc(matches, func(a, b match) int { return a.r.Priority - b.r.Priority })
+
+	out := in.Base
+	var names []string
+	lerp := func(from, to int, frac float64) float64 {
+		return float64(from) + frac*float64(to-from)
+	}
+	for _, m := range matches {
+		if m.r.Brightness != nil {
+			out.Brightness = int(math.Round(lerp(out.Brightness, clamp(*m.r.Brightness, 0, 100), m.frac)))
+		}
+		if m.r.Temp != nil {
+			out.Temp = int(math.Round(lerp(out.Temp, clamp(*m.r.Temp, tempMin, tempMax), m.frac)))
+			if m.frac < 1 {
+				out.Temp = roundTo100(out.Temp)
+			}
+		}
+		names = append(names, m.r.label())
+	}
+	return out, names
+}
+
+// scheduledLevels returns the levels for now: the day/night blend of sched,
+// overridden by any matching rules.
+func scheduledLevels(now time.Time, sched sunSchedule) (levels, []string) {
+	daylight, _ := blend(now, sched, 1, 0)
+	appliedMu.Lock()
+	current := applied
+	appliedMu.Unlock()
+	c := cfgSnapshot()
+	return evaluateRules(c.Rules, ruleInput{
+		Now:      now.In(scheduleZone()),
+		Lat:      c.Latitude,
+		Lon:      c.Longitude,
+		Daylight: daylight,
+		Base: levels{
+			Brightness: interpolateBrightness(now, sched, c.DayBrightness, c.NightBrightness),
+			Temp:       interpolateTemp(now, sched, c.DayTemp, c.NightTemp),
+		},
+		Current: current,
+	})
+}
+
+// scheduledTemp returns the color temperature scheduledLevels asks for.
+func scheduledTemp(now time.Time, sched sunSchedule) int {
+	lv, _ := scheduledLevels(now, sched)
+	return lv.Temp
+}
+
+// noteAppliedTemp and noteAppliedBrightness record levels the schedule
+// applied, as state for the next evaluation.
+func noteAppliedTemp(kelvin int) {
+	appliedMu.Lock()
+	defer appliedMu.Unlock()
+	applied.Temp = kelvin
+}
+
+func noteAppliedBrightness(level int) {
+	appliedMu.Lock()
+	defer appliedMu.Unlock()
+	applied.Brightness = level
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func intp(v int) *int { return &v }
+
+func TestParseDays(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []time.Weekday
+		err  bool
+	}{
+		{"", []time.Weekday{0, 1, 2, 3, 4, 5, 6}, false},
+		{"Mon–Fri", []time.Weekday{1, 2, 3, 4, 5}, false},
+		{"Fri-Mon", []time.Weekday{0, 1, 5, 6}, false},
+		{"sat, Sunday", []time.Weekday{0, 6}, false},
+		{"weekends", []time.Weekday{0, 6}, false},
+		{"weekdays,sun", []time.Weekday{0, 1, 2, 3, 4, 5}, false},
+		{"Mon-Funday", nil, true},
+		{"Mo", nil, true},
+	}
+	for _, tt := range tests {
+		days, err := parseDays(tt.in)
+		if (err != nil) != tt.err {
+			t.Errorf("parseDays(%q): err = %v", tt.in, err)
+			continue
+		}
+		if tt.err {
+			continue
+		}
+		var got []time.Weekday
+		for d, ok := range days {
+			if ok {
+				got = append(got, time.Weekday(d))
+			}
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("parseDays(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRuleCompileErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		r    rule
+	}{
+		{"no levels", rule{From: "09:00"}},
+		{"bad time", rule{From: "9am", Brightness: intp(50)}},
+		{"bad days", rule{Days: "Someday", Brightness: intp(50)}},
+		{"negative transition", rule{Transition: -1, Brightness: intp(50)}},
+		{"syntax", rule{When: "hour >", Brightness: intp(50)}},
+		{"unknown variable", rule{When: "humidity > 50", Brightness: intp(50)}},
+		{"not a condition", rule{When: "hour + 1", Brightness: intp(50)}},
+	}
+	for _, tt := range tests {
+		if _, err := tt.r.compile(); err == nil {
+			t.Errorf("%s: compiled without error", tt.name)
+		}
+	}
+}
+
+func TestEvaluateRules(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules := []rule{
+		{Name: "work", Days: "Mon-Fri", From: "09:00", To: "17:00", Brightness: intp(80), Temp: intp(6500), Transition: 30},
+		{Name: "evening", From: "22:00", Brightness: intp(30), Temp: intp(3400)},
+		{Name: "late shift", Days: "Fri", From: "23:00", To: "02:00", Brightness: intp(60), Priority: 1},
+		{Name: "bright dusk", When: "located && elevation < 0 && elevation > -6 && weekday != 'Sun'", Brightness: intp(90), Priority: 2},
+		{Name: "dim only if bright", From: "12:00", To: "13:00", When: "brightness >= 70", Temp: intp(5000)},
+		{Name: "broken", From: "25:00", Brightness: intp(1)},
+	}
+	for i := range rules {
+		rules[i].compiled, _ = rules[i].compile()
+	}
+	base := levels{Brightness: 50, Temp: 4500}
+
+	tests := []struct {
+		name     string
+		at       string
+		lat, lon float64
+		current  levels
+		want     levels
+		wantRule []string
+	}{
+		{"no rule", "2024-06-05 08:00", 0, 0, levels{}, base, nil},
+		{"fading in", "2024-06-05 09:15", 0, 0, levels{}, levels{65, 5500}, []string{"work"}},
+		{"working", "2024-06-05 12:00", 0, 0, levels{}, levels{80, 6500}, []string{"work"}},
+		{"fading out", "2024-06-05 16:45", 0, 0, levels{}, levels{65, 5500}, []string{"work"}},
+		{"weekend", "2024-06-08 12:00", 0, 0, levels{}, base, nil},
+		{"evening clamps temp", "2024-06-05 22:30", 0, 0, levels{}, levels{30, 3500}, []string{"evening"}},
+		{"evening ends at midnight", "2024-06-06 00:30", 0, 0, levels{}, base, nil},
+		{"priority wins per level", "2024-06-07 23:30", 0, 0, levels{}, levels{60, 3500}, []string{"evening", "late shift"}},
+		{"overnight matches by start day", "2024-06-08 01:00", 0, 0, levels{}, levels{60, 4500}, []string{"late shift"}},
+		{"overnight ended", "2024-06-08 02:00", 0, 0, levels{}, base, nil},
+		{"overnight other day", "2024-06-09 01:00", 0, 0, levels{}, base, nil},
+		{"state condition true", "2024-06-08 12:30", 0, 0, levels{Brightness: 80}, levels{50, 5000}, []string{"dim only if bright"}},
+		{"state condition false", "2024-06-08 12:30", 0, 0, levels{Brightness: 40}, base, nil},
+		// Berlin civil dusk on 2024-06-05 is around 22:19 CEST.
+		{"location condition", "2024-06-05 21:50", 52.52, 13.41, levels{}, levels{90, 4500}, []string{"bright dusk"}},
+		{"location condition, not located", "2024-06-05 21:50", 0, 0, levels{}, base, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now, err := time.ParseInLocation("2006-01-02 15:04", tt.at, loc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, names := evaluateRules(rules, ruleInput{Now: now, Lat: tt.lat, Lon: tt.lon, Base: base, Current: tt.current})
+			if got != tt.want {
+				t.Errorf("levels = %+v, want %+v", got, tt.want)
+			}
+			if !slices.Equal(names, tt.wantRule) {
+				t.Errorf("rules = %v, want %v", names, tt.wantRule)
+			}
+		})
+	}
+}
+
+func TestRuleWindowDST(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Clocks go forward at 02:00 on 2024-03-31, so the 01:00–03:00 window
+	// is one hour long and 01:30 is already halfway out.
+	c, err := (&rule{From: "01:00", To: "03:00", Brightness: intp(10)}).compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 3, 31, 1, 30, 0, 0, loc)
+	frac, ok := c.window(now, time.Hour)
+	if !ok || frac != 0.5 {
+		t.Errorf("window(%s) = %v, %v; want 0.5, true", now, frac, ok)
+	}
+}
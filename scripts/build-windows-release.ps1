@@ -1,6 +1,8 @@
 param(
   [string]$Version,
-  [string]$Arch = "amd64"
+  [string]$Arch = "amd64",
+  # Release builds must embed the update public key and sign the exe.
+  [switch]$Release
 )
 
 Set-StrictMode -Version Latest
@@ -30,6 +32,24 @@ try {
   $env:GOARCH = $Arch
   $env:CGO_ENABLED = "0"
   $ldflags = "-X main.version=$Version -H=windowsgui"
+  # Minisign public key (the base64 line of minisign.pub) the self-updater
+  # checks releases against; without it the build refuses all updates.
+  $pubKey = $env:MONIBRIGHT_UPDATE_PUBKEY
+  # Matching secret key (the contents of a minisign.key made with
+  # "minisign -G -W", i.e. without a password) to sign the exe with.
+  $secretKey = $env:MINISIGN_SECRET_KEY
+  if ($Release) {
+    if ([string]::IsNullOrWhiteSpace($pubKey)) {
+      throw "MONIBRIGHT_UPDATE_PUBKEY is not set; the release would refuse every update"
+    }
+    if ([string]::IsNullOrWhiteSpace($secretKey)) {
+      throw "MINISIGN_SECRET_KEY is not set; the release would not be signed"
+    }
+  }
+  if (-not [string]::IsNullOrWhiteSpace($pubKey)) {
+    $pubKey = $pubKey.Trim()
+    $ldflags += " -X main.updatePublicKey=$pubKey"
+  }
   go build -ldflags $ldflags -o (Join-Path $outDir "monibright.exe") .
   if ($LASTEXITCODE -ne 0) {
     throw "go build failed"
@@ -61,8 +81,46 @@ try {
     throw "Missing installer: $setupPath"
   }
 
+  # Detached signature the self-updater verifies before installing.
+  $sigPath = "$exePath.minisig"
+  if (-not [string]::IsNullOrWhiteSpace($secretKey)) {
+    $minisign = Get-Command minisign.exe -ErrorAction SilentlyContinue
+    if (-not $minisign) {
+      throw "minisign.exe not found in PATH"
+    }
+    $keyFile = New-TemporaryFile
+    try {
+      [IO.File]::WriteAllText($keyFile.FullName, $secretKey.Trim() + "`n")
+      & $minisign.Source -S -s $keyFile.FullName -m $exePath -x $sigPath -t "monibright $Version"
+      if ($LASTEXITCODE -ne 0) {
+        throw "minisign failed"
+      }
+    }
+    finally {
+      Remove-Item $keyFile.FullName -Force
+    }
+    if (-not [string]::IsNullOrWhiteSpace($pubKey)) {
+      & $minisign.Source -V -P $pubKey -m $exePath -x $sigPath
+      if ($LASTEXITCODE -ne 0) {
+        throw "signature does not verify against MONIBRIGHT_UPDATE_PUBKEY"
+      }
+    }
+  }
+
+  # sha256sum-style manifest the self-updater checks downloads against.
+  $sumsPath = Join-Path $outDir "checksums.txt"
+  $sums = foreach ($path in @($exePath, $setupPath)) {
+    $hash = (Get-FileHash -Algorithm SHA256 $path).Hash.ToLowerInvariant()
+    "$hash  $(Split-Path -Leaf $path)"
+  }
+  [IO.File]::WriteAllText($sumsPath, ($sums -join "`n") + "`n")
+
   Write-Host "Built executable: $exePath"
   Write-Host "Built installer:  $setupPath"
+  Write-Host "Wrote checksums:  $sumsPath"
+  if (Test-Path $sigPath) {
+    Write-Host "Signed:           $sigPath"
+  }
 }
 finally {
   Pop-Location
@@ -428,16 +428,18 @@ func settingsWndProc(hwnd, msg, wParam, lParam uintptr) uintptr {
 }
 
 func loadSettingsValues() {
+	c := cfgSnapshot()
+
 	// Auto color checkbox
-	if cfg.AutoColorEnabled {
+	if c.AutoColorEnabled {
 		procSendMessageW.Call(chkAutoColor, BM_SETCHECK, BST_CHECKED, 0) //nolint:errcheck
 	} else {
 		procSendMessageW.Call(chkAutoColor, BM_SETCHECK, 0, 0) //nolint:errcheck
 	}
 
 	// Day/Night temp spinners
-	procSendMessageW.Call(udDayTemp, UDM_SETPOS32, 0, uintptr(cfg.DayTemp))     //nolint:errcheck
-	procSendMessageW.Call(udNightTemp, UDM_SETPOS32, 0, uintptr(cfg.NightTemp)) //nolint:errcheck
+	procSendMessageW.Call(udDayTemp, UDM_SETPOS32, 0, uintptr(c.DayTemp))     //nolint:errcheck
+	procSendMessageW.Call(udNightTemp, UDM_SETPOS32, 0, uintptr(c.NightTemp)) //nolint:errcheck
 
 	// Autostart checkbox
 	if isAutostartEnabled() {
@@ -493,24 +495,26 @@ func settingsOnOK(hwnd uintptr) {
 	}
 
 	// Apply auto color changes
-	wasAutoColor := cfg.AutoColorEnabled
-	oldDayTemp := cfg.DayTemp
-	oldNightTemp := cfg.NightTemp
-	cfg.DayTemp = dayTemp
-	cfg.NightTemp = nightTemp
-	cfg.AutoColorEnabled = newAutoColor
-	saveConfig()
+	old := cfgSnapshot()
+	wasAutoColor := old.AutoColorEnabled
+	oldDayTemp := old.DayTemp
+	oldNightTemp := old.NightTemp
+	updateConfig(func(c *config) {
+		c.DayTemp = dayTemp
+		c.NightTemp = nightTemp
+		c.AutoColorEnabled = newAutoColor
+	})
 
 	log.Printf("settings: saved (auto_color=%v day=%dK night=%dK autostart=%v)",
 		newAutoColor, dayTemp, nightTemp, newAutostart)
 
 	if newAutoColor && !wasAutoColor {
 		// Turning on: start auto color
-		go startAutoColor(currentColorTemp)
+		go startAutoColor(colorTemp())
 		syncAutoToggle()
 	} else if !newAutoColor && wasAutoColor {
 		// Turning off: stop auto color, restore manual temp
-		from := currentColorTemp
+		from := colorTemp()
 		stopAutoColor()
 		syncAutoToggle()
 		go func() {
@@ -519,7 +523,7 @@ func settingsOnOK(hwnd uintptr) {
 	} else if newAutoColor && (dayTemp != oldDayTemp || nightTemp != oldNightTemp) {
 		// Temps changed while auto color is on: restart to pick up new values
 		stopAutoColor()
-		go startAutoColor(currentColorTemp)
+		go startAutoColor(colorTemp())
 		syncAutoToggle()
 	}
 
@@ -95,6 +95,10 @@ const (
 	PBT_APMRESUMEAUTOMATIC = 0x0012
 	PBT_POWERSETTINGCHANGE = 0x8013
 
+	WM_DISPLAYCHANGE     = 0x007E
+	WM_DEVICECHANGE      = 0x0219
+	DBT_DEVNODES_CHANGED = 0x0007
+
 	// Win32 colors are 0x00BBGGRR
 	sliderBgColor   = 0x00202020 // #202020 dark panel
 	sliderTextColor = 0x00DEDEDE // #DEDEDE light text
@@ -117,9 +121,11 @@ var (
 	autoToggleHWND   uintptr
 	colorTempReqs    = make(chan int, 1)
 	tempDragging     bool
-	currentColorTemp = 6500
 	lastManualTemp   = 6500
 	animateStop      chan struct{}
+
+	rescanTimer   *time.Timer
+	monitorSettle = 2 * time.Second
 )
 
 type sliderPoint struct{ X, Y int32 }
@@ -300,7 +306,7 @@ func runSlider() {
 	// Range 0–100, page size 10, initial position from monitor
 	procSendMessageW.Call(sliderTrackHWND, TBM_SETRANGE, 1, 100<<16) //nolint:errcheck
 	procSendMessageW.Call(sliderTrackHWND, TBM_SETPAGESIZE, 0, 10)   //nolint:errcheck
-	if _, cur, _, err := allMonitors[0].GetBrightness(); err == nil {
+	if cur, err := currentBrightness(); err == nil {
 		procSendMessageW.Call(sliderTrackHWND, TBM_SETPOS, 1, uintptr(cur)) //nolint:errcheck
 		updatePctLabel(cur)
 	}
@@ -364,7 +370,7 @@ func sliderWndProc(hwnd, msg, wParam, lParam uintptr) uintptr {
 		return 0
 	case WM_CTLCOLORSTATIC:
 		if lParam == autoToggleHWND {
-			if autoColorActive {
+			if autoColorOn() {
 				procSetTextColor.Call(wParam, autoOnColor) //nolint:errcheck
 			} else {
 				procSetTextColor.Call(wParam, autoOffColor) //nolint:errcheck
@@ -392,10 +398,9 @@ func sliderWndProc(hwnd, msg, wParam, lParam uintptr) uintptr {
 				tempDragging = true
 				lastManualTemp = int(pos)
 				stopAnimation()
-				if autoColorActive {
+				if autoColorOn() {
 					stopAutoColor()
-					cfg.AutoColorEnabled = false
-					saveConfig()
+					updateConfig(func(c *config) { c.AutoColorEnabled = false })
 					updateAutoToggleText()
 					log.Printf("auto color temp disabled (manual override)")
 				}
@@ -403,8 +408,7 @@ func sliderWndProc(hwnd, msg, wParam, lParam uintptr) uintptr {
 			case SB_ENDSCROLL:
 				tempDragging = false
 				lastManualTemp = int(pos)
-				cfg.ManualTemp = int(pos)
-				saveConfig()
+				updateConfig(func(c *config) { c.ManualTemp = int(pos) })
 				requestColorTemp(int(pos))
 			}
 		default:
@@ -412,12 +416,15 @@ func sliderWndProc(hwnd, msg, wParam, lParam uintptr) uintptr {
 			updatePctLabel(int(pos))
 			code := wParam & 0xFFFF
 			switch code {
-			case SB_THUMBTRACK:
-				sliderDragging = true
-				requestBrightness(int(pos))
 			case SB_ENDSCROLL:
 				sliderDragging = false
 				requestBrightness(int(pos))
+			default:
+				// Dragging, clicking the track, arrow and page keys, the wheel:
+				// any of them is a manual change.
+				sliderDragging = code == SB_THUMBTRACK
+				disengageAutoBrightness()
+				requestBrightness(int(pos))
 			}
 		}
 		return 0
@@ -438,6 +445,14 @@ func sliderWndProc(hwnd, msg, wParam, lParam uintptr) uintptr {
 			}
 		}
 		return 1
+	case WM_DISPLAYCHANGE:
+		scheduleMonitorRescan()
+		return 0
+	case WM_DEVICECHANGE:
+		if wParam == DBT_DEVNODES_CHANGED {
+			scheduleMonitorRescan()
+		}
+		return 1
 	case WM_DESTROY:
 		procPostQuitMessage.Call(0) //nolint:errcheck
 		return 0
@@ -496,26 +511,17 @@ func sliderPosition(cursorX, cursorY int32, taskbar *sliderRect, screenW, screen
 }
 
 func positionAndShow(hwnd uintptr, cursorX, cursorY int32) {
-	_, cur, _, err := allMonitors[0].GetBrightness()
+	cur, err := currentBrightness()
 	if err != nil {
 		log.Printf("slider: GetBrightness: %v", err)
 		cur = 50
 	}
-	if cur == 0 {
-		log.Printf("slider: brightness=0 suspicious, re-enumerating")
-		if refreshMonitors() {
-			if _, c, _, e := allMonitors[0].GetBrightness(); e == nil {
-				cur = c
-				log.Printf("slider: brightness retry: %d", cur)
-			}
-		}
-	}
 	procSendMessageW.Call(sliderTrackHWND, TBM_SETPOS, 1, uintptr(cur)) //nolint:errcheck
 	updatePctLabel(cur)
 
 	// Sync color temp trackbar to current value.
-	procSendMessageW.Call(tempTrackHWND, TBM_SETPOS, 1, uintptr(currentColorTemp)) //nolint:errcheck
-	updateTempLabel(currentColorTemp)
+	procSendMessageW.Call(tempTrackHWND, TBM_SETPOS, 1, uintptr(colorTemp())) //nolint:errcheck
+	updateTempLabel(colorTemp())
 	updateAutoToggleText()
 
 	// Get taskbar position to anchor the slider above it (like volume flyout).
@@ -555,14 +561,25 @@ func updatePctLabel(pct int) {
 // comes back. Windows resets the gamma ramp to linear (6500K) on monitor
 // sleep/wake, so we must always reapply — even if the target temp hasn't changed.
 func handleDisplayWake(reason string) {
-	log.Printf("wake (%s): reapplying color temp %dK", reason, currentColorTemp)
-	go applyColorTemp(currentColorTemp)
-	if autoColorActive {
-		select {
-		case autoColorWake <- struct{}{}:
-		default:
-		}
+	log.Printf("wake (%s): reapplying color temp %dK", reason, colorTemp())
+	go applyColorTemp(colorTemp())
+	wakeAutoColor()
+	wakeAutoBrightness()
+}
+
+// scheduleMonitorRescan re-enumerates monitors once display and device
+// changes settle: plugging in a monitor sends a burst of them, and DDC/CI
+// needs a moment more. A new monitor gets the current color temperature and
+// scheduled brightness. Called on the UI thread.
+func scheduleMonitorRescan() {
+	if rescanTimer != nil {
+		rescanTimer.Stop()
 	}
+	rescanTimer = time.AfterFunc(monitorSettle, func() {
+		if rescanMonitors() {
+			handleDisplayWake("monitors changed")
+		}
+	})
 }
 
 // syncSlider posts the current brightness level to the slider window so it
@@ -578,6 +595,18 @@ func syncSlider(level int) {
 	}
 }
 
+// syncColorTempSlider posts a message to update the slider UI from any goroutine.
+func syncColorTempSlider(kelvin int) {
+	select {
+	case <-sliderReady:
+	default:
+		return
+	}
+	if sliderHWND != 0 {
+		procPostMessageW.Call(sliderHWND, wmSyncColorTemp, uintptr(kelvin), 0) //nolint:errcheck
+	}
+}
+
 func showSlider() {
 	<-sliderReady
 	var pt sliderPoint
@@ -594,7 +623,8 @@ func updateTempLabel(kelvin int) {
 // requestColorTemp enqueues a color temperature update, dropping any pending
 // value so the goroutine always processes the latest position.
 func requestColorTemp(kelvin int) {
-	currentColorTemp = kelvin
+	setColorTemp(kelvin)
+	publishColorTemp(kelvin)
 	select {
 	case <-colorTempReqs:
 	default:
@@ -602,9 +632,16 @@ func requestColorTemp(kelvin int) {
 	colorTempReqs <- kelvin
 }
 
+// syncManualTemp records a manual color temp set outside the slider and
+// updates the auto toggle. Safe to call from any goroutine.
+func syncManualTemp(kelvin int) {
+	lastManualTemp = kelvin
+	syncAutoToggle()
+}
+
 func updateAutoToggleText() {
 	var label string
-	if autoColorActive {
+	if autoColorOn() {
 		label = "\u25CF Auto"
 	} else {
 		label = "Auto"
@@ -614,18 +651,16 @@ func updateAutoToggleText() {
 }
 
 func handleAutoToggleClick() {
-	if autoColorActive {
-		from := currentColorTemp
+	if autoColorOn() {
+		from := colorTemp()
 		stopAutoColor()
-		cfg.AutoColorEnabled = false
-		saveConfig()
+		updateConfig(func(c *config) { c.AutoColorEnabled = false })
 		updateAutoToggleText()
 		animateColorTemp(from, lastManualTemp)
 	} else {
 		stopAnimation()
-		from := currentColorTemp
-		cfg.AutoColorEnabled = true
-		saveConfig()
+		from := colorTemp()
+		updateConfig(func(c *config) { c.AutoColorEnabled = true })
 		updateAutoToggleText()
 		go func() {
 			startAutoColor(from)
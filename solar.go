@@ -0,0 +1,157 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+// Solar position from the NOAA solar calculator equations
+// (https://gml.noaa.gov/grad/solcalc/calcdetails.html). Accurate to about a
+// minute for sunrise/sunset between 1900 and 2100 outside the polar regions,
+// which is all auto color and auto brightness need.
+
+// Sun elevation angles, in degrees, that define the day's events.
+const (
+	elevSunrise  = -0.833 // upper limb on the horizon, with standard refraction
+	elevCivil    = -6.0
+	elevNautical = -12.0
+	elevAstro    = -18.0
+)
+
+func rad(deg float64) float64 { return deg * math.Pi / 180 }
+func deg(rad float64) float64 { return rad * 180 / math.Pi }
+
+// julianCentury returns the Julian centuries since J2000.0 at t.
+func julianCentury(t time.Time) float64 {
+	jd := float64(t.UnixNano())/float64(24*time.Hour) + 2440587.5
+	return (jd - 2451545) / 36525
+}
+
+// solarParams returns the sun's declination (degrees) and the equation of
+// time (minutes) at t.
+func solarParams(t time.Time) (decl, eqTime float64) {
+	T := julianCentury(t)
+
+	meanLong := math.Mod(280.46646+T*(36000.76983+T*0.0003032), 360)
+	meanAnom := 357.52911 + T*(35999.05029-0.0001537*T)
+	eccent := 0.016708634 - T*(0.000042037+0.0000001267*T)
+	eqCenter := math.Sin(rad(meanAnom))*(1.914602-T*(0.004817+0.000014*T)) +
+		math.Sin(rad(2*meanAnom))*(0.019993-0.000101*T) +
+		math.Sin(rad(3*meanAnom))*0.000289
+	omega := 125.04 - 1934.136*T
+	appLong := meanLong + eqCenter - 0.00569 - 0.00478*math.Sin(rad(omega))
+
+	meanObliq := 23 + (26+(21.448-T*(46.815+T*(0.00059-T*0.001813)))/60)/60
+	obliq := meanObliq + 0.00256*math.Cos(rad(omega))
+
+	decl = deg(math.Asin(math.Sin(rad(obliq)) * math.Sin(rad(appLong))))
+
+	y := math.Pow(math.Tan(rad(obliq/2)), 2)
+	l, m := rad(meanLong), rad(meanAnom)
+	eqTime = 4 * deg(y*math.Sin(2*l)-
+		2*eccent*math.Sin(m)+
+		4*eccent*y*math.Sin(m)*math.Cos(2*l)-
+		0.5*y*y*math.Sin(4*l)-
+		1.25*eccent*eccent*math.Sin(2*m))
+	return decl, eqTime
+}
+
+// trueSolarMinutes returns the true solar time at longitude lon, in minutes
+// after local solar midnight.
+func trueSolarMinutes(t time.Time, lon, eqTime float64) float64 {
+	u := t.UTC()
+	minutes := float64(u.Hour()*60+u.Minute()) + float64(u.Second())/60 + float64(u.Nanosecond())/6e10
+	return math.Mod(minutes+eqTime+4*lon+1440, 1440)
+}
+
+// solarElevation returns the geometric elevation of the sun's center above
+// the horizon at t, in degrees (no refraction correction).
+func solarElevation(t time.Time, lat, lon float64) float64 {
+	decl, eqTime := solarParams(t)
+	hourAngle := trueSolarMinutes(t, lon, eqTime)/4 - 180
+	cosZenith := math.Sin(rad(lat))*math.Sin(rad(decl)) +
+		math.Cos(rad(lat))*math.Cos(rad(decl))*math.Cos(rad(hourAngle))
+	return 90 - deg(math.Acos(math.Max(-1, math.Min(1, cosZenith))))
+}
+
+// solarNoon returns the solar noon closest to near.
+func solarNoon(near time.Time, lon float64) time.Time {
+	noon := near
+	for range 2 {
+		_, eqTime := solarParams(noon)
+		offset := 720 - trueSolarMinutes(noon, lon, eqTime)
+		noon = noon.Add(time.Duration(offset * float64(time.Minute)))
+	}
+	return noon
+}
+
+// sunCrossing returns when the sun crosses elev degrees on the day of noon,
+// rising (morning) or setting (evening). ok is false when it doesn't cross
+// that day: the sun stays above (polar day) or below (polar night) elev.
+func sunCrossing(noon time.Time, lat, lon, elev float64, rising bool) (t time.Time, ok bool) {
+	t = noon
+	// Iterate so the declination and equation of time are taken at the
+	// event rather than at noon; two rounds converge to well under a second.
+	for range 3 {
+		decl, eqTime := solarParams(t)
+		cosH := (math.Sin(rad(elev)) - math.Sin(rad(lat))*math.Sin(rad(decl))) /
+			(math.Cos(rad(lat)) * math.Cos(rad(decl)))
+		if cosH < -1 || cosH > 1 {
+			return time.Time{}, false
+		}
+		h := deg(math.Acos(cosH)) // hour angle, degrees
+		if rising {
+			h = -h
+		}
+		// Minutes from the event's true solar time to the current estimate.
+		offset := 720 + 4*h - trueSolarMinutes(t, lon, eqTime)
+		if offset > 720 {
+			offset -= 1440
+		} else if offset < -720 {
+			offset += 1440
+		}
+		t = t.Add(time.Duration(offset * float64(time.Minute)))
+	}
+	return t, true
+}
+
+// computeSunSchedule computes the sun schedule for the calendar day of date
+// in date's location. Events that don't happen that day are left zero:
+// nautical and astronomical twilight often don't end in summer at high
+// latitudes, and civil twilight doesn't either on white nights. When the sun
+// doesn't both rise and set, Cycle says whether it stays up or down.
+func computeSunSchedule(date time.Time, lat, lon float64) sunSchedule {
+	loc := date.Location()
+	localNoon := time.Date(date.Year(), date.Month(), date.Day(), 12, 0, 0, 0, loc)
+	noon := solarNoon(localNoon, lon)
+
+	event := func(elev float64, rising bool) time.Time {
+		t, crossed := sunCrossing(noon, lat, lon, elev, rising)
+		if !crossed {
+			return time.Time{}
+		}
+		return t.In(loc).Round(time.Second)
+	}
+	sched := sunSchedule{
+		Noon:            noon.In(loc).Round(time.Second),
+		Sunrise:         event(elevSunrise, true),
+		Sunset:          event(elevSunrise, false),
+		CivilTwBegin:    event(elevCivil, true),
+		CivilTwEnd:      event(elevCivil, false),
+		NauticalTwBegin: event(elevNautical, true),
+		NauticalTwEnd:   event(elevNautical, false),
+		AstroTwBegin:    event(elevAstro, true),
+		AstroTwEnd:      event(elevAstro, false),
+	}
+	// On the first and last days of polar day the sun may dip below the
+	// horizon for a few minutes, giving a sunset but no sunrise (or the
+	// reverse); those days count as polar day too.
+	if sched.Sunrise.IsZero() || sched.Sunset.IsZero() {
+		if solarElevation(noon, lat, lon) > elevSunrise {
+			sched.Cycle = sunNeverSets
+		} else {
+			sched.Cycle = sunNeverRises
+		}
+	}
+	return sched
+}
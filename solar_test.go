@@ -0,0 +1,153 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// TestComputeSunSchedule checks computed sunrise/sunset against published
+// almanac times (rounded to the minute), allowing a minute either way.
+func TestComputeSunSchedule(t *testing.T) {
+	tests := []struct {
+		name     string
+		tz       string
+		lat, lon float64
+		date     string
+		sunrise  string
+		sunset   string
+	}{
+		{"London solstice", "Europe/London", 51.5074, -0.1278, "2024-06-20", "04:43", "21:21"},
+		{"New York solstice", "America/New_York", 40.7128, -74.0060, "2024-06-20", "05:25", "20:31"},
+		{"Sydney winter", "Australia/Sydney", -33.8688, 151.2093, "2024-06-21", "07:00", "16:54"},
+		{"Oslo winter", "Europe/Oslo", 59.9139, 10.7522, "2024-12-21", "09:18", "15:12"},
+		{"Auckland new year", "Pacific/Auckland", -36.8485, 174.7633, "2024-01-01", "06:05", "20:43"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loc, err := time.LoadLocation(tt.tz)
+			if err != nil {
+				t.Skipf("no tzdata: %v", err)
+			}
+			date, _ := time.ParseInLocation("2006-01-02", tt.date, loc)
+			sched := computeSunSchedule(date, tt.lat, tt.lon)
+			if sched.Cycle != sunRisesAndSets {
+				t.Fatalf("cycle = %d, want sunRisesAndSets", sched.Cycle)
+			}
+			check := func(name string, got time.Time, want string) {
+				w, _ := time.ParseInLocation("2006-01-02 15:04", tt.date+" "+want, loc)
+				if d := got.Sub(w).Abs(); d > time.Minute+30*time.Second {
+					t.Errorf("%s = %s, want %s (off by %s)", name, got.Format("15:04:05"), want, d)
+				}
+			}
+			check("sunrise", sched.Sunrise, tt.sunrise)
+			check("sunset", sched.Sunset, tt.sunset)
+		})
+	}
+}
+
+// TestSunScheduleElevations checks that each computed event is where the sun
+// is at that event's elevation, and that the events are in order.
+func TestSunScheduleElevations(t *testing.T) {
+	const lat, lon = 48.8566, 2.3522 // Paris
+	loc, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Skipf("no tzdata: %v", err)
+	}
+	for _, date := range []string{"2024-01-15", "2024-03-20", "2024-09-22", "2024-12-21"} {
+		d, _ := time.ParseInLocation("2006-01-02", date, loc)
+		s := computeSunSchedule(d, lat, lon)
+		events := []struct {
+			name string
+			t    time.Time
+			elev float64
+		}{
+			{"astro begin", s.AstroTwBegin, elevAstro},
+			{"nautical begin", s.NauticalTwBegin, elevNautical},
+			{"civil begin", s.CivilTwBegin, elevCivil},
+			{"sunrise", s.Sunrise, elevSunrise},
+			{"sunset", s.Sunset, elevSunrise},
+			{"civil end", s.CivilTwEnd, elevCivil},
+			{"nautical end", s.NauticalTwEnd, elevNautical},
+			{"astro end", s.AstroTwEnd, elevAstro},
+		}
+		for i, ev := range events {
+			if got := solarElevation(ev.t, lat, lon); math.Abs(got-ev.elev) > 0.02 {
+				t.Errorf("%s %s: elevation %.3f°, want %.3f°", date, ev.name, got, ev.elev)
+			}
+			if i > 0 && !ev.t.After(events[i-1].t) {
+				t.Errorf("%s: %s (%s) not after %s (%s)", date, ev.name, ev.t.Format("15:04"),
+					events[i-1].name, events[i-1].t.Format("15:04"))
+			}
+			if y, m, dd := ev.t.Date(); y != d.Year() || m != d.Month() || dd != d.Day() {
+				t.Errorf("%s: %s on %s", date, ev.name, ev.t.Format("2006-01-02"))
+			}
+		}
+	}
+}
+
+func TestComputeSunSchedulePolar(t *testing.T) {
+	const (
+		tromsøLat, tromsøLon     = 69.6492, 18.9553
+		svalbardLat, svalbardLon = 78.2232, 15.6267 // Longyearbyen
+	)
+	oslo, err := time.LoadLocation("Europe/Oslo")
+	if err != nil {
+		t.Skipf("no tzdata: %v", err)
+	}
+	tests := []struct {
+		name          string
+		lat, lon      float64
+		date          string
+		cycle         sunCycle
+		civil, astro  bool // whether civil/astronomical dusk happen
+		sunrise, dusk string
+	}{
+		{"Oslo summer", 59.9139, 10.7522, "2024-06-21", sunRisesAndSets, true, false, "03:54", ""},
+		{"Tromsø winter day", tromsøLat, tromsøLon, "2024-01-18", sunRisesAndSets, true, true, "10:57", "14:59"},
+		{"Tromsø white night", tromsøLat, tromsøLon, "2024-07-30", sunRisesAndSets, false, false, "02:08", ""},
+		{"Tromsø midnight sun", tromsøLat, tromsøLon, "2024-06-21", sunNeverSets, false, false, "", ""},
+		{"Tromsø last midnight sun day", tromsøLat, tromsøLon, "2024-07-25", sunNeverSets, false, false, "", ""},
+		{"Tromsø polar night", tromsøLat, tromsøLon, "2024-12-21", sunNeverRises, true, true, "", "13:53"},
+		{"Svalbard midnight sun", svalbardLat, svalbardLon, "2024-06-21", sunNeverSets, false, false, "", ""},
+		{"Svalbard polar night", svalbardLat, svalbardLon, "2024-12-21", sunNeverRises, false, true, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, _ := time.ParseInLocation("2006-01-02", tt.date, oslo)
+			s := computeSunSchedule(d, tt.lat, tt.lon)
+			if s.Cycle != tt.cycle {
+				t.Errorf("cycle = %d, want %d (%s)", s.Cycle, tt.cycle, s)
+			}
+			if got := !s.CivilTwEnd.IsZero(); got != tt.civil {
+				t.Errorf("civil dusk = %s, want present=%v", formatSunTime(s.CivilTwEnd), tt.civil)
+			}
+			if got := !s.AstroTwEnd.IsZero(); got != tt.astro {
+				t.Errorf("astronomical dusk = %s, want present=%v", formatSunTime(s.AstroTwEnd), tt.astro)
+			}
+			near := func(name string, got time.Time, want string) {
+				if want == "" {
+					return
+				}
+				w, _ := time.ParseInLocation("2006-01-02 15:04", tt.date+" "+want, d.Location())
+				if got.Sub(w).Abs() > 2*time.Minute {
+					t.Errorf("%s = %s, want %s", name, formatSunTime(got), want)
+				}
+			}
+			near("sunrise", s.Sunrise, tt.sunrise)
+			near("civil dusk", s.CivilTwEnd, tt.dusk)
+		})
+	}
+}
+
+func TestSolarElevationNoon(t *testing.T) {
+	// At the March equinox the noon sun is about 90° - |lat| high.
+	d := time.Date(2024, 3, 20, 12, 0, 0, 0, time.UTC)
+	for _, lat := range []float64{0, 30, 51.5, -33.9} {
+		noon := solarNoon(d, 0)
+		want := 90 - math.Abs(lat)
+		if got := solarElevation(noon, lat, 0); math.Abs(got-want) > 0.5 {
+			t.Errorf("lat %.1f: noon elevation %.2f°, want ≈%.1f°", lat, got, want)
+		}
+	}
+}
@@ -1,147 +1,301 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 )
 
-const githubReleaseURL = "https://api.github.com/repos/alex-vit/monibright/releases/latest"
+// Release source and install location, replaced in tests.
+var (
+	releaseURL = "https://api.github.com/repos/alex-vit/monibright/releases?per_page=50"
+	executable = os.Executable
+)
+
+// Release asset names: the portable exe, its minisign signature and the
+// SHA-256 manifest in sha256sum format.
+const (
+	exeAsset  = "monibright.exe"
+	sigAsset  = "monibright.exe.minisig"
+	sumsAsset = "checksums.txt"
+)
 
 type ghRelease struct {
-	TagName string    `json:"tag_name"`
-	Assets  []ghAsset `json:"assets"`
+	TagName    string    `json:"tag_name"`
+	Draft      bool      `json:"draft"`
+	Prerelease bool      `json:"prerelease"`
+	Assets     []ghAsset `json:"assets"`
 }
 
 type ghAsset struct {
 	Name               string `json:"name"`
 	BrowserDownloadURL string `json:"browser_download_url"`
+	Size               int64  `json:"size"`
+}
+
+// release is a newer release found by checkForUpdate.
+type release struct {
+	Version string
+	ExeURL  string
+	ExeSize int64
+	SigURL  string // empty if the release has no signature
+	SumsURL string // empty if the release has no checksums.txt
 }
 
 func autoUpdate() {
-	latestVer, url, err := checkForUpdate()
+	rel, err := checkForUpdate()
 	if err != nil {
 		log.Printf("update check failed: %v", err)
 		return
 	}
-	if url == "" {
+	if rel == nil {
 		log.Printf("no update available (current=%s)", displayVersion())
 		return
 	}
-	log.Printf("update available: v%s", latestVer)
-	tmpPath, err := downloadUpdate(url)
+	log.Printf("update available: v%s", rel.Version)
+	publishUpdate(rel.Version, "available")
+	tmpPath, err := downloadUpdate(rel)
 	if err != nil {
 		log.Printf("update download failed: %v", err)
 		return
 	}
-	if err := applyUpdate(tmpPath); err != nil {
+	if err := applyUpdate(tmpPath, rel.Version); err != nil {
 		log.Printf("update apply failed: %v", err)
+		return
 	}
+	publishUpdate(rel.Version, "ready")
 }
 
-// cleanOldBinary removes a leftover .old file from a previous update.
+// cleanOldBinary removes the binaries kept by a previous update or
+// rollback. The .old one stays while the update's boot check is pending, as
+// rollback needs it.
 func cleanOldBinary() {
-	exe, err := os.Executable()
+	exe, err := executable()
 	if err != nil {
 		return
 	}
-	old := exe + ".old"
-	if err := os.Remove(old); err == nil {
-		log.Printf("removed old binary: %s", old)
+	if _, err := os.Stat(bootMarker(exe)); err == nil {
+		return
+	}
+	for _, path := range []string{exe + ".old", exe + ".failed"} {
+		if err := os.Remove(path); err == nil {
+			log.Printf("removed old binary: %s", path)
+		}
 	}
 }
 
-// checkForUpdate queries the GitHub releases API and returns the latest
-// version and asset download URL if it's newer than the current version.
-func checkForUpdate() (latestVer, downloadURL string, err error) {
-	req, err := http.NewRequest(http.MethodGet, githubReleaseURL, nil) //nolint:noctx
+// checkForUpdate lists releases on GitHub and returns the one to install
+// (see selectRelease), or nil if there is none.
+func checkForUpdate() (*release, error) {
+	req, err := http.NewRequest(http.MethodGet, releaseURL, nil) //nolint:noctx
 	if err != nil {
-		return "", "", err
+		return nil, err
 	}
 	req.Header.Set("Accept", "application/vnd.github+json")
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return "", "", err
+		return nil, err
 	}
 	defer func() { _ = resp.Body.Close() }()
 
 	if resp.StatusCode != http.StatusOK {
-		return "", "", fmt.Errorf("GitHub API returned %d", resp.StatusCode)
+		return nil, fmt.Errorf("GitHub API returned %d", resp.StatusCode)
 	}
 
-	var rel ghRelease
-	if err := json.NewDecoder(resp.Body).Decode(&rel); err != nil {
-		return "", "", err
+	var releases []ghRelease
+	if err := json.NewDecoder(resp.Body).Decode(&releases); err != nil {
+		return nil, err
 	}
 
-	latestVer = strings.TrimPrefix(rel.TagName, "v")
-	if !isNewer(latestVer, version) {
-		return "", "", nil // up to date
+	gh := selectRelease(releases, version)
+	if gh == nil {
+		return nil, nil // up to date
 	}
 
-	for _, a := range rel.Assets {
-		if strings.EqualFold(a.Name, "monibright.exe") {
-			return latestVer, a.BrowserDownloadURL, nil
+	rel := &release{Version: strings.TrimPrefix(gh.TagName, "v")}
+	for _, a := range gh.Assets {
+		switch {
+		case strings.EqualFold(a.Name, exeAsset):
+			rel.ExeURL, rel.ExeSize = a.BrowserDownloadURL, a.Size
+		case strings.EqualFold(a.Name, sigAsset):
+			rel.SigURL = a.BrowserDownloadURL
+		case strings.EqualFold(a.Name, sumsAsset):
+			rel.SumsURL = a.BrowserDownloadURL
 		}
 	}
-	return "", "", fmt.Errorf("no monibright.exe asset in release %s", rel.TagName)
+	if rel.ExeURL == "" {
+		return nil, fmt.Errorf("no %s asset in release %s", exeAsset, gh.TagName)
+	}
+	return rel, nil
 }
 
-// downloadUpdate downloads the new binary to a .tmp file next to the running exe.
-func downloadUpdate(url string) (tmpPath string, err error) {
-	exe, err := os.Executable()
+// downloadUpdate downloads the new binary to a .tmp file next to the running
+// exe, and its signature to .tmp.minisig. The binary is hashed as it
+// streams in and deleted unless its size and SHA-256 match the release's
+// checksums.txt.
+func downloadUpdate(rel *release) (tmpPath string, err error) {
+	if rel.SigURL == "" {
+		return "", fmt.Errorf("v%s: %w (no %s asset)", rel.Version, errUnsigned, sigAsset)
+	}
+	if rel.SumsURL == "" {
+		return "", fmt.Errorf("v%s: no %s asset", rel.Version, sumsAsset)
+	}
+	exe, err := executable()
 	if err != nil {
 		return "", err
 	}
 
-	req, err := http.NewRequest(http.MethodGet, url, nil) //nolint:noctx
+	want, err := fetchChecksum(rel.SumsURL, exeAsset)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", sumsAsset, err)
+	}
+
+	tmpPath = exe + ".tmp"
+	n, sum, err := download(rel.ExeURL, tmpPath)
 	if err != nil {
 		return "", err
 	}
-	resp, err := http.DefaultClient.Do(req)
+	switch {
+	case rel.ExeSize > 0 && n != rel.ExeSize:
+		err = fmt.Errorf("downloaded %d bytes, release lists %d", n, rel.ExeSize)
+	case !bytes.Equal(sum, want):
+		err = fmt.Errorf("SHA-256 %x does not match %s (%x)", sum, sumsAsset, want)
+	}
 	if err != nil {
+		_ = os.Remove(tmpPath)
 		return "", err
 	}
-	defer func() { _ = resp.Body.Close() }()
+	if _, _, err := download(rel.SigURL, tmpPath+".minisig"); err != nil {
+		_ = os.Remove(tmpPath)
+		return "", fmt.Errorf("signature: %w", err)
+	}
 
+	log.Printf("downloaded update to %s (%d bytes, sha256 %x)", tmpPath, n, sum)
+	return tmpPath, nil
+}
+
+// get fetches url, failing on anything but 200 OK.
+func get(url string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil) //nolint:noctx
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("download returned %d", resp.StatusCode)
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("download returned %d", resp.StatusCode)
 	}
+	return resp, nil
+}
 
-	tmpPath = exe + ".tmp"
-	f, err := os.Create(tmpPath)
+// download saves url to path and returns its size and SHA-256, removing the
+// file again on failure.
+func download(url, path string) (n int64, sum []byte, err error) {
+	resp, err := get(url)
 	if err != nil {
-		return "", err
+		return 0, nil, err
 	}
+	defer func() { _ = resp.Body.Close() }()
 
-	if _, err := io.Copy(f, resp.Body); err != nil {
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	h := sha256.New()
+	if n, err = io.Copy(io.MultiWriter(f, h), resp.Body); err != nil {
 		_ = f.Close()
-		_ = os.Remove(tmpPath)
-		return "", err
+		_ = os.Remove(path)
+		return 0, nil, err
 	}
 	if err := f.Close(); err != nil {
-		_ = os.Remove(tmpPath)
-		return "", err
+		_ = os.Remove(path)
+		return 0, nil, err
 	}
+	return n, h.Sum(nil), nil
+}
 
-	log.Printf("downloaded update to %s", tmpPath)
-	return tmpPath, nil
+// fetchChecksum returns the SHA-256 listed for name in the sha256sum-style
+// manifest at url ("<hex>  <name>" per line, "*<name>" in binary mode).
+func fetchChecksum(url, name string) ([]byte, error) {
+	resp, err := get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	sc := bufio.NewScanner(io.LimitReader(resp.Body, 1<<20))
+	for sc.Scan() {
+		digest, file, ok := strings.Cut(strings.TrimSpace(sc.Text()), " ")
+		file = strings.TrimPrefix(strings.TrimSpace(file), "*")
+		if !ok || !strings.EqualFold(file, name) {
+			continue
+		}
+		sum, err := hex.DecodeString(digest)
+		if err != nil || len(sum) != sha256.Size {
+			return nil, fmt.Errorf("malformed checksum for %s", name)
+		}
+		return sum, nil
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("no checksum for %s", name)
+}
+
+// verifyUpdate checks the downloaded update against its signature and the
+// public key built into this binary.
+func verifyUpdate(tmpPath string) error {
+	if updatePublicKey == "" {
+		return errors.New("this build has no update signing key")
+	}
+	key, err := parseMinisignKey(updatePublicKey)
+	if err != nil {
+		return err
+	}
+	sig, err := os.ReadFile(tmpPath + ".minisig")
+	if errors.Is(err, os.ErrNotExist) {
+		return errUnsigned
+	} else if err != nil {
+		return err
+	}
+	return verifyMinisign(key, tmpPath, sig)
 }
 
-// applyUpdate replaces the running exe with the downloaded update.
-// The new version takes effect on next launch (reboot, autostart, or manual).
-func applyUpdate(tmpPath string) error {
-	exe, err := os.Executable()
+// applyUpdate replaces the running exe with the downloaded update once its
+// signature checks out; otherwise the download is deleted. The new version
+// takes effect on next launch (reboot, autostart, or manual), where
+// checkBoot watches that it starts.
+func applyUpdate(tmpPath, newVersion string) error {
+	exe, err := executable()
 	if err != nil {
 		return err
 	}
 
+	err = verifyUpdate(tmpPath)
+	_ = os.Remove(tmpPath + ".minisig")
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("refusing update: %w", err)
+	}
+	log.Printf("update signature verified")
+
 	old := exe + ".old"
 
 	// Windows allows renaming a running exe but not overwriting it.
@@ -152,45 +306,192 @@ func applyUpdate(tmpPath string) error {
 		_ = os.Rename(old, exe)
 		return fmt.Errorf("rename .tmp to exe: %w", err)
 	}
+	if err := writeBootMarker(exe, newVersion); err != nil {
+		log.Printf("update: no boot check for v%s: %v", newVersion, err)
+	}
 
 	log.Printf("applied update, new version ready on next launch")
 	return nil
 }
 
-// isNewer reports whether latest is a higher semver than current.
-// Versions are expected as "X.Y.Z" (no "v" prefix).
-func isNewer(latest, current string) bool {
+// selectRelease picks the release to update to from the channel in config:
+// the newest stable release, or on the beta channel the newest release
+// including pre-releases. Versions in UpdateSkip are passed over. With
+// UpdatePin set only that version is installed, even if it's older than the
+// running one. It returns nil when there is nothing to do.
+func selectRelease(releases []ghRelease, current string) *ghRelease {
 	if current == "" || current == "dev" {
-		return false // dev builds don't auto-update
+		return nil // dev builds don't auto-update
 	}
-	lp := parseSemver(latest)
-	cp := parseSemver(current)
-	if lp == nil || cp == nil {
-		return false
+	cur, ok := parseSemver(current)
+	if !ok {
+		return nil
+	}
+	c := cfgSnapshot()
+	beta := false
+	switch c.UpdateChannel {
+	case "", "stable":
+	case "beta":
+		beta = true
+	default:
+		log.Printf("unknown update_channel %q, using stable", c.UpdateChannel)
 	}
-	for i := range 3 {
-		if lp[i] > cp[i] {
-			return true
+	var pin *semver
+	if c.UpdatePin != "" {
+		v, ok := parseSemver(c.UpdatePin)
+		if !ok {
+			log.Printf("update_pin %q is not a version, ignoring it", c.UpdatePin)
+		} else {
+			pin = &v
 		}
-		if lp[i] < cp[i] {
-			return false
+	}
+	skipped := func(v semver) bool {
+		return slices.ContainsFunc(c.UpdateSkip, func(s string) bool {
+			sv, ok := parseSemver(s)
+			return ok && sv.compare(v) == 0
+		})
+	}
+
+	var best *ghRelease
+	var bestVer semver
+	for i, r := range releases {
+		v, ok := parseSemver(r.TagName)
+		if !ok || r.Draft || skipped(v) {
+			continue
+		}
+		if pin != nil {
+			if v.compare(*pin) == 0 && v.compare(cur) != 0 {
+				return &releases[i]
+			}
+			continue
+		}
+		if !beta && (r.Prerelease || len(v.pre) > 0) {
+			continue
+		}
+		if v.compare(cur) > 0 && (best == nil || v.compare(bestVer) > 0) {
+			best, bestVer = &releases[i], v
 		}
 	}
-	return false
+	return best
 }
 
-func parseSemver(s string) []int {
-	parts := strings.SplitN(s, ".", 3)
+// semver is a semantic version (semver.org 2.0). Build metadata is
+// validated but dropped, as it doesn't affect precedence.
+type semver struct {
+	major, minor, patch uint64
+	pre                 []string // pre-release identifiers
+}
+
+// parseSemver parses a version like 1.5.0-rc.1+build.7, with or without a
+// leading "v" as in tag names.
+func parseSemver(s string) (semver, bool) {
+	s = strings.TrimPrefix(s, "v")
+	s, build, hasBuild := strings.Cut(s, "+")
+	if hasBuild && !validIdents(build, false) {
+		return semver{}, false
+	}
+	s, pre, hasPre := strings.Cut(s, "-")
+	if hasPre && !validIdents(pre, true) {
+		return semver{}, false
+	}
+
+	parts := strings.Split(s, ".")
 	if len(parts) != 3 {
-		return nil
+		return semver{}, false
 	}
-	nums := make([]int, 3)
+	var nums [3]uint64
 	for i, p := range parts {
-		n, err := strconv.Atoi(p)
+		if !isNumeric(p) || (len(p) > 1 && p[0] == '0') {
+			return semver{}, false
+		}
+		n, err := strconv.ParseUint(p, 10, 64)
 		if err != nil {
-			return nil
+			return semver{}, false
 		}
 		nums[i] = n
 	}
-	return nums
+	v := semver{major: nums[0], minor: nums[1], patch: nums[2]}
+	if hasPre {
+		v.pre = strings.Split(pre, ".")
+	}
+	return v, true
+}
+
+// validIdents checks dot-separated identifiers: non-empty, [0-9A-Za-z-],
+// and for pre-releases no leading zeros in numeric ones.
+func validIdents(s string, pre bool) bool {
+	for id := range strings.SplitSeq(s, ".") {
+		if id == "" {
+			return false
+		}
+		for _, c := range id {
+			if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '-') {
+				return false
+			}
+		}
+		if pre && isNumeric(id) && len(id) > 1 && id[0] == '0' {
+			return false
+		}
+	}
+	return true
+}
+
+func isNumeric(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// compare returns -1, 0 or +1 as v has lower, equal or higher precedence
+// than w.
+func (v semver) compare(w semver) int {
+	if c := cmp.Compare(v.major, w.major); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(v.minor, w.minor); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(v.patch, w.patch); c != 0 {
+		return c
+	}
+	// A pre-release sorts before its release.
+	switch {
+	case len(v.pre) == 0 && len(w.pre) == 0:
+		return 0
+	case len(v.pre) == 0:
+		return 1
+	case len(w.pre) == 0:
+		return -1
+	}
+	for i := range min(len(v.pre), len(w.pre)) {
+		if c := compareIdent(v.pre[i], w.pre[i]); c != 0 {
+			return c
+		}
+	}
+	return cmp.Compare(len(v.pre), len(w.pre))
+}
+
+// compareIdent orders pre-release identifiers: numeric ones numerically and
+// below alphanumeric ones, which compare in ASCII order.
+func compareIdent(a, b string) int {
+	an, bn := isNumeric(a), isNumeric(b)
+	switch {
+	case an && bn:
+		// No leading zeros, so the longer number is the larger.
+		if c := cmp.Compare(len(a), len(b)); c != 0 {
+			return c
+		}
+		return strings.Compare(a, b)
+	case an:
+		return -1
+	case bn:
+		return 1
+	}
+	return strings.Compare(a, b)
 }
@@ -1,33 +1,112 @@
 package main
 
 import (
+	"cmp"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+
+	"golang.org/x/crypto/blake2b"
 )
 
-func TestIsNewer(t *testing.T) {
+func TestSemverPrecedence(t *testing.T) {
+	// In ascending order, from semver.org.
+	ordered := []string{
+		"1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-alpha.beta", "1.0.0-beta",
+		"1.0.0-beta.2", "1.0.0-beta.11", "1.0.0-rc.1", "1.0.0", "1.0.1",
+		"1.1.0-0", "1.1.0-9", "1.1.0-10", "1.1.0-a", "1.1.0", "2.0.0", "10.0.0",
+	}
+	for i, a := range ordered {
+		av, ok := parseSemver(a)
+		if !ok {
+			t.Fatalf("parseSemver(%q) failed", a)
+		}
+		for j, b := range ordered {
+			bv, _ := parseSemver(b)
+			if got, want := av.compare(bv), cmp.Compare(i, j); got != want {
+				t.Errorf("compare(%s, %s) = %d, want %d", a, b, got, want)
+			}
+		}
+	}
+
+	a, _ := parseSemver("1.0.0-rc.1+build.1")
+	b, _ := parseSemver("v1.0.0-rc.1+exp.sha.5114f85")
+	if a.compare(b) != 0 {
+		t.Error("build metadata affects precedence")
+	}
+
+	for _, bad := range []string{
+		"", "1", "1.0", "1.0.0.0", "01.0.0", "1.00.0", "-1.0.0", "1.0.0-",
+		"1.0.0-01", "1.0.0-a..b", "1.0.0-a_b", "1.0.0+", "1.0.0+a..b", "1.0.0 ",
+	} {
+		if _, ok := parseSemver(bad); ok {
+			t.Errorf("parseSemver(%q) accepted", bad)
+		}
+	}
+	for _, good := range []string{"1.0.0-0a", "1.0.0-x-y-z.--", "1.0.0+001", "1.0.0-alpha+001"} {
+		if _, ok := parseSemver(good); !ok {
+			t.Errorf("parseSemver(%q) rejected", good)
+		}
+	}
+}
+
+func TestSelectRelease(t *testing.T) {
+	releases := []ghRelease{
+		{TagName: "v1.6.0-rc.2", Prerelease: true},
+		{TagName: "v1.7.0", Draft: true},
+		{TagName: "v1.5.1"},
+		{TagName: "v1.6.0-rc.1", Prerelease: true},
+		{TagName: "nightly", Prerelease: true},
+		{TagName: "v1.5.0"},
+		{TagName: "v1.4.0"},
+	}
 	tests := []struct {
-		latest, current string
-		want            bool
+		name    string
+		current string
+		channel string
+		pin     string
+		skip    []string
+		want    string // tag, "" for none
 	}{
-		{"1.2.0", "1.1.0", true},
-		{"1.1.1", "1.1.0", true},
-		{"2.0.0", "1.9.9", true},
-		{"1.10.0", "1.9.0", true},
-		{"1.1.0", "1.1.0", false},
-		{"1.0.0", "1.1.0", false},
-		{"1.1.0", "2.0.0", false},
-		{"1.1.0", "", false},
-		{"1.1.0", "dev", false},
-		{"bad", "1.0.0", false},
-		{"1.0.0", "bad", false},
-		{"1.0", "1.0.0", false},
+		{"stable", "v1.4.0", "", "", nil, "v1.5.1"},
+		{"stable explicit", "1.4.0", "stable", "", nil, "v1.5.1"},
+		{"up to date", "v1.5.1", "stable", "", nil, ""},
+		{"beta", "v1.4.0", "beta", "", nil, "v1.6.0-rc.2"},
+		{"beta on pre-release", "v1.6.0-rc.1", "beta", "", nil, "v1.6.0-rc.2"},
+		{"stable on pre-release", "v1.6.0-rc.1", "stable", "", nil, ""},
+		{"unknown channel", "v1.4.0", "nightly", "", nil, "v1.5.1"},
+		{"skip", "v1.4.0", "stable", "", []string{"1.5.1"}, "v1.5.0"},
+		{"skip beta", "v1.4.0", "beta", "", []string{"v1.6.0-rc.2", "1.5.1"}, "v1.6.0-rc.1"},
+		{"pin", "v1.4.0", "stable", "1.5.0", nil, "v1.5.0"},
+		{"pin older", "v1.5.1", "stable", "v1.4.0", nil, "v1.4.0"},
+		{"pin pre-release", "v1.4.0", "", "1.6.0-rc.1", nil, "v1.6.0-rc.1"},
+		{"pinned already", "v1.5.0", "beta", "1.5.0", nil, ""},
+		{"pin missing", "v1.4.0", "stable", "1.9.0", nil, ""},
+		{"pin skipped", "v1.4.0", "stable", "1.5.0", []string{"1.5.0"}, ""},
+		{"dev build", "dev", "beta", "", nil, ""},
+		{"git describe build", "v1.5.1-3-gabc1234", "stable", "", nil, "v1.5.1"},
 	}
+	prevCfg := cfg
+	t.Cleanup(func() { cfg = prevCfg })
 	for _, tt := range tests {
-		got := isNewer(tt.latest, tt.current)
+		cfg.UpdateChannel, cfg.UpdatePin, cfg.UpdateSkip = tt.channel, tt.pin, tt.skip
+		got := ""
+		if r := selectRelease(releases, tt.current); r != nil {
+			got = r.TagName
+		}
 		if got != tt.want {
-			t.Errorf("isNewer(%q, %q) = %v, want %v", tt.latest, tt.current, got, tt.want)
+			t.Errorf("%s: selectRelease from %s = %q, want %q", tt.name, tt.current, got, tt.want)
 		}
 	}
 }
@@ -89,3 +168,230 @@ func TestApplyUpdateRollback(t *testing.T) {
 		t.Errorf("after rollback, exe content = %q, want %q", got, "old")
 	}
 }
+
+// testSigner signs like "minisign -S": a prehashed signature and a global
+// signature over it and the trusted comment.
+type testSigner struct {
+	id   [8]byte
+	priv ed25519.PrivateKey
+}
+
+func newTestSigner(t *testing.T) *testSigner {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &testSigner{priv: priv}
+	_, _ = rand.Read(s.id[:])
+	return s
+}
+
+func (s *testSigner) publicKey() string {
+	b := slices.Concat([]byte("Ed"), s.id[:], s.priv.Public().(ed25519.PublicKey))
+	return "untrusted comment: minisign public key\n" + base64.StdEncoding.EncodeToString(b)
+}
+
+func (s *testSigner) sign(data []byte, trusted string) []byte {
+	h := blake2b.Sum512(data)
+	sig := slices.Concat([]byte("ED"), s.id[:], ed25519.Sign(s.priv, h[:]))
+	global := ed25519.Sign(s.priv, slices.Concat(sig[10:], []byte(trusted)))
+	return []byte("untrusted comment: signature from minisign secret key\n" +
+		base64.StdEncoding.EncodeToString(sig) + "\n" +
+		"trusted comment: " + trusted + "\n" +
+		base64.StdEncoding.EncodeToString(global) + "\n")
+}
+
+// checksums returns a sha256sum manifest for files.
+func checksums(files map[string][]byte) []byte {
+	var b strings.Builder
+	for name, data := range files {
+		fmt.Fprintf(&b, "%x  %s\n", sha256.Sum256(data), name)
+	}
+	return []byte(b.String())
+}
+
+// useReleaseServer serves a v2.0.0 release with the given assets and points
+// the updater at it, with the running exe in a temp dir. The release listing
+// can be changed through the returned pointer.
+func useReleaseServer(t *testing.T, pubKey string, assets map[string][]byte) (exe string, rel *ghRelease) {
+	t.Helper()
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	rel = &ghRelease{TagName: "v2.0.0"}
+	for name, data := range assets {
+		rel.Assets = append(rel.Assets, ghAsset{Name: name, BrowserDownloadURL: srv.URL + "/download/" + name, Size: int64(len(data))})
+		mux.HandleFunc("GET /download/"+name, func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write(data)
+		})
+	}
+	mux.HandleFunc("GET /releases", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode([]ghRelease{{TagName: "v2.1.0-beta.1", Prerelease: true}, *rel, {TagName: "v1.0.0"}})
+	})
+
+	exe = filepath.Join(t.TempDir(), "monibright.exe")
+	if err := os.WriteFile(exe, []byte("old"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	prevURL, prevExe, prevVersion, prevKey := releaseURL, executable, version, updatePublicKey
+	t.Cleanup(func() {
+		releaseURL, executable, version, updatePublicKey = prevURL, prevExe, prevVersion, prevKey
+	})
+	releaseURL = srv.URL + "/releases"
+	executable = func() (string, error) { return exe, nil }
+	version = "1.0.0"
+	updatePublicKey = pubKey
+	return exe, rel
+}
+
+// update runs one check, download and apply, as autoUpdate does.
+func update() error {
+	rel, err := checkForUpdate()
+	if err != nil {
+		return err
+	}
+	if rel == nil {
+		return errors.New("no update found")
+	}
+	tmpPath, err := downloadUpdate(rel)
+	if err != nil {
+		return err
+	}
+	return applyUpdate(tmpPath, rel.Version)
+}
+
+func TestSignedUpdate(t *testing.T) {
+	s := newTestSigner(t)
+	exe, _ := useReleaseServer(t, s.publicKey(), map[string][]byte{
+		exeAsset:  []byte("new"),
+		sigAsset:  s.sign([]byte("new"), "timestamp:1760000000\tfile:monibright.exe"),
+		sumsAsset: []byte(fmt.Sprintf("%x *monibright-setup.exe\n%x *monibright.exe\n", sha256.Sum256([]byte("setup")), sha256.Sum256([]byte("new")))),
+	})
+
+	if err := update(); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if got, _ := os.ReadFile(exe); string(got) != "new" {
+		t.Errorf("exe content = %q, want %q", got, "new")
+	}
+	if got, _ := os.ReadFile(exe + ".old"); string(got) != "old" {
+		t.Errorf("old content = %q, want %q", got, "old")
+	}
+	if _, err := os.Stat(exe + ".tmp.minisig"); !os.IsNotExist(err) {
+		t.Errorf("signature file left behind")
+	}
+	if st := readBootState(t, exe); st.Version != "2.0.0" || st.Previous != "1.0.0" || st.Attempts != 0 {
+		t.Errorf("boot marker = %+v, want a fresh check for 2.0.0", st)
+	}
+}
+
+func TestUnverifiedUpdateRefused(t *testing.T) {
+	s := newTestSigner(t)
+	other := newTestSigner(t)
+	good := s.sign([]byte("new"), "file:monibright.exe")
+	tampered := strings.Replace(string(good), "file:monibright.exe", "file:monibright-1.0.exe", 1)
+
+	tests := []struct {
+		name    string
+		pubKey  string
+		sig     []byte // nil for no signature asset
+		wantErr string
+	}{
+		{"unsigned", s.publicKey(), nil, "not signed"},
+		{"other content", s.publicKey(), s.sign([]byte("evil"), "file:monibright.exe"), "signature does not match"},
+		{"other key", s.publicKey(), other.sign([]byte("new"), "file:monibright.exe"), "signed with key"},
+		{"tampered comment", s.publicKey(), []byte(tampered), "trusted comment signature"},
+		{"garbage", s.publicKey(), []byte("not a signature"), "malformed"},
+		{"no embedded key", "", good, "no update signing key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assets := map[string][]byte{exeAsset: []byte("new")}
+			assets[sumsAsset] = checksums(assets)
+			if tt.sig != nil {
+				assets[sigAsset] = tt.sig
+			}
+			exe, _ := useReleaseServer(t, tt.pubKey, assets)
+
+			err := update()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("update error = %v, want %q", err, tt.wantErr)
+			}
+			if got, _ := os.ReadFile(exe); string(got) != "old" {
+				t.Errorf("exe content = %q, want it untouched", got)
+			}
+			entries, _ := os.ReadDir(filepath.Dir(exe))
+			if len(entries) != 1 {
+				t.Errorf("files left behind: %v", entries)
+			}
+		})
+	}
+}
+
+func TestCorruptDownloadRefused(t *testing.T) {
+	s := newTestSigner(t)
+	sig := s.sign([]byte("new"), "file:monibright.exe")
+	sums := checksums(map[string][]byte{exeAsset: []byte("new")})
+
+	tests := []struct {
+		name    string
+		exe     []byte
+		sums    []byte // nil for no checksums.txt
+		size    int64  // listed size, 0 for len(exe)
+		wantErr string
+	}{
+		{"truncated", []byte("ne"), sums, 3, "downloaded 2 bytes, release lists 3"},
+		{"corrupted", []byte("nEw"), sums, 0, "does not match checksums.txt"},
+		{"no manifest", []byte("new"), nil, 0, "no checksums.txt asset"},
+		{"not in manifest", []byte("new"), checksums(map[string][]byte{"monibright-setup.exe": []byte("new")}), 0, "no checksum for monibright.exe"},
+		{"malformed manifest", []byte("new"), []byte("abc  monibright.exe\n"), 0, "malformed checksum"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assets := map[string][]byte{exeAsset: tt.exe, sigAsset: sig}
+			if tt.sums != nil {
+				assets[sumsAsset] = tt.sums
+			}
+			exe, rel := useReleaseServer(t, s.publicKey(), assets)
+			if tt.size > 0 {
+				for i := range rel.Assets {
+					if rel.Assets[i].Name == exeAsset {
+						rel.Assets[i].Size = tt.size
+					}
+				}
+			}
+
+			err := update()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("update error = %v, want %q", err, tt.wantErr)
+			}
+			if got, _ := os.ReadFile(exe); string(got) != "old" {
+				t.Errorf("exe content = %q, want it untouched", got)
+			}
+			entries, _ := os.ReadDir(filepath.Dir(exe))
+			if len(entries) != 1 {
+				t.Errorf("files left behind: %v", entries)
+			}
+		})
+	}
+}
+
+func TestParseMinisignKey(t *testing.T) {
+	s := newTestSigner(t)
+	k, err := parseMinisignKey(s.publicKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k.id != s.id || !k.key.Equal(s.priv.Public()) {
+		t.Errorf("parsed key %X, want %X", k.id, s.id)
+	}
+	// The bare base64 line, as passed with -ldflags.
+	bare := s.publicKey()[strings.LastIndex(s.publicKey(), "\n")+1:]
+	if _, err := parseMinisignKey(bare); err != nil {
+		t.Errorf("bare key: %v", err)
+	}
+	if _, err := parseMinisignKey("RWQ="); err == nil {
+		t.Error("short key parsed")
+	}
+}
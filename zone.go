@@ -0,0 +1,50 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	// Windows has no zoneinfo database; embed one so IANA zones load everywhere.
+	_ "time/tzdata"
+)
+
+var (
+	zoneMu    sync.Mutex
+	zoneCache = map[string]*time.Location{}
+	zoneWarn  string // last zone name that failed to load, logged once
+)
+
+// scheduleZone returns the time zone sun schedules are computed in:
+// cfg.Timezone if set, else the system zone. The system zone is re-read on
+// every call (unlike time.Local) so a travelling laptop picks up a change.
+func scheduleZone() *time.Location {
+	name := cfgSnapshot().Timezone
+	if name == "" {
+		name = systemZoneName()
+	}
+	if name == "" {
+		return time.Local
+	}
+	return loadZone(name)
+}
+
+// loadZone loads and caches an IANA zone, falling back to time.Local.
+func loadZone(name string) *time.Location {
+	zoneMu.Lock()
+	defer zoneMu.Unlock()
+
+	if loc, ok := zoneCache[name]; ok {
+		return loc
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		if zoneWarn != name {
+			log.Printf("timezone %q: %v, using local time", name, err)
+			zoneWarn = name
+		}
+		return time.Local
+	}
+	zoneCache[name] = loc
+	return loc
+}
@@ -0,0 +1,24 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"strings"
+)
+
+// systemZoneName returns the IANA name of the system time zone from $TZ or
+// the /etc/localtime symlink, or "" if it can't be determined.
+func systemZoneName() string {
+	if tz, ok := os.LookupEnv("TZ"); ok {
+		return strings.TrimPrefix(tz, ":")
+	}
+	target, err := os.Readlink("/etc/localtime")
+	if err != nil {
+		return ""
+	}
+	if _, name, ok := strings.Cut(target, "zoneinfo/"); ok {
+		return name
+	}
+	return ""
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// useSchedLocation configures a location and schedule zone for the test.
+func useSchedLocation(t *testing.T, zone string, lat, lon float64) *time.Location {
+	t.Helper()
+	prev := cfg
+	t.Cleanup(func() { cfg = prev })
+	cfg.Timezone = zone
+	cfg.Latitude, cfg.Longitude = lat, lon
+	cfg.TransitionModel = modelTwilight
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+// TestSunScheduleAtDST checks schedules on DST transition days and across
+// the year boundary: every hour of the day must get the schedule of its own
+// solar day, in absolute instants, in the configured zone.
+func TestSunScheduleAtDST(t *testing.T) {
+	tests := []struct {
+		name     string
+		zone     string
+		lat, lon float64
+		date     string
+	}{
+		{"Berlin spring forward", "Europe/Berlin", 52.52, 13.41, "2024-03-31"},
+		{"Berlin fall back", "Europe/Berlin", 52.52, 13.41, "2024-10-27"},
+		{"New York spring forward", "America/New_York", 40.71, -74.01, "2024-03-10"},
+		{"New York fall back", "America/New_York", 40.71, -74.01, "2024-11-03"},
+		{"Sydney fall back", "Australia/Sydney", -33.87, 151.21, "2024-04-07"},
+		{"Sydney spring forward", "Australia/Sydney", -33.87, 151.21, "2024-10-06"},
+		{"New Year's Eve", "Europe/Berlin", 52.52, 13.41, "2024-12-31"},
+		{"New Year's Day", "Europe/Berlin", 52.52, 13.41, "2025-01-01"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loc := useSchedLocation(t, tt.zone, tt.lat, tt.lon)
+			date, _ := time.ParseInLocation("2006-01-02", tt.date, loc)
+
+			today := sunScheduleAt(date.Add(12 * time.Hour))
+			yesterday := sunScheduleAt(date.Add(-12 * time.Hour))
+			if today.key() != tt.date+" "+tt.zone {
+				t.Errorf("key = %q", today.key())
+			}
+			if today.key() == yesterday.key() {
+				t.Errorf("yesterday and today share key %q", today.key())
+			}
+			// Successive sunrises are about 24 hours apart in absolute
+			// time, whatever the clocks did overnight.
+			if d := today.Sunrise.Sub(yesterday.Sunrise) - 24*time.Hour; d.Abs() > 5*time.Minute {
+				t.Errorf("sunrise %s follows %s by 24h%+v", today.Sunrise, yesterday.Sunrise, d)
+			}
+			if e := solarElevation(today.Sunrise, tt.lat, tt.lon); e < elevSunrise-0.05 || e > elevSunrise+0.05 {
+				t.Errorf("sun at %.2f° at sunrise %s", e, today.Sunrise)
+			}
+
+			for h := range 24 {
+				now := date.Add(time.Duration(h) * time.Hour) // absolute hours: 23 or 25 on DST days
+				s := sunScheduleAt(now)
+				if d := now.Sub(s.Noon); d < -12*time.Hour || d >= 12*time.Hour {
+					t.Errorf("%s: schedule for %s, noon %s", now, s.key(), s.Noon)
+				}
+				if s.Sunrise.Location().String() != tt.zone {
+					t.Errorf("%s: sunrise in %s, want %s", now, s.Sunrise.Location(), loc)
+				}
+			}
+
+			if got := interpolateTemp(today.Noon, today, 6500, 3500); got != 6500 {
+				t.Errorf("solar noon = %dK, want 6500K", got)
+			}
+			midnight := today.Noon.Add(11 * time.Hour)
+			if got := interpolateTemp(midnight, sunScheduleAt(midnight), 6500, 3500); got != 3500 {
+				t.Errorf("near solar midnight = %dK, want 3500K", got)
+			}
+		})
+	}
+}
+
+func TestSunScheduleAtMidnightSunset(t *testing.T) {
+	// Reykjavik at the solstice: sunset at 00:04 the next day.
+	loc := useSchedLocation(t, "Atlantic/Reykjavik", 64.1466, -21.9426)
+	now := time.Date(2024, 6, 22, 0, 1, 0, 0, loc)
+	s := sunScheduleAt(now)
+	if s.key() != "2024-06-21 Atlantic/Reykjavik" {
+		t.Errorf("key = %q, want the 21st's schedule", s.key())
+	}
+	if !s.Sunset.After(now) {
+		t.Errorf("sunset %s should still be ahead at %s", s.Sunset, now)
+	}
+	if got := interpolateTemp(now, s, 6500, 3500); got <= 3500 || got >= 6500 {
+		t.Errorf("00:01 = %dK, want mid evening ramp", got)
+	}
+}
+
+func TestNormalizeSchedDST(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Made the day before spring forward, used the day after.
+	old := defaultSunSchedule(time.Date(2024, 3, 30, 9, 0, 0, 0, berlin))
+	now := time.Date(2024, 3, 31, 10, 0, 0, 0, berlin)
+	got := normalizeSched(now, old)
+
+	if h, m, _ := got.Sunrise.Clock(); h != 6 || m != 0 || got.Sunrise.Day() != 31 {
+		t.Errorf("sunrise = %s, want 06:00 on the 31st", got.Sunrise)
+	}
+	if d := got.Sunrise.Sub(old.Sunrise); d != 23*time.Hour {
+		t.Errorf("sunrise moved by %s, want 23h across spring forward", d)
+	}
+	if got.key() != "2024-03-31 Europe/Berlin" {
+		t.Errorf("key = %q", got.key())
+	}
+}
+
+func TestSunScheduleKeyYearRollover(t *testing.T) {
+	loc := useSchedLocation(t, "Europe/Berlin", 52.52, 13.41)
+	key := func(y int, m time.Month, d, h int) string {
+		return sunScheduleAt(time.Date(y, m, d, h, 0, 0, 0, loc)).key()
+	}
+	if a, b := key(2024, 12, 31, 8), key(2024, 12, 31, 20); a != b {
+		t.Errorf("same day, different keys: %q %q", a, b)
+	}
+	if a, b := key(2024, 12, 31, 12), key(2025, 1, 1, 12); a == b {
+		t.Errorf("New Year kept key %q", a)
+	}
+	// Same YearDay (365) a year apart.
+	if a, b := key(2023, 12, 31, 12), key(2024, 12, 30, 12); a == b {
+		t.Errorf("a year apart, same key %q", a)
+	}
+}
+
+func TestScheduleZone(t *testing.T) {
+	prev := cfg
+	t.Cleanup(func() { cfg = prev })
+
+	cfg.Timezone = "America/Chicago"
+	if got := scheduleZone().String(); got != "America/Chicago" {
+		t.Errorf("configured zone = %s", got)
+	}
+
+	// Without a configured zone, follow the system zone as it changes.
+	cfg.Timezone = ""
+	t.Setenv("TZ", "Asia/Tokyo")
+	if got := scheduleZone().String(); got != "Asia/Tokyo" {
+		t.Errorf("system zone = %s, want Asia/Tokyo", got)
+	}
+	t.Setenv("TZ", "Europe/Paris")
+	if got := scheduleZone().String(); got != "Europe/Paris" {
+		t.Errorf("system zone after change = %s, want Europe/Paris", got)
+	}
+
+	cfg.Timezone = "Not/AZone"
+	if got := scheduleZone(); got != time.Local {
+		t.Errorf("bad zone = %s, want Local", got)
+	}
+}
+
+func TestLocationFromTimezone(t *testing.T) {
+	useSchedLocation(t, "Europe/Berlin", 0, 0)
+	lat, lon, err := locationFromTimezone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := tzCoords["Europe/Berlin"]; lat != want[0] || lon != want[1] {
+		t.Errorf("location = %.2f,%.2f, want Berlin's %v", lat, lon, want)
+	}
+}